@@ -18,8 +18,7 @@ import (
 const (
 	mspID        = "Org1MSP"
 	cryptoPath   = "/home/akhileswarv/fabric/fabric-samples/test-network/organizations/peerOrganizations/org1.example.com"
-	certPath     = cryptoPath + "/users/User1@org1.example.com/msp/signcerts"
-	keyPath      = cryptoPath + "/users/User1@org1.example.com/msp/keystore"
+	userPath     = cryptoPath + "/users/User1@org1.example.com/msp"
 	tlsCertPath  = cryptoPath + "/peers/peer0.org1.example.com/tls/ca.crt"
 	peerEndpoint = "dns:///localhost:7051"
 	gatewayPeer  = "peer0.org1.example.com"
@@ -30,24 +29,7 @@ func main() {
 	clientConnection := newGrpcConnection()
 	defer clientConnection.Close()
 
-	id := newIdentity()
-	sign := newSign()
-
-	// Create a Gateway connection for a specific client identity
-	gw, err := client.Connect(
-		id,
-		client.WithSign(sign),
-		client.WithHash(hash.SHA256),
-		client.WithClientConnection(clientConnection),
-		// Default timeouts for different gRPC calls
-		client.WithEvaluateTimeout(5*time.Second),
-		client.WithEndorseTimeout(15*time.Second),
-		client.WithSubmitTimeout(5*time.Second),
-		client.WithCommitStatusTimeout(1*time.Minute),
-	)
-	if err != nil {
-		panic(err)
-	}
+	gw := newGateway(clientConnection, userPath)
 	defer gw.Close()
 
 	// Override default values for chaincode and channel name as they may differ in testing contexts.
@@ -62,8 +44,40 @@ func main() {
 	}
 
 	network := gw.GetNetwork(channelName)
-	contract := network.GetContract(chaincodeName)
-	web.Routers(contract)
+
+	// Privileged chaincode functions check the role attribute of the signer's certificate, so
+	// requests of those roles are signed by a user enrolled with it, when one exists, such as
+	// registrar@org1.example.com with the attribute role=registrar.
+	roleNetworks := make(map[string]*client.Network)
+	for _, role := range web.SigningRoles {
+		rolePath := cryptoPath + "/users/" + role + "@org1.example.com/msp"
+		if _, err := os.Stat(rolePath); err != nil {
+			continue
+		}
+		roleGateway := newGateway(clientConnection, rolePath)
+		defer roleGateway.Close()
+		roleNetworks[role] = roleGateway.GetNetwork(channelName)
+	}
+	web.Routers(network, roleNetworks, chaincodeName)
+}
+
+// newGateway creates a Gateway connection for the client identity in the MSP directory mspPath.
+func newGateway(clientConnection *grpc.ClientConn, mspPath string) *client.Gateway {
+	gw, err := client.Connect(
+		newIdentity(mspPath+"/signcerts"),
+		client.WithSign(newSign(mspPath+"/keystore")),
+		client.WithHash(hash.SHA256),
+		client.WithClientConnection(clientConnection),
+		// Default timeouts for different gRPC calls
+		client.WithEvaluateTimeout(5*time.Second),
+		client.WithEndorseTimeout(15*time.Second),
+		client.WithSubmitTimeout(5*time.Second),
+		client.WithCommitStatusTimeout(1*time.Minute),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return gw
 }
 
 // newGrpcConnection creates a gRPC connection to the Gateway server.
@@ -91,7 +105,7 @@ func newGrpcConnection() *grpc.ClientConn {
 }
 
 // newIdentity creates a client identity for this Gateway connection using an X.509 certificate.
-func newIdentity() *identity.X509Identity {
+func newIdentity(certPath string) *identity.X509Identity {
 	certificatePEM, err := readFirstFile(certPath)
 	if err != nil {
 		panic(fmt.Errorf("failed to read certificate file: %w", err))
@@ -111,7 +125,7 @@ func newIdentity() *identity.X509Identity {
 }
 
 // newSign creates a function that generates a digital signature from a message digest using a private key.
-func newSign() identity.Sign {
+func newSign(keyPath string) identity.Sign {
 	privateKeyPEM, err := readFirstFile(keyPath)
 	if err != nil {
 		panic(fmt.Errorf("failed to read private key file: %w", err))
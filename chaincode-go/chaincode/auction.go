@@ -0,0 +1,532 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Auctions are held in the currency of the property's price, and bids are minor units of it.
+type Auction struct {
+	Id          string `json:"id"`
+	PropertyId  string `json:"property_id"`
+	SellerEmail string `json:"seller_email"`
+	Deadline    string `json:"deadline"`
+	Status      string `json:"status"`
+	WinnerEmail string `json:"winner_email"`
+	WinningBid  int64  `json:"winning_bid"`
+	Currency    string `json:"currency"`
+}
+
+type Bid struct {
+	AuctionId   string `json:"auction_id"`
+	BidderEmail string `json:"bidder_email"`
+	Hash        string `json:"hash"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Status      string `json:"status"`
+}
+
+type AuctionResult struct {
+	Auction       Auction `json:"auction"`
+	Bids          []Bid   `json:"bids" metadata:",optional"`
+	TransactionId string  `json:"transaction_id"`
+}
+
+// BidPrivateDetails is the sealed bid a bidder passes in the "bid" transient entry. The amount is
+// in minor units of the auction's currency.
+type BidPrivateDetails struct {
+	Amount int64  `json:"amount"`
+	Salt   string `json:"salt"`
+}
+
+const auctionCompositeKey = "auction~auctionId~propertyId~sellerEmail~deadline~status~winnerEmail~winningBid~transactionId"
+const bidCompositeKey = "bid~auctionId~bidderEmail~hash~amount~status"
+
+// openAuctionCompositeKey indexes the auction running for each property, so that sales and
+// transfers can check for one without scanning every auction ever held.
+const openAuctionCompositeKey = "openauction~propertyId~auctionId"
+
+const bidPrivateCollection = "bidPrivateCollection"
+const bidTransientKey = "bid"
+
+const (
+	AuctionOpen   = "Open"
+	AuctionClosed = "Closed"
+
+	BidSealed   = "Sealed"
+	BidRevealed = "Revealed"
+)
+
+// CreateAuction opens an auction of a listed property once co-owners holding the property's
+// consent threshold have asked for one with the same deadline. Until then it records the
+// owner's consent and returns ConsentPending.
+func (r *RealEstate) CreateAuction(ctx contractapi.TransactionContextInterface, auctionId string, propertyId string, sellerEmail string, deadline string) (string, error) {
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if !property.IsListed || property.Retired {
+		return "", invalidArgument("only listed properties can be auctioned")
+	}
+	if property.Frozen {
+		return "", invalidArgument("cannot auction a frozen property")
+	}
+	if !ownsShare(property, sellerEmail) {
+		return "", permissionDenied("only an owner of the property can open an auction")
+	}
+	closesAt, err := time.Parse(time.RFC3339, deadline)
+	if err != nil {
+		return "", invalidArgument("invalid deadline: %s", deadline)
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !closesAt.After(now) {
+		return "", invalidArgument("deadline must be in the future")
+	}
+	existing, err := r.openAuctionFor(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "", invalidArgument("property is already under auction %s", existing.Id)
+	}
+	if _, _, err := r.getAuction(ctx, auctionId); err == nil {
+		return "", alreadyExists("auction %s already exists", auctionId)
+	}
+	closesAtText := closesAt.UTC().Format(time.RFC3339)
+	approved, err := r.recordConsent(ctx, propertyId, property.OwnerEmail, ConsentAuctionPrefix+closesAtText, sellerEmail)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return ConsentPending, nil
+	}
+	err = r.putAuction(ctx, Auction{
+		Id:          auctionId,
+		PropertyId:  propertyId,
+		SellerEmail: sellerEmail,
+		Deadline:    closesAtText,
+		Status:      AuctionOpen,
+	}, "")
+	if err != nil {
+		return "", err
+	}
+	return auctionId, nil
+}
+
+// SubmitBid seals a bid passed in the "bid" transient entry. The amount and salt go to the bid
+// private collection and only their hash is published until the bidder reveals it.
+func (r *RealEstate) SubmitBid(ctx contractapi.TransactionContextInterface, auctionId string, bidderEmail string) (string, error) {
+	auction, _, err := r.getAuction(ctx, auctionId)
+	if err != nil {
+		return "", err
+	}
+	if auction.Status != AuctionOpen {
+		return "", invalidArgument("auction is not open")
+	}
+	closed, err := auctionDeadlinePassed(ctx, auction)
+	if err != nil {
+		return "", err
+	}
+	if closed {
+		return "", invalidArgument("bidding has closed")
+	}
+	if bidderEmail == auction.SellerEmail {
+		return "", invalidArgument("seller cannot bid in their own auction")
+	}
+	var details BidPrivateDetails
+	if err := readTransient(ctx, bidTransientKey, &details); err != nil {
+		return "", err
+	}
+	if details.Amount <= 0 || details.Salt == "" {
+		return "", invalidArgument("bid needs a positive amount and a salt")
+	}
+	if _, err := putPrivate(ctx, bidPrivateCollection, auctionId+bidderEmail, details); err != nil {
+		return "", err
+	}
+	// Re-bidding before the deadline replaces the previous sealed bid.
+	if err := r.deleteBid(ctx, auctionId, bidderEmail); err != nil {
+		return "", err
+	}
+	hash := bidHash(bidderEmail, details.Amount, details.Salt)
+	if err := r.putBid(ctx, auctionId, bidderEmail, hash, "", BidSealed); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+func (r *RealEstate) RevealBid(ctx contractapi.TransactionContextInterface, auctionId string, bidderEmail string, amount int64, salt string) error {
+	auction, _, err := r.getAuction(ctx, auctionId)
+	if err != nil {
+		return err
+	}
+	if auction.Status != AuctionOpen {
+		return invalidArgument("auction is not open")
+	}
+	closed, err := auctionDeadlinePassed(ctx, auction)
+	if err != nil {
+		return err
+	}
+	if !closed {
+		return invalidArgument("bids can only be revealed after the deadline")
+	}
+	bid, bidKey, err := r.getBid(ctx, auctionId, bidderEmail)
+	if err != nil {
+		return err
+	}
+	if bid.Status != BidSealed {
+		return invalidArgument("bid is already revealed")
+	}
+	if bidHash(bidderEmail, amount, salt) != bid.Hash {
+		return invalidArgument("revealed bid does not match the sealed bid")
+	}
+	property, _, err := r.getProperty(ctx, auction.PropertyId)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(bidKey)
+	if err != nil {
+		return errors.New("failed to delete old bid state")
+	}
+	return r.putBid(ctx, auctionId, bidderEmail, bid.Hash, formatMoney(amount, property.Currency), BidRevealed)
+}
+
+// CloseAuction settles the auction after the deadline: the highest revealed bid from a
+// registered bidder wins and the property is transferred to them, provided the seller still owns
+// it and it is not retired, frozen or encumbered. Anyone may close an auction once its deadline
+// has passed, so an auction the seller abandons does not block sales of the property forever.
+func (r *RealEstate) CloseAuction(ctx contractapi.TransactionContextInterface, auctionId string) (*AuctionResult, error) {
+	auction, auctionKey, err := r.getAuction(ctx, auctionId)
+	if err != nil {
+		return nil, err
+	}
+	if auction.Status != AuctionOpen {
+		return nil, invalidArgument("auction is already closed")
+	}
+	closed, err := auctionDeadlinePassed(ctx, auction)
+	if err != nil {
+		return nil, err
+	}
+	if !closed {
+		return nil, invalidArgument("auction deadline has not passed yet")
+	}
+	bids, err := r.getBids(ctx, auctionId)
+	if err != nil {
+		return nil, err
+	}
+	var winner *Bid
+	for i := range bids {
+		if bids[i].Status != BidRevealed {
+			continue
+		}
+		if winner == nil || bids[i].Amount > winner.Amount {
+			winner = &bids[i]
+		}
+	}
+
+	transactionId := ""
+	if winner != nil {
+		property, propertyKey, err := r.getProperty(ctx, auction.PropertyId)
+		if err != nil {
+			return nil, err
+		}
+		if !ownsShare(property, auction.SellerEmail) {
+			return nil, invalidArgument("seller no longer owns the property")
+		}
+		if property.Retired {
+			return nil, invalidArgument("cannot sell a retired property")
+		}
+		if property.Frozen {
+			return nil, invalidArgument("property is frozen by a legal hold")
+		}
+		encumbered, err := r.hasActiveLien(ctx, auction.PropertyId)
+		if err != nil {
+			return nil, err
+		}
+		if encumbered {
+			return nil, invalidArgument("property has active liens that must be released before sale")
+		}
+		stored, err := ctx.GetStub().GetState(propertyKey)
+		if err != nil {
+			return nil, errors.New("failed to read property from world state")
+		}
+		keyParts, _, err := readAsset(ctx, assetProperty, propertyKey, stored)
+		if err != nil {
+			return nil, err
+		}
+		if winner.Currency != property.Currency {
+			return nil, invalidArgument("winning bid is in %s but the property is priced in %s", winner.Currency, property.Currency)
+		}
+		sale := SalePrivateDetails{Amount: winner.Amount, Currency: winner.Currency}
+		transactionId, err = r.completeSale(ctx, propertyKey, keyParts, winner.BidderEmail, auction.SellerEmail, sale)
+		if err != nil {
+			return nil, err
+		}
+		auction.WinnerEmail = winner.BidderEmail
+		auction.WinningBid = winner.Amount
+		auction.Currency = winner.Currency
+	}
+	err = ctx.GetStub().DelState(auctionKey)
+	if err != nil {
+		return nil, errors.New("failed to delete old auction state")
+	}
+	if err := r.deleteIndex(ctx, openAuctionCompositeKey, "openauction", auction.PropertyId, auction.Id); err != nil {
+		return nil, err
+	}
+	auction.Status = AuctionClosed
+	if err := r.putAuction(ctx, *auction, transactionId); err != nil {
+		return nil, err
+	}
+	return &AuctionResult{Auction: *auction, Bids: bids, TransactionId: transactionId}, nil
+}
+
+func (r *RealEstate) GetAuction(ctx contractapi.TransactionContextInterface, auctionId string) (*AuctionResult, error) {
+	auction, auctionKey, err := r.getAuction(ctx, auctionId)
+	if err != nil {
+		return nil, err
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(auctionKey)
+	if splitKeyErr != nil {
+		return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	bids, err := r.getBids(ctx, auctionId)
+	if err != nil {
+		return nil, err
+	}
+	if auction.Currency == "" {
+		property, _, err := r.getProperty(ctx, auction.PropertyId)
+		if err != nil {
+			return nil, err
+		}
+		auction.Currency = property.Currency
+	}
+	return &AuctionResult{Auction: *auction, Bids: bids, TransactionId: keyParts[8]}, nil
+}
+
+// openAuctionFor returns the auction currently running for a property, or nil if there is none.
+func (r *RealEstate) openAuctionFor(ctx contractapi.TransactionContextInterface, propertyId string) (*Auction, error) {
+	auctionIds, err := r.indexLookup(ctx, openAuctionCompositeKey, []string{"openauction", propertyId})
+	if err != nil {
+		return nil, err
+	}
+	if len(auctionIds) == 0 {
+		return nil, nil
+	}
+	auction, _, err := r.getAuction(ctx, auctionIds[0])
+	return auction, err
+}
+
+// indexOpenAuctions writes the open auction index for every running auction. Only RebuildIndexes
+// calls it, to backfill auctions opened before the index existed.
+func (r *RealEstate) indexOpenAuctions(ctx contractapi.TransactionContextInterface) error {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(auctionCompositeKey, []string{"auction"})
+	if err != nil {
+		return errors.New("failed to get auctions")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return errors.New("failed to iterate over auctions")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		if keyParts[5] != AuctionOpen {
+			continue
+		}
+		if err := r.putIndex(ctx, openAuctionCompositeKey, "openauction", keyParts[2], keyParts[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RealEstate) getAuction(ctx contractapi.TransactionContextInterface, auctionId string) (*Auction, string, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(auctionCompositeKey, []string{"auction", auctionId})
+	if err != nil {
+		log.Println("failed to read auction from world state")
+		return nil, "", errors.New("failed to read auction from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return nil, "", notFound("auction %s not found", auctionId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return nil, "", errors.New("failed to iterate over auctions")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return nil, "", fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	auction, err := auctionFromKeyParts(keyParts)
+	if err != nil {
+		return nil, "", err
+	}
+	return auction, queryResponse.Key, nil
+}
+
+func (r *RealEstate) putAuction(ctx contractapi.TransactionContextInterface, auction Auction, transactionId string) error {
+	winningBid := ""
+	if auction.WinnerEmail != "" {
+		winningBid = formatMoney(auction.WinningBid, auction.Currency)
+	}
+	auctionKey, err := ctx.GetStub().CreateCompositeKey(auctionCompositeKey, []string{"auction", auction.Id, auction.PropertyId, auction.SellerEmail, auction.Deadline, auction.Status, auction.WinnerEmail, winningBid, transactionId})
+	if err != nil {
+		log.Println("failed to create composite key for auction")
+		return errors.New("failed to create composite key for auction")
+	}
+	err = ctx.GetStub().PutState(auctionKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put auction in world state")
+		return errors.New("failed to put auction in world state")
+	}
+	if auction.Status == AuctionOpen {
+		return r.putIndex(ctx, openAuctionCompositeKey, "openauction", auction.PropertyId, auction.Id)
+	}
+	return nil
+}
+
+func (r *RealEstate) getBids(ctx contractapi.TransactionContextInterface, auctionId string) ([]Bid, error) {
+	var bids []Bid
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(bidCompositeKey, []string{"bid", auctionId})
+	if err != nil {
+		return nil, errors.New("failed to get bids")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over bids")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		bid, err := bidFromKeyParts(keyParts)
+		if err != nil {
+			return nil, err
+		}
+		bids = append(bids, *bid)
+	}
+	return bids, nil
+}
+
+func (r *RealEstate) getBid(ctx contractapi.TransactionContextInterface, auctionId string, bidderEmail string) (*Bid, string, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(bidCompositeKey, []string{"bid", auctionId, bidderEmail})
+	if err != nil {
+		return nil, "", errors.New("failed to read bid from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return nil, "", notFound("bid of %s in auction %s not found", bidderEmail, auctionId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return nil, "", errors.New("failed to iterate over bids")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return nil, "", fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	bid, err := bidFromKeyParts(keyParts)
+	if err != nil {
+		return nil, "", err
+	}
+	return bid, queryResponse.Key, nil
+}
+
+func (r *RealEstate) putBid(ctx contractapi.TransactionContextInterface, auctionId string, bidderEmail string, hash string, amount string, status string) error {
+	bidKey, err := ctx.GetStub().CreateCompositeKey(bidCompositeKey, []string{"bid", auctionId, bidderEmail, hash, amount, status})
+	if err != nil {
+		log.Println("failed to create composite key for bid")
+		return errors.New("failed to create composite key for bid")
+	}
+	err = ctx.GetStub().PutState(bidKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put bid in world state")
+		return errors.New("failed to put bid in world state")
+	}
+	return nil
+}
+
+func (r *RealEstate) deleteBid(ctx contractapi.TransactionContextInterface, auctionId string, bidderEmail string) error {
+	_, bidKey, err := r.getBid(ctx, auctionId, bidderEmail)
+	if err != nil {
+		// No earlier bid from this bidder.
+		return nil
+	}
+	if err := ctx.GetStub().DelState(bidKey); err != nil {
+		return errors.New("failed to delete old bid state")
+	}
+	return nil
+}
+
+func auctionDeadlinePassed(ctx contractapi.TransactionContextInterface, auction *Auction) (bool, error) {
+	deadline, err := time.Parse(time.RFC3339, auction.Deadline)
+	if err != nil {
+		return false, err
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return false, err
+	}
+	return !now.Before(deadline), nil
+}
+
+func auctionFromKeyParts(keyParts []string) (*Auction, error) {
+	auction := &Auction{
+		Id:          keyParts[1],
+		PropertyId:  keyParts[2],
+		SellerEmail: keyParts[3],
+		Deadline:    keyParts[4],
+		Status:      keyParts[5],
+		WinnerEmail: keyParts[6],
+	}
+	if keyParts[7] != "" {
+		winningBid, currency, err := parseMoney(keyParts[7])
+		if err != nil {
+			return nil, err
+		}
+		auction.WinningBid = winningBid
+		auction.Currency = currency
+	}
+	return auction, nil
+}
+
+func bidFromKeyParts(keyParts []string) (*Bid, error) {
+	bid := &Bid{
+		AuctionId:   keyParts[1],
+		BidderEmail: keyParts[2],
+		Hash:        keyParts[3],
+		Status:      keyParts[5],
+	}
+	if keyParts[4] != "" {
+		amount, currency, err := parseMoney(keyParts[4])
+		if err != nil {
+			return nil, err
+		}
+		bid.Amount = amount
+		bid.Currency = currency
+	}
+	return bid, nil
+}
+
+// bidHash is the SHA-256 commitment published for a sealed bid.
+func bidHash(bidderEmail string, amount int64, salt string) string {
+	hash := sha256.Sum256([]byte(bidderEmail + ":" + strconv.FormatInt(amount, 10) + ":" + salt))
+	return hex.EncodeToString(hash[:])
+}
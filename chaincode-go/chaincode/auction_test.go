@@ -0,0 +1,121 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuction(t *testing.T) {
+	stub := newTestStub(t)
+	seller := testClient{email: "seller@example.com"}
+	alice := testClient{email: "alice@example.com"}
+	bob := testClient{email: "bob@example.com"}
+	stranger := testClient{email: "stranger@example.com"}
+	r := new(RealEstate)
+
+	opened := time.Now().UTC().Truncate(time.Second)
+	deadline := opened.Add(time.Hour)
+	for _, propertyId := range []string{"p1", "p2"} {
+		stub.mustInvoke(seller, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Plot", "Nairobi", 10, seller.email, 100000, "USD", true, "")
+		})
+	}
+	open := func(auctionId string, propertyId string) {
+		require.NoError(t, stub.invoke(seller, opened, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := r.CreateAuction(ctx, auctionId, propertyId, seller.email, deadline.Format(time.RFC3339))
+			return err
+		}))
+	}
+	bid := func(bidder testClient, amount int64, salt string) {
+		details, err := json.Marshal(BidPrivateDetails{Amount: amount, Salt: salt})
+		require.NoError(t, err)
+		stub.TransientMap = map[string][]byte{bidTransientKey: details}
+		defer func() { stub.TransientMap = nil }()
+		require.NoError(t, stub.invoke(bidder, opened.Add(10*time.Minute), func(ctx contractapi.TransactionContextInterface) error {
+			_, err := r.SubmitBid(ctx, "a1", bidder.email)
+			return err
+		}))
+	}
+	reveal := func(bidder testClient, amount int64, salt string) {
+		require.NoError(t, stub.invoke(bidder, deadline.Add(time.Minute), func(ctx contractapi.TransactionContextInterface) error {
+			return r.RevealBid(ctx, "a1", bidder.email, amount, salt)
+		}))
+	}
+	closeAuction := func(auctionId string, at time.Time) (*AuctionResult, error) {
+		var result *AuctionResult
+		err := stub.invoke(stranger, at, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			result, err = r.CloseAuction(ctx, auctionId)
+			return err
+		})
+		return result, err
+	}
+	buy := func(propertyId string) error {
+		return stub.invoke(seller, deadline.Add(2*time.Hour), func(ctx contractapi.TransactionContextInterface) error {
+			_, err := r.BuyProperty(ctx, propertyId, alice.email, seller.email)
+			return err
+		})
+	}
+
+	open("a1", "p1")
+	bid(alice, 120000, "salt-a")
+	bid(bob, 150000, "salt-b")
+	_, err := closeAuction("a1", opened.Add(30*time.Minute))
+	assert.ErrorContains(t, err, "deadline has not passed")
+	reveal(alice, 120000, "salt-a")
+	reveal(bob, 150000, "salt-b")
+
+	// Anyone may settle an auction after its deadline, not just the seller.
+	result, err := closeAuction("a1", deadline.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, AuctionClosed, result.Auction.Status)
+	assert.Equal(t, bob.email, result.Auction.WinnerEmail)
+	assert.Equal(t, int64(150000), result.Auction.WinningBid)
+	assert.NotEmpty(t, result.TransactionId)
+	var owners []Owner
+	stub.mustInvoke(bob, func(ctx contractapi.TransactionContextInterface) error {
+		owners, err = r.GetOwners(ctx, "p1")
+		return err
+	})
+	assert.Equal(t, []Owner{{Email: bob.email, Share: 100}}, owners)
+	_, err = closeAuction("a1", deadline.Add(time.Hour))
+	assert.ErrorContains(t, err, "already closed")
+
+	// An auction nobody bids in keeps the property off the market until someone closes it.
+	open("a2", "p2")
+	assert.ErrorContains(t, buy("p2"), "under auction a2")
+	result, err = closeAuction("a2", deadline.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, result.Auction.WinnerEmail)
+	assert.Empty(t, result.TransactionId)
+	require.NoError(t, buy("p2"))
+
+	// Auctions opened before the open auction index existed are picked up by RebuildIndexes.
+	stub.mustInvoke(bob, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.UpdateFlag(ctx, "p1", bob.email)
+		return err
+	})
+	require.NoError(t, stub.invoke(bob, opened, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.CreateAuction(ctx, "a3", "p1", bob.email, deadline.Format(time.RFC3339))
+		return err
+	}))
+	for key := range stub.State {
+		if strings.Contains(key, openAuctionCompositeKey) {
+			delete(stub.State, key)
+		}
+	}
+	registrar := testClient{email: "registrar@example.com", role: roleRegistrar}
+	stub.mustInvoke(registrar, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RebuildIndexes(ctx)
+	})
+	err = stub.invoke(bob, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+		return r.TransferShare(ctx, "p1", bob.email, alice.email, "10")
+	})
+	assert.ErrorContains(t, err, "under auction a3")
+}
@@ -0,0 +1,214 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Every transaction leaves an audit entry recording who invoked which function with which
+// arguments. Only a digest of the arguments is kept, so the log never repeats private values, and
+// transient data is not included at all. The timestamp comes first so entries list oldest first.
+const auditCompositeKey = "audit~timestamp~transactionId~mspId~subject~function~argsDigest"
+
+// auditTimeLayout has a fixed width so that keys sort in time order.
+const auditTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+type AuditEntry struct {
+	TransactionId string `json:"transaction_id"`
+	Timestamp     string `json:"timestamp"`
+	MSPId         string `json:"msp_id"`
+	Subject       string `json:"subject"`
+	Function      string `json:"function"`
+	ArgsDigest    string `json:"args_digest"`
+}
+
+// RegistryContext is the transaction context of every contract. BeforeTransaction fills in the
+// audit entry and AfterTransaction writes it, together with the transaction's counter updates,
+// once the function has succeeded; failed transactions are never committed, so they leave no
+// entry either way. Functions that wrote nothing leave no entry either: they are only evaluated,
+// and the peer rejects writes in a transaction that ran a paginated query.
+type RegistryContext struct {
+	contractapi.TransactionContext
+	entry    *AuditEntry
+	counters map[string]counterUpdate
+	stub     *writeTrackingStub
+}
+
+// writeTrackingStub records whether the function wrote to the world state or a private data
+// collection.
+type writeTrackingStub struct {
+	shim.ChaincodeStubInterface
+	wrote bool
+}
+
+func (s *writeTrackingStub) PutState(key string, value []byte) error {
+	s.wrote = true
+	return s.ChaincodeStubInterface.PutState(key, value)
+}
+
+func (s *writeTrackingStub) DelState(key string) error {
+	s.wrote = true
+	return s.ChaincodeStubInterface.DelState(key)
+}
+
+func (s *writeTrackingStub) PutPrivateData(collection string, key string, value []byte) error {
+	s.wrote = true
+	return s.ChaincodeStubInterface.PutPrivateData(collection, key, value)
+}
+
+func (s *writeTrackingStub) DelPrivateData(collection string, key string) error {
+	s.wrote = true
+	return s.ChaincodeStubInterface.DelPrivateData(collection, key)
+}
+
+func (s *writeTrackingStub) PurgePrivateData(collection string, key string) error {
+	s.wrote = true
+	return s.ChaincodeStubInterface.PurgePrivateData(collection, key)
+}
+
+func auditedContract(name string) contractapi.Contract {
+	return contractapi.Contract{
+		Name:                      name,
+		BeforeTransaction:         beforeTransaction,
+		AfterTransaction:          afterTransaction,
+		TransactionContextHandler: new(RegistryContext),
+	}
+}
+
+func beforeTransaction(ctx *RegistryContext) error {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return errors.New("failed to get client MSP id")
+	}
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil || cert == nil {
+		return errors.New("failed to get client certificate")
+	}
+	timestamp, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	function, args := ctx.GetStub().GetFunctionAndParameters()
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	ctx.stub = &writeTrackingStub{ChaincodeStubInterface: ctx.GetStub()}
+	ctx.SetStub(ctx.stub)
+	ctx.entry = &AuditEntry{
+		TransactionId: ctx.GetStub().GetTxID(),
+		Timestamp:     timestamp.UTC().Format(auditTimeLayout),
+		MSPId:         mspId,
+		Subject:       cert.Subject.String(),
+		Function:      function,
+		ArgsDigest:    hex.EncodeToString(digest[:]),
+	}
+	return nil
+}
+
+func afterTransaction(ctx *RegistryContext, _ interface{}) error {
+	if ctx.entry == nil {
+		return errors.New("transaction was not audited")
+	}
+	if !ctx.stub.wrote && len(ctx.counters) == 0 {
+		return nil
+	}
+	entry := ctx.entry
+	if err := putKey(ctx, auditCompositeKey, []byte{0x00}, "audit", entry.Timestamp, entry.TransactionId, entry.MSPId, entry.Subject, entry.Function, entry.ArgsDigest); err != nil {
+		return err
+	}
+	return flushCounters(ctx)
+}
+
+// AuditPage is one page of the audit log. An empty bookmark means there are no more entries.
+type AuditPage struct {
+	Entries  []AuditEntry `json:"entries" metadata:",optional"`
+	Bookmark string       `json:"bookmark"`
+	Count    int32        `json:"count"`
+}
+
+// GetAuditLog returns one page of the audit entries between from and to (RFC3339, either may be
+// empty for an open range), oldest first. A non-empty actor keeps only entries whose MSP id or
+// certificate subject equals it, so a page can hold fewer than pageSize entries. Keys sort by
+// timestamp, so the first page starts at the key of the from time instead of scanning the log
+// from its beginning; the returned bookmark is the key the next page starts at. Only auditors may
+// read the log.
+func (r *RealEstate) GetAuditLog(ctx contractapi.TransactionContextInterface, from string, to string, actor string, pageSize int32, bookmark string) (*AuditPage, error) {
+	if _, err := requireRole(ctx, "read the audit log", roleAuditor); err != nil {
+		return nil, err
+	}
+	if pageSize == 0 {
+		pageSize = defaultQueryPageSize
+	}
+	if pageSize < 0 || pageSize > maxQueryPageSize {
+		return nil, invalidArgument("page size must be between 1 and %d", maxQueryPageSize)
+	}
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, invalidArgument("invalid from time: %s", from)
+		}
+	}
+	if to != "" {
+		if end, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, invalidArgument("invalid to time: %s", to)
+		}
+	}
+	if bookmark == "" && !start.IsZero() {
+		bookmark, err = createKey(ctx, auditCompositeKey, "audit", start.UTC().Format(auditTimeLayout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resultIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(auditCompositeKey, []string{"audit"}, pageSize, bookmark)
+	if err != nil {
+		return nil, errors.New("failed to get audit log")
+	}
+	defer resultIterator.Close()
+
+	page := &AuditPage{Entries: []AuditEntry{}, Bookmark: metadata.Bookmark}
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over audit log")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		timestamp, err := time.Parse(auditTimeLayout, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		if !start.IsZero() && timestamp.Before(start) {
+			continue
+		}
+		if !end.IsZero() && timestamp.After(end) {
+			page.Bookmark = ""
+			break
+		}
+		if actor != "" && keyParts[3] != actor && keyParts[4] != actor {
+			continue
+		}
+		page.Entries = append(page.Entries, AuditEntry{
+			Timestamp:     keyParts[1],
+			TransactionId: keyParts[2],
+			MSPId:         keyParts[3],
+			Subject:       keyParts[4],
+			Function:      keyParts[5],
+			ArgsDigest:    keyParts[6],
+		})
+	}
+	page.Count = int32(len(page.Entries))
+	return page, nil
+}
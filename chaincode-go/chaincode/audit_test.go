@@ -0,0 +1,66 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogThroughTransactionHooks(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	auditor := testClient{email: "auditor@example.com", role: roleAuditor}
+	r := new(RealEstate)
+
+	for _, propertyId := range []string{"p1", "p2", "p3"} {
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Plot", "Nairobi", 10, owner.email, 100, "USD", false, "")
+		})
+	}
+
+	// Reading the log runs a paginated query, which the peer only allows when the transaction
+	// writes nothing, so the hooks must not add an entry for the read.
+	var pages []*AuditPage
+	bookmark := ""
+	for {
+		var page *AuditPage
+		err := stub.invoke(auditor, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			page, err = r.GetAuditLog(ctx, "", "", "", 2, bookmark)
+			return err
+		})
+		require.NoError(t, err)
+		pages = append(pages, page)
+		if bookmark = page.Bookmark; bookmark == "" {
+			break
+		}
+	}
+	require.Len(t, pages, 2)
+	assert.Equal(t, int32(2), pages[0].Count)
+	assert.Equal(t, int32(1), pages[1].Count)
+	for _, page := range pages {
+		for _, entry := range page.Entries {
+			assert.Equal(t, testMSPID, entry.MSPId)
+			assert.Contains(t, entry.Subject, owner.email)
+		}
+	}
+	assert.Equal(t, "tx001", pages[0].Entries[0].TransactionId)
+	assert.Equal(t, "tx003", pages[1].Entries[0].TransactionId)
+
+	var summary *RegistrySummary
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		summary, err = r.GetRegistrySummary(ctx)
+		return err
+	})
+	assert.Equal(t, 3, summary.Properties)
+
+	err := stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.GetAuditLog(ctx, "", "", "", 0, "")
+		return err
+	})
+	assert.ErrorContains(t, err, ErrPermissionDenied)
+}
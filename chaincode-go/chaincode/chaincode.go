@@ -1,13 +1,15 @@
 package chaincode
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"time"
 
-	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -20,23 +22,63 @@ type User struct {
 	Password string `json:"password"`
 }
 type Property struct {
-	Id         string  `json:"id"`
-	Title      string  `json:"title"`
-	Location   string  `json:"location"`
-	Size       float64 `json:"size"`
-	OwnerEmail string  `json:"current_owner_email"`
-	Price      float64 `json:"price"`
-	IsListed   bool    `json:"is_listed"`
+	Id               string  `json:"id"`
+	Title            string  `json:"title"`
+	Location         string  `json:"location"`
+	Size             float64 `json:"size"`
+	OwnerEmail       string  `json:"current_owner_email"`
+	Price            int64   `json:"price"`
+	Currency         string  `json:"currency"`
+	IsListed         bool    `json:"is_listed"`
+	Owners           []Owner `json:"owners" metadata:",optional"`
+	ConsentThreshold float64 `json:"consent_threshold"`
+	BoundaryHash     string  `json:"boundary_hash"`
+	Retired          bool    `json:"retired"`
+	Frozen           bool    `json:"frozen"`
+	ListingStatus    string  `json:"listing_status"`
+	ListedFrom       string  `json:"listed_from"`
+	ListedUntil      string  `json:"listed_until"`
+	Valuation        int64   `json:"valuation"`
+	PriceDeviation   float64 `json:"price_deviation"`
+	PriceFlagged     bool    `json:"price_flagged"`
 }
 
 type Transaction struct {
-	Id          string  `json:"id"`
-	PropertyId  string  `json:"property_id"`
-	BuyerEmail  string  `json:"buyer_email"`
-	SellerEmail string  `json:"seller_email"`
-	Amount      float64 `json:"amount"`
-	Date        string  `json:"date"`
-	Status      string  `json:"status"`
+	Id            string    `json:"id"`
+	Type          string    `json:"type"`
+	PropertyId    string    `json:"property_id"`
+	BuyerEmail    string    `json:"buyer_email"`
+	SellerEmail   string    `json:"seller_email"`
+	Amount        int64     `json:"amount"`
+	Currency      string    `json:"currency"`
+	Date          string    `json:"date"`
+	Status        string    `json:"status"`
+	Jurisdiction  string    `json:"jurisdiction"`
+	Tax           []TaxLine `json:"tax" metadata:",optional"`
+	TotalTax      int64     `json:"total_tax"`
+	ReversedBy    string    `json:"reversed_by"`
+	Reverses      string    `json:"reverses"`
+	Justification string    `json:"justification"`
+	ApprovedBy    string    `json:"approved_by"`
+	Heirs         []Owner   `json:"heirs" metadata:",optional"`
+	ProbateHash   string    `json:"probate_hash"`
+	AgentEmail    string    `json:"agent_email"`
+}
+
+type PropertyDetail struct {
+	Property  Property   `json:"property"`
+	Liens     []Lien     `json:"liens" metadata:",optional"`
+	Leases    []Lease    `json:"leases" metadata:",optional"`
+	Documents []Document `json:"documents" metadata:",optional"`
+}
+
+type PropertyHistory struct {
+	PropertyId   string        `json:"property_id"`
+	Transactions []Transaction `json:"transactions" metadata:",optional"`
+	Liens        []Lien        `json:"liens" metadata:",optional"`
+	Lineage      []LineageLink `json:"lineage" metadata:",optional"`
+	Freezes      []Freeze      `json:"freezes" metadata:",optional"`
+	AgentActions []AgentAction `json:"agent_actions" metadata:",optional"`
 }
 
 type RealEstate struct {
@@ -46,23 +88,78 @@ type RealEstate struct {
 const userCompositeKey = "user~userId~name~email~address~contact~password"
 const propertCompositeKey = "property~propertyId~title~location~size~ownerEmail~price~isListed"
 const transactionCompositeKey = "transaction~transactionId~propertyId~buyerEmail~sellerEmail~amount~date~status"
+const transactionTypeCompositeKey = "transactiontype~transactionId~type"
+
+// transactionDetailCompositeKey holds what reversals, successions and agents add to a
+// transaction, keyed by the transaction id alone, so that reading a transaction takes one
+// GetState instead of scanning those records.
+const transactionDetailCompositeKey = "transactiondetail~transactionId"
 
-func (r *RealEstate) RegisterUser(ctx contractapi.TransactionContextInterface, userId string, name string, email string, address string, contact string, password string) error {
-	userKey, err := ctx.GetStub().CreateCompositeKey(userCompositeKey, []string{"user", userId, name, email, address, contact, password})
+const boundaryCompositeKey = "boundary~propertyId~hash"
+
+const transactionDateLayout = "2006-01-02 15:04:05"
+
+// Transactions without a transactiontype key are sales.
+const (
+	TransactionTypeSale       = "Sale"
+	TransactionTypeReversal   = "Reversal"
+	TransactionTypeSuccession = "Succession"
+)
+
+// RegisterUser expects the address, contact and password in the "user" transient entry. They are
+// kept in the user private collection and only their hash is written to the public state. Both the
+// user id and the email must be unused.
+func (r *RealEstate) RegisterUser(ctx contractapi.TransactionContextInterface, userId string, name string, email string) error {
+	if err := requireFields("userId", userId, "name", name, "email", email); err != nil {
+		return err
+	}
+	if err := validateEmail("email", email); err != nil {
+		return err
+	}
+	exists, err := keyExists(ctx, userCompositeKey, "user", userId)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return alreadyExists("user %s already exists", userId)
+	}
+	registered, err := r.indexLookup(ctx, emailIndexCompositeKey, []string{"email", email})
+	if err != nil {
+		return err
+	}
+	if len(registered) > 0 {
+		return alreadyExists("email %s is already registered", email)
+	}
+	var details UserPrivateDetails
+	if err := readTransient(ctx, userTransientKey, &details); err != nil {
+		return err
+	}
+	hash, err := putPrivate(ctx, userPrivateCollection, userId, details)
+	if err != nil {
+		return err
+	}
+	userKey, err := ctx.GetStub().CreateCompositeKey(userCompositeKey, []string{"user", userId, name, email, "", "", ""})
 	if err != nil {
 		log.Println("failed to create composite key for user ")
 		return errors.New("failed to create composite key for user ")
 	}
-	err = ctx.GetStub().PutState(userKey, []byte{0x00})
+	err = ctx.GetStub().PutState(userKey, versionedValue(assetUser, hash))
 	if err != nil {
 		log.Println("failed to put user in world state")
 		return errors.New("failed to put user in world state")
 	}
-	return nil
+	if err := r.putIndex(ctx, emailIndexCompositeKey, "email", email, userId); err != nil {
+		return err
+	}
+	return addCount(ctx, counterUsers, 1)
 }
 
 func (r *RealEstate) GetAllUsers(ctx contractapi.TransactionContextInterface) ([]User, error) {
 	var users []User
+	authorized, err := canReadPrivateData(ctx)
+	if err != nil {
+		return nil, err
+	}
 	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(userCompositeKey, []string{"user"})
 	if err != nil {
 		return nil, errors.New("failed to get users")
@@ -74,9 +171,9 @@ func (r *RealEstate) GetAllUsers(ctx contractapi.TransactionContextInterface) ([
 		if err != nil {
 			return nil, errors.New("failed to iterate over users ")
 		}
-		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
-		if splitKeyErr != nil {
-			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		keyParts, _, err := readAsset(ctx, assetUser, queryResponse.Key, queryResponse.Value)
+		if err != nil {
+			return nil, err
 		}
 		user := User{
 			UserId:   keyParts[1],
@@ -86,21 +183,92 @@ func (r *RealEstate) GetAllUsers(ctx contractapi.TransactionContextInterface) ([
 			Contact:  keyParts[5],
 			Password: keyParts[6],
 		}
+		if authorized {
+			var details UserPrivateDetails
+			found, err := getPrivate(ctx, userPrivateCollection, user.UserId, &details)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				user.Address, user.Contact, user.Password = details.Address, details.Contact, details.Password
+			}
+		} else {
+			// Users registered before private collections still carry these in their key.
+			user.Address, user.Contact, user.Password = "", "", ""
+		}
 		users = append(users, user)
 	}
 	return users, nil
 }
 
-func (r *RealEstate) RegisterProperty(ctx contractapi.TransactionContextInterface, propertyId string, title string, location string, size string, ownerEmail string, price string, isListed string) error {
-	propertyKey, err := ctx.GetStub().CreateCompositeKey(propertCompositeKey, []string{"property", propertyId, title, location, size, ownerEmail, price, isListed})
+// RegisterProperty records a new property priced at price minor units of currency. boundaryHash
+// is the SHA-256 of the parcel's GeoJSON boundary kept in the read model, or empty when the
+// parcel has no surveyed boundary.
+func (r *RealEstate) RegisterProperty(ctx contractapi.TransactionContextInterface, propertyId string, title string, location string, size float64, ownerEmail string, price int64, currency string, isListed bool, boundaryHash string) error {
+	if err := requireFields("propertyId", propertyId, "title", title, "location", location, "ownerEmail", ownerEmail); err != nil {
+		return err
+	}
+	if err := validateEmail("ownerEmail", ownerEmail); err != nil {
+		return err
+	}
+	if !(size > 0) || math.IsInf(size, 0) {
+		return invalidArgument("size must be a positive number")
+	}
+	if err := validateMoney("price", price, currency); err != nil {
+		return err
+	}
+	if boundaryHash != "" {
+		if decoded, err := hex.DecodeString(boundaryHash); err != nil || len(decoded) != 32 {
+			return invalidArgument("boundary hash must be a hex encoded SHA-256 digest")
+		}
+	}
+	exists, err := keyExists(ctx, propertCompositeKey, "property", propertyId)
 	if err != nil {
-		return errors.New("failed to create composite key for property")
+		return err
+	}
+	if exists {
+		return alreadyExists("property %s already exists", propertyId)
 	}
-	err = ctx.GetStub().PutState(propertyKey, []byte{0x00})
+	err = r.putProperty(ctx, propertyId, title, location, formatAmount(size), ownerEmail, formatMoney(price, currency), strconv.FormatBool(isListed))
 	if err != nil {
-		return errors.New("failed to put property in world state")
+		return err
 	}
-	return nil
+	if err := addCount(ctx, counterProperties, 1); err != nil {
+		return err
+	}
+	if err := countListing(ctx, false, isListed); err != nil {
+		return err
+	}
+	if isListed {
+		if err := putPriceRecord(ctx, propertyId, PriceListed, formatMoney(price, currency)); err != nil {
+			return err
+		}
+	}
+	if boundaryHash != "" {
+		boundaryKey, err := ctx.GetStub().CreateCompositeKey(boundaryCompositeKey, []string{"boundary", propertyId, boundaryHash})
+		if err != nil {
+			return errors.New("failed to create composite key for boundary")
+		}
+		err = ctx.GetStub().PutState(boundaryKey, []byte{0x00})
+		if err != nil {
+			return errors.New("failed to put boundary in world state")
+		}
+	}
+	return r.setSoleOwner(ctx, propertyId, ownerEmail)
+}
+
+func (r *RealEstate) putProperty(ctx contractapi.TransactionContextInterface, propertyId string, title string, location string, size string, ownerEmail string, price string, isListed string) error {
+	if err := putKey(ctx, propertCompositeKey, versionedValue(assetProperty, nil), "property", propertyId, title, location, size, ownerEmail, price, isListed); err != nil {
+		return err
+	}
+	if err := r.putIndex(ctx, locationIndexCompositeKey, "location", normalizeLocation(location), propertyId); err != nil {
+		return err
+	}
+	retired, err := keyExists(ctx, retiredCompositeKey, "retired", propertyId)
+	if err != nil {
+		return err
+	}
+	return r.putPropertyDocument(ctx, propertyId, title, location, size, ownerEmail, price, isListed, retired)
 
 }
 
@@ -117,15 +285,15 @@ func (r *RealEstate) GetAllProperty(ctx contractapi.TransactionContextInterface)
 		if err != nil {
 			return nil, errors.New("failed to iterate over properties")
 		}
-		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
-		if splitKeyErr != nil {
-			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		keyParts, _, err := readAsset(ctx, assetProperty, queryResponse.Key, queryResponse.Value)
+		if err != nil {
+			return nil, err
 		}
 		size, err := strconv.ParseFloat(keyParts[4], 64)
 		if err != nil {
 			return nil, err
 		}
-		price, err := strconv.ParseFloat(keyParts[6], 64)
+		price, currency, err := parseMoney(keyParts[6])
 		if err != nil {
 			return nil, err
 		}
@@ -140,45 +308,82 @@ func (r *RealEstate) GetAllProperty(ctx contractapi.TransactionContextInterface)
 			Size:       size,
 			OwnerEmail: keyParts[5],
 			Price:      price,
+			Currency:   currency,
 			IsListed:   isListed,
 		}
+		if err := r.loadPropertyState(ctx, &property); err != nil {
+			return nil, err
+		}
 
 		properties = append(properties, property)
 	}
 	return properties, nil
 }
 
-func (r *RealEstate) UpdateFlag(ctx contractapi.TransactionContextInterface, propertyId string, OwnerEmail string) error {
+func (r *RealEstate) UpdateFlag(ctx contractapi.TransactionContextInterface, propertyId string, OwnerEmail string) (string, error) {
 
 	propertyBytes, err := ctx.GetStub().GetStateByPartialCompositeKey(propertCompositeKey, []string{"property", propertyId})
 	if err != nil {
 		log.Println("failed to read property from world state")
-		return errors.New("failed to read property from world state")
+		return "", errors.New("failed to read property from world state")
 	}
 	if !propertyBytes.HasNext() {
 		log.Println("property not found")
-		return errors.New("property not found")
+		return "", notFound("property %s not found", propertyId)
 	}
 	queryResponse, err := propertyBytes.Next()
 	if err != nil {
-		return errors.New("failed to iterate over properties")
+		return "", errors.New("failed to iterate over properties")
+	}
+	keyParts, _, err := readAsset(ctx, assetProperty, queryResponse.Key, queryResponse.Value)
+	if err != nil {
+		return "", err
 	}
-	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
-	if splitKeyErr != nil {
-		return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	if keyParts[7] == "true" {
+		return "", invalidArgument("property already listed for sale")
+	}
+	if err := r.ensureActive(ctx, propertyId); err != nil {
+		return "", err
+	}
+	if err := r.ensureNotFrozen(ctx, propertyId); err != nil {
+		return "", err
+	}
+	approved, err := r.recordConsent(ctx, propertyId, keyParts[5], ConsentList, OwnerEmail)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return ConsentPending, nil
 	}
 	keyParts[7] = "true"
 
 	err = ctx.GetStub().DelState(queryResponse.Key)
 	if err != nil {
-		return errors.New("failed to delete old property state")
+		return "", errors.New("failed to delete old property state")
 	}
 
-	err = r.RegisterProperty(ctx, propertyId, keyParts[2], keyParts[3], keyParts[4], keyParts[5], keyParts[6], keyParts[7])
+	err = r.putProperty(ctx, propertyId, keyParts[2], keyParts[3], keyParts[4], keyParts[5], keyParts[6], keyParts[7])
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	if err := countListing(ctx, false, true); err != nil {
+		return "", err
+	}
+	if err := putPriceRecord(ctx, propertyId, PriceListed, keyParts[6]); err != nil {
+		return "", err
+	}
+	// Listing now replaces any listing scheduled for later.
+	if err := r.clearListing(ctx, propertyId); err != nil {
+		return "", err
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := r.putListing(ctx, propertyId, now, now.Add(defaultListingPeriod), ListingLive); err != nil {
+		return "", err
+	}
+	return ConsentApproved, nil
 
 }
 
@@ -190,40 +395,117 @@ func (r *RealEstate) BuyProperty(ctx contractapi.TransactionContextInterface, pr
 	}
 	if !propertyBytes.HasNext() {
 		log.Println("property not found")
-		return "", errors.New("property not found")
+		return "", notFound("property %s not found", propertyId)
 	}
 	queryResponse, err := propertyBytes.Next()
 	if err != nil {
 		return "", errors.New("failed to iterate over properties")
 	}
-	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
-	if splitKeyErr != nil {
-		return "", fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	keyParts, _, err := readAsset(ctx, assetProperty, queryResponse.Key, queryResponse.Value)
+	if err != nil {
+		return "", err
 	}
-	
-	transactionId := ctx.GetStub().GetTxID()
-	currentTime := time.Now()
-	transactionKey, err := ctx.GetStub().CreateCompositeKey(transactionCompositeKey, []string{"transaction", transactionId, propertyId, buyerEmail, sellerEmail, keyParts[6], currentTime.Format("2006-01-02 15:04:05"), "Completed"})
+	if err := r.ensureActive(ctx, propertyId); err != nil {
+		return "", err
+	}
+	if err := r.ensureNotFrozen(ctx, propertyId); err != nil {
+		return "", err
+	}
+	if err := r.ensureTransferable(ctx, propertyId); err != nil {
+		return "", err
+	}
+	approved, err := r.recordConsent(ctx, propertyId, keyParts[5], ConsentSellPrefix+buyerEmail, sellerEmail)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return "", nil
+	}
+
+	sale, err := readSaleAmount(ctx, keyParts[6])
 	if err != nil {
-		log.Println("failed to create composite key for transaction")
-		return "", errors.New("failed to create composite key for transaction")
+		return "", err
 	}
+	return r.completeSale(ctx, queryResponse.Key, keyParts, buyerEmail, sellerEmail, sale)
+}
 
-	err = ctx.GetStub().PutState(transactionKey, []byte{0x00})
+// ensureTransferable fails while a lien encumbers the property or an auction of it is open, since
+// neither a sale nor a share transfer may move the property out from under them.
+func (r *RealEstate) ensureTransferable(ctx contractapi.TransactionContextInterface, propertyId string) error {
+	encumbered, err := r.hasActiveLien(ctx, propertyId)
 	if err != nil {
-		log.Println("failed to save transaction to world state")
-		return "", errors.New("failed to save transaction to world state")
+		return err
 	}
-	
+	if encumbered {
+		return invalidArgument("property has active liens that must be released before transfer")
+	}
+	auction, err := r.openAuctionFor(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	if auction != nil {
+		return invalidArgument("property is under auction %s", auction.Id)
+	}
+	return nil
+}
+
+// completeSale records the sale transaction and hands the property, its shares and any
+// running leases over to the buyer. keyParts are the split property key stored at propertyKey.
+func (r *RealEstate) completeSale(ctx contractapi.TransactionContextInterface, propertyKey string, keyParts []string, buyerEmail string, sellerEmail string, sale SalePrivateDetails) (string, error) {
+	var err error
+	propertyId := keyParts[1]
+	transactionId := ctx.GetStub().GetTxID()
+	sale.Jurisdiction, sale.Tax, sale.TotalTax, err = r.computeTax(ctx, keyParts[3], sale.Amount, sale.Currency)
+	if err != nil {
+		return "", err
+	}
+	hash, err := putPrivate(ctx, transactionPrivateCollection, transactionId, sale)
+	if err != nil {
+		return "", err
+	}
+	currentTime, err := txTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = r.putTransaction(ctx, []string{"transaction", transactionId, propertyId, buyerEmail, sellerEmail, "", currentTime.UTC().Format(transactionDateLayout), TransactionCompleted}, hash)
+	if err != nil {
+		return "", err
+	}
+
+	wasListed := keyParts[7] == "true"
 	keyParts[5] = buyerEmail
 	keyParts[7] = "false"
 
-	err = ctx.GetStub().DelState(queryResponse.Key)
+	err = ctx.GetStub().DelState(propertyKey)
 	if err != nil {
 		return "", errors.New("failed to delete old property state")
 	}
 
-	err = r.RegisterProperty(ctx, propertyId, keyParts[2], keyParts[3], keyParts[4], keyParts[5], keyParts[6], keyParts[7])
+	err = r.putProperty(ctx, propertyId, keyParts[2], keyParts[3], keyParts[4], keyParts[5], keyParts[6], keyParts[7])
+	if err != nil {
+		return "", err
+	}
+	if err := countListing(ctx, wasListed, false); err != nil {
+		return "", err
+	}
+	if err := addCount(ctx, counterSales, 1); err != nil {
+		return "", err
+	}
+	if err := putPriceRecord(ctx, propertyId, PriceSold, ""); err != nil {
+		return "", err
+	}
+	if err := r.recordSaleOwners(ctx, transactionId, propertyId, sellerEmail); err != nil {
+		return "", err
+	}
+	err = r.setSoleOwner(ctx, propertyId, buyerEmail)
+	if err != nil {
+		return "", err
+	}
+	err = r.clearListing(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	err = r.transferLeases(ctx, propertyId, "", buyerEmail)
 	if err != nil {
 		return "", err
 	}
@@ -231,43 +513,366 @@ func (r *RealEstate) BuyProperty(ctx contractapi.TransactionContextInterface, pr
 }
 
 func (r *RealEstate) GetAllTransaction(ctx contractapi.TransactionContextInterface, transactionId string) ([]Transaction, error) {
-	var resultIterator shim.StateQueryIteratorInterface
-	var err error
-	var transactions []Transaction
 	if transactionId != "" {
-		resultIterator, err = ctx.GetStub().GetStateByPartialCompositeKey(transactionCompositeKey, []string{"transaction", transactionId})
-	} else {
-		resultIterator, err = ctx.GetStub().GetStateByPartialCompositeKey(transactionCompositeKey, []string{"transaction"})
+		return r.getTransactions(ctx, []string{transactionId})
+	}
+	return r.getTransactions(ctx, nil)
+}
+
+// getTransactions reads the given transactions, or every transaction when transactionIds is nil,
+// together with their reversal, succession and agent details.
+func (r *RealEstate) getTransactions(ctx contractapi.TransactionContextInterface, transactionIds []string) ([]Transaction, error) {
+	var transactions []Transaction
+	authorized, err := canReadPrivateData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queries := [][]string{{"transaction"}}
+	if transactionIds != nil {
+		queries = nil
+		for _, transactionId := range transactionIds {
+			queries = append(queries, []string{"transaction", transactionId})
+		}
+	}
+
+	for _, attributes := range queries {
+		resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transactionCompositeKey, attributes)
+		if err != nil {
+			return nil, errors.New("failed to get transactions")
+		}
+		for resultIterator.HasNext() {
+			queryResponse, err := resultIterator.Next()
+			if err != nil {
+				resultIterator.Close()
+				return nil, errors.New("failed to iterate over transactions")
+			}
+			keyParts, _, err := readAsset(ctx, assetTransaction, queryResponse.Key, queryResponse.Value)
+			if err != nil {
+				resultIterator.Close()
+				return nil, err
+			}
+			sale, err := r.transactionSale(ctx, keyParts[1], keyParts[5], authorized)
+			if err != nil {
+				resultIterator.Close()
+				return nil, err
+			}
+			detail, err := r.getTransactionDetail(ctx, keyParts[1])
+			if err != nil {
+				resultIterator.Close()
+				return nil, err
+			}
+			transactions = append(transactions, Transaction{
+				Id:            keyParts[1],
+				PropertyId:    keyParts[2],
+				BuyerEmail:    keyParts[3],
+				SellerEmail:   keyParts[4],
+				Amount:        sale.Amount,
+				Currency:      sale.Currency,
+				Date:          keyParts[6],
+				Status:        keyParts[7],
+				Jurisdiction:  sale.Jurisdiction,
+				Tax:           sale.Tax,
+				TotalTax:      sale.TotalTax,
+				Type:          detail.Type,
+				ReversedBy:    detail.ReversedBy,
+				Reverses:      detail.Reverses,
+				Justification: detail.Justification,
+				ApprovedBy:    detail.ApprovedBy,
+				Heirs:         detail.Heirs,
+				ProbateHash:   detail.ProbateHash,
+				AgentEmail:    detail.AgentEmail,
+			})
+		}
+		resultIterator.Close()
+	}
+	return transactions, nil
+}
+
+func (r *RealEstate) GetProperty(ctx contractapi.TransactionContextInterface, propertyId string) (*PropertyDetail, error) {
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	liens, err := r.GetLiens(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := r.GetLeases(ctx, propertyId)
+	if err != nil {
+		return nil, err
 	}
+	documents, err := r.GetDocuments(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	return &PropertyDetail{Property: *property, Liens: liens, Leases: leases, Documents: documents}, nil
+}
 
+func (r *RealEstate) GetPropertyHistory(ctx contractapi.TransactionContextInterface, propertyId string) (*PropertyHistory, error) {
+	if _, _, err := r.getProperty(ctx, propertyId); err != nil {
+		return nil, err
+	}
+	history := &PropertyHistory{PropertyId: propertyId}
+	transactions, err := r.GetTransactionsByProperty(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	history.Transactions = transactions
+	history.Liens, err = r.GetLiens(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	history.Lineage, err = r.GetLineage(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	history.Freezes, err = r.GetFreezes(ctx, propertyId)
 	if err != nil {
-		return nil, errors.New("failed to get transactions")
+		return nil, err
+	}
+	history.AgentActions, err = r.GetAgentActions(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// getProperty looks up a single property by id and returns it together with its current state key.
+func (r *RealEstate) getProperty(ctx contractapi.TransactionContextInterface, propertyId string) (*Property, string, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(propertCompositeKey, []string{"property", propertyId})
+	if err != nil {
+		log.Println("failed to read property from world state")
+		return nil, "", errors.New("failed to read property from world state")
 	}
 	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return nil, "", notFound("property %s not found", propertyId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return nil, "", errors.New("failed to iterate over properties")
+	}
+	keyParts, _, err := readAsset(ctx, assetProperty, queryResponse.Key, queryResponse.Value)
+	if err != nil {
+		return nil, "", err
+	}
+	property, err := propertyFromKeyParts(keyParts)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := r.loadPropertyState(ctx, property); err != nil {
+		return nil, "", err
+	}
+	return property, queryResponse.Key, nil
+}
 
-	for resultIterator.HasNext() {
+// loadPropertyState fills in the parts of a property kept outside its own composite key.
+func (r *RealEstate) loadPropertyState(ctx contractapi.TransactionContextInterface, property *Property) error {
+	if err := r.loadOwnership(ctx, property); err != nil {
+		return err
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(boundaryCompositeKey, []string{"boundary", property.Id})
+	if err != nil {
+		return errors.New("failed to read boundary from world state")
+	}
+	defer resultIterator.Close()
+	if resultIterator.HasNext() {
 		queryResponse, err := resultIterator.Next()
 		if err != nil {
-			return nil, errors.New("failed to iterate over transactions")
+			return errors.New("failed to iterate over boundaries")
 		}
 		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
 		if splitKeyErr != nil {
-			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+			return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		property.BoundaryHash = keyParts[2]
+	}
+	retiredBy, err := r.retiredBy(ctx, property.Id)
+	if err != nil {
+		return err
+	}
+	property.Retired = retiredBy != ""
+	frozen, err := r.isFrozen(ctx, property.Id)
+	if err != nil {
+		return err
+	}
+	property.Frozen = frozen
+	current, err := r.getListing(ctx, property.Id)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		property.ListingStatus = current.status
+		property.ListedFrom = current.startsAt.UTC().Format(time.RFC3339)
+		property.ListedUntil = current.endsAt.UTC().Format(time.RFC3339)
+	}
+	return r.loadValuation(ctx, property)
+}
+
+func propertyFromKeyParts(keyParts []string) (*Property, error) {
+	size, err := strconv.ParseFloat(keyParts[4], 64)
+	if err != nil {
+		return nil, err
+	}
+	price, currency, err := parseMoney(keyParts[6])
+	if err != nil {
+		return nil, err
+	}
+	isListed, err := strconv.ParseBool(keyParts[7])
+	if err != nil {
+		return nil, err
+	}
+	return &Property{
+		Id:         keyParts[1],
+		Title:      keyParts[2],
+		Location:   keyParts[3],
+		Size:       size,
+		OwnerEmail: keyParts[5],
+		Price:      price,
+		Currency:   currency,
+		IsListed:   isListed,
+	}, nil
+}
+
+// readSaleAmount takes the negotiated amount from the "sale" transient entry, falling back to
+// the listed price when the client did not negotiate one. A negotiated amount is in minor units
+// of the listing's currency, which the entry may repeat but not change.
+func readSaleAmount(ctx contractapi.TransactionContextInterface, listedPrice string) (SalePrivateDetails, error) {
+	price, currency, err := parseMoney(listedPrice)
+	if err != nil {
+		return SalePrivateDetails{}, err
+	}
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return SalePrivateDetails{}, errors.New("failed to read transient data")
+	}
+	if _, ok := transientMap[saleTransientKey]; !ok {
+		return SalePrivateDetails{Amount: price, Currency: currency}, nil
+	}
+	var negotiated struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := readTransient(ctx, saleTransientKey, &negotiated); err != nil {
+		return SalePrivateDetails{}, err
+	}
+	if negotiated.Currency != "" && negotiated.Currency != currency {
+		return SalePrivateDetails{}, invalidArgument("sale amount must be in %s, the currency of the listing", currency)
+	}
+	if negotiated.Amount <= 0 {
+		return SalePrivateDetails{}, invalidArgument("sale amount must be greater than zero")
+	}
+	return SalePrivateDetails{Amount: negotiated.Amount, Currency: currency}, nil
+}
+
+// transactionSale resolves the private sale details of a transaction. Amounts of sales made
+// before private collections were introduced are still part of the public key.
+func (r *RealEstate) transactionSale(ctx contractapi.TransactionContextInterface, transactionId string, publicAmount string, authorized bool) (SalePrivateDetails, error) {
+	var sale SalePrivateDetails
+	if !authorized {
+		return sale, nil
+	}
+	if publicAmount != "" {
+		amount, currency, err := parseMoney(publicAmount)
+		if err != nil {
+			return sale, err
 		}
-		amount, err := strconv.ParseFloat(keyParts[5], 64)
+		sale.Amount, sale.Currency = amount, currency
+		return sale, nil
+	}
+	if _, err := getPrivate(ctx, transactionPrivateCollection, transactionId, &sale); err != nil {
+		return sale, err
+	}
+	return sale, nil
+}
+
+type transactionDetail struct {
+	Type          string  `json:"type"`
+	ReversedBy    string  `json:"reversed_by,omitempty"`
+	Reverses      string  `json:"reverses,omitempty"`
+	Justification string  `json:"justification,omitempty"`
+	ApprovedBy    string  `json:"approved_by,omitempty"`
+	Heirs         []Owner `json:"heirs,omitempty"`
+	ProbateHash   string  `json:"probate_hash,omitempty"`
+	AgentEmail    string  `json:"agent_email,omitempty"`
+}
+
+// getTransactionDetail reads the details of a transaction. Transactions without any are sales.
+func (r *RealEstate) getTransactionDetail(ctx contractapi.TransactionContextInterface, transactionId string) (transactionDetail, error) {
+	detail := transactionDetail{Type: TransactionTypeSale}
+	detailKey, err := createKey(ctx, transactionDetailCompositeKey, "transactiondetail", transactionId)
+	if err != nil {
+		return detail, err
+	}
+	data, err := ctx.GetStub().GetState(detailKey)
+	if err != nil {
+		return detail, errors.New("failed to read transaction detail from world state")
+	}
+	if data == nil {
+		return detail, nil
+	}
+	if err := json.Unmarshal(data, &detail); err != nil {
+		return detail, err
+	}
+	return detail, nil
+}
+
+// updateTransactionDetail applies update to the details of a transaction and writes them back.
+// Reads do not see the writes of their own transaction, so a transaction must update the details
+// of each transaction id at most once.
+func (r *RealEstate) updateTransactionDetail(ctx contractapi.TransactionContextInterface, transactionId string, update func(detail *transactionDetail)) error {
+	detail, err := r.getTransactionDetail(ctx, transactionId)
+	if err != nil {
+		return err
+	}
+	update(&detail)
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	return putKey(ctx, transactionDetailCompositeKey, data, "transactiondetail", transactionId)
+}
+
+func (r *RealEstate) putTransactionType(ctx contractapi.TransactionContextInterface, transactionId string, transactionType string) error {
+	typeKey, err := ctx.GetStub().CreateCompositeKey(transactionTypeCompositeKey, []string{"transactiontype", transactionId, transactionType})
+	if err != nil {
+		log.Println("failed to create composite key for transaction type")
+		return errors.New("failed to create composite key for transaction type")
+	}
+	err = ctx.GetStub().PutState(typeKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put transaction type in world state")
+		return errors.New("failed to put transaction type in world state")
+	}
+	return nil
+}
+
+func (r *RealEstate) getTransactionTypes(ctx contractapi.TransactionContextInterface) (map[string]string, error) {
+	types := make(map[string]string)
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transactionTypeCompositeKey, []string{"transactiontype"})
+	if err != nil {
+		return nil, errors.New("failed to get transaction types")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
 		if err != nil {
-			return nil, err
+			return nil, errors.New("failed to iterate over transaction types")
 		}
-		transaction := Transaction{
-			Id:          keyParts[1],
-			PropertyId:  keyParts[2],
-			BuyerEmail:  keyParts[3],
-			SellerEmail: keyParts[4],
-			Amount:      amount,
-			Date:        keyParts[6],
-			Status:      keyParts[7],
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
 		}
-		transactions = append(transactions, transaction)
+		types[keyParts[1]] = keyParts[2]
 	}
-	return transactions, nil
+	return types, nil
+}
+
+// txTime returns the proposal timestamp, which is the same on every endorsing peer.
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New("failed to read transaction timestamp")
+	}
+	return timestamp.AsTime(), nil
 }
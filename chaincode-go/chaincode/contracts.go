@@ -0,0 +1,389 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The chaincode is split into namespaced contracts: clients call "user:Register",
+// "property:Register", "transfer:Buy", "lease:Create" and so on. All of them share one RealEstate
+// registry, so keys are built by the same helpers whichever contract writes them. RealEstate
+// itself stays registered as the default contract, which keeps the legacy function names such as
+// RegisterProperty or BuyProperty working while clients migrate.
+const (
+	UserContractName       = "user"
+	PropertyContractName   = "property"
+	TransferContractName   = "transfer"
+	LienContractName       = "lien"
+	LeaseContractName      = "lease"
+	AuctionContractName    = "auction"
+	TaxContractName        = "tax"
+	FreezeContractName     = "freeze"
+	ValuationContractName  = "valuation"
+	DelegationContractName = "delegation"
+	DocumentContractName   = "document"
+	AuditContractName      = "audit"
+	RegistryContractName   = "registry"
+)
+
+// Contracts returns every contract of the chaincode, the legacy default contract first. All of
+// them write an audit entry for each transaction.
+func Contracts() []contractapi.ContractInterface {
+	registry := &RealEstate{Contract: auditedContract("")}
+	return []contractapi.ContractInterface{
+		registry,
+		&UserContract{Contract: auditedContract(UserContractName), registry: registry},
+		&PropertyContract{Contract: auditedContract(PropertyContractName), registry: registry},
+		&TransferContract{Contract: auditedContract(TransferContractName), registry: registry},
+		&LienContract{Contract: auditedContract(LienContractName), registry: registry},
+		&LeaseContract{Contract: auditedContract(LeaseContractName), registry: registry},
+		&AuctionContract{Contract: auditedContract(AuctionContractName), registry: registry},
+		&TaxContract{Contract: auditedContract(TaxContractName), registry: registry},
+		&FreezeContract{Contract: auditedContract(FreezeContractName), registry: registry},
+		&ValuationContract{Contract: auditedContract(ValuationContractName), registry: registry},
+		&DelegationContract{Contract: auditedContract(DelegationContractName), registry: registry},
+		&DocumentContract{Contract: auditedContract(DocumentContractName), registry: registry},
+		&AuditContract{Contract: auditedContract(AuditContractName), registry: registry},
+		&RegistryContract{Contract: auditedContract(RegistryContractName), registry: registry},
+	}
+}
+
+// UserContract registers and lists users.
+type UserContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *UserContract) Register(ctx contractapi.TransactionContextInterface, userId string, name string, email string) error {
+	return c.registry.RegisterUser(ctx, userId, name, email)
+}
+
+func (c *UserContract) GetAll(ctx contractapi.TransactionContextInterface) ([]User, error) {
+	return c.registry.GetAllUsers(ctx)
+}
+
+// PropertyContract registers, lists, restructures and looks up properties.
+type PropertyContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *PropertyContract) Register(ctx contractapi.TransactionContextInterface, propertyId string, title string, location string, size float64, ownerEmail string, price int64, currency string, isListed bool, boundaryHash string) error {
+	return c.registry.RegisterProperty(ctx, propertyId, title, location, size, ownerEmail, price, currency, isListed, boundaryHash)
+}
+
+func (c *PropertyContract) Get(ctx contractapi.TransactionContextInterface, propertyId string) (*PropertyDetail, error) {
+	return c.registry.GetProperty(ctx, propertyId)
+}
+
+func (c *PropertyContract) GetAll(ctx contractapi.TransactionContextInterface) ([]Property, error) {
+	return c.registry.GetAllProperty(ctx)
+}
+
+func (c *PropertyContract) GetHistory(ctx contractapi.TransactionContextInterface, propertyId string) (*PropertyHistory, error) {
+	return c.registry.GetPropertyHistory(ctx, propertyId)
+}
+
+func (c *PropertyContract) GetByOwner(ctx contractapi.TransactionContextInterface, ownerEmail string) ([]Property, error) {
+	return c.registry.GetPropertiesByOwner(ctx, ownerEmail)
+}
+
+func (c *PropertyContract) GetByLocation(ctx contractapi.TransactionContextInterface, location string) ([]Property, error) {
+	return c.registry.GetPropertiesByLocation(ctx, location)
+}
+
+func (c *PropertyContract) Query(ctx contractapi.TransactionContextInterface, conditions []QueryCondition, pageSize int32, bookmark string) (*PropertyPage, error) {
+	return c.registry.QueryProperties(ctx, conditions, pageSize, bookmark)
+}
+
+func (c *PropertyContract) GetOwners(ctx contractapi.TransactionContextInterface, propertyId string) ([]Owner, error) {
+	return c.registry.GetOwners(ctx, propertyId)
+}
+
+func (c *PropertyContract) SetConsentThreshold(ctx contractapi.TransactionContextInterface, propertyId string, threshold string, ownerEmail string) (string, error) {
+	return c.registry.SetConsentThreshold(ctx, propertyId, threshold, ownerEmail)
+}
+
+// List is the namespaced UpdateFlag.
+func (c *PropertyContract) List(ctx contractapi.TransactionContextInterface, propertyId string, ownerEmail string) (string, error) {
+	return c.registry.UpdateFlag(ctx, propertyId, ownerEmail)
+}
+
+func (c *PropertyContract) Reprice(ctx contractapi.TransactionContextInterface, propertyId string, price int64, ownerEmail string) (string, error) {
+	return c.registry.RepriceProperty(ctx, propertyId, price, ownerEmail)
+}
+
+func (c *PropertyContract) GetPrices(ctx contractapi.TransactionContextInterface, propertyId string) ([]PriceRecord, error) {
+	return c.registry.GetPriceHistory(ctx, propertyId)
+}
+
+func (c *PropertyContract) ListAsAgent(ctx contractapi.TransactionContextInterface, propertyId string, agentEmail string, principalEmail string) (string, error) {
+	return c.registry.UpdateFlagAsAgent(ctx, propertyId, agentEmail, principalEmail)
+}
+
+func (c *PropertyContract) ScheduleListing(ctx contractapi.TransactionContextInterface, propertyId string, ownerEmail string, startsAt string, endsAt string) (string, error) {
+	return c.registry.ScheduleListing(ctx, propertyId, ownerEmail, startsAt, endsAt)
+}
+
+func (c *PropertyContract) StartScheduledListings(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	return c.registry.StartScheduledListings(ctx)
+}
+
+func (c *PropertyContract) ExpireListings(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	return c.registry.ExpireListings(ctx)
+}
+
+func (c *PropertyContract) Subdivide(ctx contractapi.TransactionContextInterface, propertyId string, children []PropertyPart, ownerEmail string) (string, error) {
+	return c.registry.SubdivideProperty(ctx, propertyId, children, ownerEmail)
+}
+
+func (c *PropertyContract) Merge(ctx contractapi.TransactionContextInterface, propertyIds []string, merged PropertyPart, ownerEmail string) (string, error) {
+	return c.registry.MergeProperties(ctx, propertyIds, merged, ownerEmail)
+}
+
+func (c *PropertyContract) GetLineage(ctx contractapi.TransactionContextInterface, propertyId string) ([]LineageLink, error) {
+	return c.registry.GetLineage(ctx, propertyId)
+}
+
+func (c *PropertyContract) RebuildIndexes(ctx contractapi.TransactionContextInterface) error {
+	return c.registry.RebuildIndexes(ctx)
+}
+
+// TransferContract moves ownership, whether by sale, share transfer, reversal or succession, and
+// reports the resulting transactions.
+type TransferContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *TransferContract) Buy(ctx contractapi.TransactionContextInterface, propertyId string, buyerEmail string, sellerEmail string) (string, error) {
+	return c.registry.BuyProperty(ctx, propertyId, buyerEmail, sellerEmail)
+}
+
+func (c *TransferContract) BuyAsAgent(ctx contractapi.TransactionContextInterface, propertyId string, buyerEmail string, agentEmail string, principalEmail string) (string, error) {
+	return c.registry.BuyPropertyAsAgent(ctx, propertyId, buyerEmail, agentEmail, principalEmail)
+}
+
+func (c *TransferContract) Share(ctx contractapi.TransactionContextInterface, propertyId string, fromEmail string, toEmail string, share string) error {
+	return c.registry.TransferShare(ctx, propertyId, fromEmail, toEmail, share)
+}
+
+func (c *TransferContract) Reverse(ctx contractapi.TransactionContextInterface, transactionId string, justification string) (string, error) {
+	return c.registry.ReverseTransaction(ctx, transactionId, justification)
+}
+
+func (c *TransferContract) BySuccession(ctx contractapi.TransactionContextInterface, propertyId string, deceasedEmail string, heirs []Owner, probateHash string) (string, error) {
+	return c.registry.TransferBySuccession(ctx, propertyId, deceasedEmail, heirs, probateHash)
+}
+
+// GetAll returns every transaction, or only transactionId when it is not empty.
+func (c *TransferContract) GetAll(ctx contractapi.TransactionContextInterface, transactionId string) ([]Transaction, error) {
+	return c.registry.GetAllTransaction(ctx, transactionId)
+}
+
+func (c *TransferContract) GetByParty(ctx contractapi.TransactionContextInterface, email string) ([]Transaction, error) {
+	return c.registry.GetTransactionsByParty(ctx, email)
+}
+
+func (c *TransferContract) GetByProperty(ctx contractapi.TransactionContextInterface, propertyId string) ([]Transaction, error) {
+	return c.registry.GetTransactionsByProperty(ctx, propertyId)
+}
+
+func (c *TransferContract) Query(ctx contractapi.TransactionContextInterface, conditions []QueryCondition, pageSize int32, bookmark string) (*TransactionPage, error) {
+	return c.registry.QueryTransactions(ctx, conditions, pageSize, bookmark)
+}
+
+// LienContract registers and releases liens on properties.
+type LienContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *LienContract) Register(ctx contractapi.TransactionContextInterface, lienId string, propertyId string, amount int64, currency string, priority string) error {
+	return c.registry.RegisterLien(ctx, lienId, propertyId, amount, currency, priority)
+}
+
+func (c *LienContract) Release(ctx contractapi.TransactionContextInterface, propertyId string, lienId string) error {
+	return c.registry.ReleaseLien(ctx, propertyId, lienId)
+}
+
+func (c *LienContract) GetAll(ctx contractapi.TransactionContextInterface, propertyId string) ([]Lien, error) {
+	return c.registry.GetLiens(ctx, propertyId)
+}
+
+// LeaseContract creates, signs, renews and terminates leases.
+type LeaseContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *LeaseContract) Create(ctx contractapi.TransactionContextInterface, leaseId string, propertyId string, landlordEmail string, tenantEmail string, startDate string, endDate string, rent int64, deposit int64, currency string) error {
+	return c.registry.CreateLease(ctx, leaseId, propertyId, landlordEmail, tenantEmail, startDate, endDate, rent, deposit, currency)
+}
+
+func (c *LeaseContract) Sign(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string, signerEmail string) (string, error) {
+	return c.registry.SignLease(ctx, propertyId, leaseId, signerEmail)
+}
+
+func (c *LeaseContract) Renew(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string, endDate string, rent int64, signerEmail string) (string, error) {
+	return c.registry.RenewLease(ctx, propertyId, leaseId, endDate, rent, signerEmail)
+}
+
+func (c *LeaseContract) Terminate(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string, requesterEmail string) error {
+	return c.registry.TerminateLease(ctx, propertyId, leaseId, requesterEmail)
+}
+
+func (c *LeaseContract) GetAll(ctx contractapi.TransactionContextInterface, propertyId string) ([]Lease, error) {
+	return c.registry.GetLeases(ctx, propertyId)
+}
+
+// AuctionContract runs sealed-bid auctions of properties.
+type AuctionContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *AuctionContract) Create(ctx contractapi.TransactionContextInterface, auctionId string, propertyId string, sellerEmail string, deadline string) (string, error) {
+	return c.registry.CreateAuction(ctx, auctionId, propertyId, sellerEmail, deadline)
+}
+
+func (c *AuctionContract) SubmitBid(ctx contractapi.TransactionContextInterface, auctionId string, bidderEmail string) (string, error) {
+	return c.registry.SubmitBid(ctx, auctionId, bidderEmail)
+}
+
+func (c *AuctionContract) RevealBid(ctx contractapi.TransactionContextInterface, auctionId string, bidderEmail string, amount int64, salt string) error {
+	return c.registry.RevealBid(ctx, auctionId, bidderEmail, amount, salt)
+}
+
+func (c *AuctionContract) Close(ctx contractapi.TransactionContextInterface, auctionId string) (*AuctionResult, error) {
+	return c.registry.CloseAuction(ctx, auctionId)
+}
+
+func (c *AuctionContract) Get(ctx contractapi.TransactionContextInterface, auctionId string) (*AuctionResult, error) {
+	return c.registry.GetAuction(ctx, auctionId)
+}
+
+// TaxContract sets tax schedules and reports the duty collected on sales.
+type TaxContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *TaxContract) SetSchedule(ctx contractapi.TransactionContextInterface, jurisdiction string, currency string, brackets []TaxBracket) error {
+	return c.registry.SetTaxSchedule(ctx, jurisdiction, currency, brackets)
+}
+
+func (c *TaxContract) GetSchedule(ctx contractapi.TransactionContextInterface, jurisdiction string) ([]TaxBracket, error) {
+	return c.registry.GetTaxSchedule(ctx, jurisdiction)
+}
+
+func (c *TaxContract) GetReport(ctx contractapi.TransactionContextInterface, from string, to string) ([]TaxReportEntry, error) {
+	return c.registry.GetTaxReport(ctx, from, to)
+}
+
+// FreezeContract places and lifts legal holds on properties.
+type FreezeContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *FreezeContract) Freeze(ctx contractapi.TransactionContextInterface, propertyId string, freezeId string, reason string, reference string) error {
+	return c.registry.FreezeProperty(ctx, propertyId, freezeId, reason, reference)
+}
+
+func (c *FreezeContract) Unfreeze(ctx contractapi.TransactionContextInterface, propertyId string, freezeId string, reason string) error {
+	return c.registry.UnfreezeProperty(ctx, propertyId, freezeId, reason)
+}
+
+func (c *FreezeContract) GetAll(ctx contractapi.TransactionContextInterface, propertyId string) ([]Freeze, error) {
+	return c.registry.GetFreezes(ctx, propertyId)
+}
+
+// ValuationContract records appraisals of properties.
+type ValuationContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *ValuationContract) Submit(ctx contractapi.TransactionContextInterface, valuationId string, propertyId string, value int64, currency string, method string, reportHash string) error {
+	return c.registry.SubmitValuation(ctx, valuationId, propertyId, value, currency, method, reportHash)
+}
+
+func (c *ValuationContract) GetAll(ctx contractapi.TransactionContextInterface, propertyId string) ([]Valuation, error) {
+	return c.registry.GetValuations(ctx, propertyId)
+}
+
+func (c *ValuationContract) GetLatest(ctx contractapi.TransactionContextInterface, propertyId string) (*Valuation, error) {
+	return c.registry.GetLatestValuation(ctx, propertyId)
+}
+
+func (c *ValuationContract) SetBand(ctx contractapi.TransactionContextInterface, band string) error {
+	return c.registry.SetValuationBand(ctx, band)
+}
+
+// DelegationContract grants and revokes agents' authority to act for owners.
+type DelegationContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *DelegationContract) Grant(ctx contractapi.TransactionContextInterface, delegationId string, principalEmail string, agentEmail string, scope []string, propertyId string, expiry string) error {
+	return c.registry.GrantDelegation(ctx, delegationId, principalEmail, agentEmail, scope, propertyId, expiry)
+}
+
+func (c *DelegationContract) Revoke(ctx contractapi.TransactionContextInterface, principalEmail string, delegationId string) error {
+	return c.registry.RevokeDelegation(ctx, principalEmail, delegationId)
+}
+
+func (c *DelegationContract) GetAll(ctx contractapi.TransactionContextInterface, principalEmail string) ([]Delegation, error) {
+	return c.registry.GetDelegations(ctx, principalEmail)
+}
+
+func (c *DelegationContract) GetAgentActions(ctx contractapi.TransactionContextInterface, propertyId string) ([]AgentAction, error) {
+	return c.registry.GetAgentActions(ctx, propertyId)
+}
+
+// DocumentContract anchors the hashes of documents attached to properties.
+type DocumentContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *DocumentContract) Attach(ctx contractapi.TransactionContextInterface, propertyId string, documentId string, name string, hash string, mediaType string, uploaderEmail string) error {
+	return c.registry.AttachDocument(ctx, propertyId, documentId, name, hash, mediaType, uploaderEmail)
+}
+
+func (c *DocumentContract) Get(ctx contractapi.TransactionContextInterface, propertyId string, documentId string) (*Document, error) {
+	return c.registry.GetDocument(ctx, propertyId, documentId)
+}
+
+func (c *DocumentContract) GetAll(ctx contractapi.TransactionContextInterface, propertyId string) ([]Document, error) {
+	return c.registry.GetDocuments(ctx, propertyId)
+}
+
+// AuditContract reads the audit trail written for every transaction.
+type AuditContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *AuditContract) GetLog(ctx contractapi.TransactionContextInterface, from string, to string, actor string, pageSize int32, bookmark string) (*AuditPage, error) {
+	return c.registry.GetAuditLog(ctx, from, to, actor, pageSize, bookmark)
+}
+
+// RegistryContract reports on and maintains the registry as a whole.
+type RegistryContract struct {
+	contractapi.Contract
+	registry *RealEstate
+}
+
+func (c *RegistryContract) GetSummary(ctx contractapi.TransactionContextInterface) (*RegistrySummary, error) {
+	return c.registry.GetRegistrySummary(ctx)
+}
+
+func (c *RegistryContract) GetCurrencies(ctx contractapi.TransactionContextInterface) ([]Currency, error) {
+	return c.registry.GetCurrencies(ctx)
+}
+
+func (c *RegistryContract) RunMigration(ctx contractapi.TransactionContextInterface, asset string, batchSize int32, bookmark string) (*MigrationProgress, error) {
+	return c.registry.RunMigration(ctx, asset, batchSize, bookmark)
+}
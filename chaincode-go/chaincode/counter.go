@@ -0,0 +1,184 @@
+package chaincode
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Registry counters keep authoritative totals on the ledger so that a summary never has to scan
+// every record. A transaction collects its changes in the RegistryContext and, once the function
+// succeeded, writes its net change to each counter under a delta key of its own. Nothing reads the
+// counter while updating it, so concurrent transactions never conflict on a hot key. A counter is
+// its base value plus the sum of its deltas; RebuildIndexes recounts it, writes the base and
+// removes the deltas.
+const (
+	counterCompositeKey      = "counter~name"
+	counterDeltaCompositeKey = "counterdelta~name~txId"
+)
+
+const (
+	counterUsers       = "users"
+	counterProperties  = "properties"
+	counterListed      = "listed"
+	counterRetired     = "retired"
+	counterSales       = "sales"
+	counterReversals   = "reversals"
+	counterSuccessions = "successions"
+)
+
+var counterNames = []string{counterUsers, counterProperties, counterListed, counterRetired, counterSales, counterReversals, counterSuccessions}
+
+type counterUpdate struct {
+	value    int
+	absolute bool
+}
+
+type RegistrySummary struct {
+	Users       int `json:"users"`
+	Properties  int `json:"properties"`
+	Listed      int `json:"listed"`
+	Unlisted    int `json:"unlisted"`
+	Retired     int `json:"retired"`
+	Sales       int `json:"sales"`
+	Reversals   int `json:"reversals"`
+	Successions int `json:"successions"`
+}
+
+// GetRegistrySummary returns the registry's counters. Properties counts every registered parcel;
+// listed and unlisted only cover the ones that have not been retired.
+func (r *RealEstate) GetRegistrySummary(ctx contractapi.TransactionContextInterface) (*RegistrySummary, error) {
+	counts := make(map[string]int)
+	for _, name := range counterNames {
+		count, err := readCounter(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		counts[name] = count
+	}
+	return &RegistrySummary{
+		Users:       counts[counterUsers],
+		Properties:  counts[counterProperties],
+		Listed:      counts[counterListed],
+		Unlisted:    counts[counterProperties] - counts[counterRetired] - counts[counterListed],
+		Retired:     counts[counterRetired],
+		Sales:       counts[counterSales],
+		Reversals:   counts[counterReversals],
+		Successions: counts[counterSuccessions],
+	}, nil
+}
+
+// countListing adjusts the listed counter when a property's listing flag changes.
+func countListing(ctx contractapi.TransactionContextInterface, wasListed bool, isListed bool) error {
+	switch {
+	case isListed && !wasListed:
+		return addCount(ctx, counterListed, 1)
+	case wasListed && !isListed:
+		return addCount(ctx, counterListed, -1)
+	}
+	return nil
+}
+
+func addCount(ctx contractapi.TransactionContextInterface, name string, delta int) error {
+	registryCtx, ok := ctx.(*RegistryContext)
+	if !ok {
+		return errors.New("counters need the registry transaction context")
+	}
+	if registryCtx.counters == nil {
+		registryCtx.counters = make(map[string]counterUpdate)
+	}
+	update := registryCtx.counters[name]
+	update.value += delta
+	registryCtx.counters[name] = update
+	return nil
+}
+
+// setCount overwrites a counter, discarding the transaction's other changes to it and the deltas
+// of earlier transactions.
+func setCount(ctx contractapi.TransactionContextInterface, name string, value int) error {
+	registryCtx, ok := ctx.(*RegistryContext)
+	if !ok {
+		return errors.New("counters need the registry transaction context")
+	}
+	if registryCtx.counters == nil {
+		registryCtx.counters = make(map[string]counterUpdate)
+	}
+	registryCtx.counters[name] = counterUpdate{value: value, absolute: true}
+	return nil
+}
+
+func flushCounters(ctx *RegistryContext) error {
+	for name, update := range ctx.counters {
+		if update.absolute {
+			if err := deleteCounterDeltas(ctx, name); err != nil {
+				return err
+			}
+			if err := putKey(ctx, counterCompositeKey, []byte(strconv.Itoa(update.value)), "counter", name); err != nil {
+				return err
+			}
+			continue
+		}
+		if update.value == 0 {
+			continue
+		}
+		if err := putKey(ctx, counterDeltaCompositeKey, []byte(strconv.Itoa(update.value)), "counterdelta", name, ctx.GetStub().GetTxID()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCounter adds the deltas of a counter to its base value.
+func readCounter(ctx contractapi.TransactionContextInterface, name string) (int, error) {
+	counterKey, err := createKey(ctx, counterCompositeKey, "counter", name)
+	if err != nil {
+		return 0, err
+	}
+	data, err := ctx.GetStub().GetState(counterKey)
+	if err != nil {
+		return 0, errors.New("failed to read counter from world state")
+	}
+	value := 0
+	if data != nil {
+		value, err = strconv.Atoi(string(data))
+		if err != nil {
+			return 0, err
+		}
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(counterDeltaCompositeKey, []string{"counterdelta", name})
+	if err != nil {
+		return 0, errors.New("failed to read counter deltas from world state")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return 0, errors.New("failed to iterate over counter deltas")
+		}
+		delta, err := strconv.Atoi(string(queryResponse.Value))
+		if err != nil {
+			return 0, err
+		}
+		value += delta
+	}
+	return value, nil
+}
+
+func deleteCounterDeltas(ctx contractapi.TransactionContextInterface, name string) error {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(counterDeltaCompositeKey, []string{"counterdelta", name})
+	if err != nil {
+		return errors.New("failed to read counter deltas from world state")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return errors.New("failed to iterate over counter deltas")
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return errors.New("failed to delete counter delta")
+		}
+	}
+	return nil
+}
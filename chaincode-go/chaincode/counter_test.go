@@ -0,0 +1,165 @@
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounters(t *testing.T) {
+	stub := shimtest.NewMockStub("realestate", nil)
+	// Each step is one transaction: its changes are collected in a fresh context and flushed.
+	commit := func(txId string, change func(ctx *RegistryContext) error) {
+		ctx := new(RegistryContext)
+		ctx.SetStub(stub)
+		stub.MockTransactionStart(txId)
+		require.NoError(t, change(ctx))
+		require.NoError(t, flushCounters(ctx))
+		stub.MockTransactionEnd(txId)
+	}
+	read := func(name string) int {
+		ctx := new(RegistryContext)
+		ctx.SetStub(stub)
+		count, err := readCounter(ctx, name)
+		require.NoError(t, err)
+		return count
+	}
+	deltas := func(name string) int {
+		iterator, err := stub.GetStateByPartialCompositeKey(counterDeltaCompositeKey, []string{"counterdelta", name})
+		require.NoError(t, err)
+		defer iterator.Close()
+		count := 0
+		for iterator.HasNext() {
+			_, err := iterator.Next()
+			require.NoError(t, err)
+			count++
+		}
+		return count
+	}
+
+	tests := []struct {
+		name       string
+		change     func(ctx *RegistryContext) error
+		wantCount  int
+		wantDeltas int
+	}{
+		{
+			name: "changes in one transaction are summed into one delta",
+			change: func(ctx *RegistryContext) error {
+				if err := addCount(ctx, counterProperties, 2); err != nil {
+					return err
+				}
+				return addCount(ctx, counterProperties, 1)
+			},
+			wantCount:  3,
+			wantDeltas: 1,
+		},
+		{
+			name:       "each transaction writes its own delta",
+			change:     func(ctx *RegistryContext) error { return addCount(ctx, counterProperties, -1) },
+			wantCount:  2,
+			wantDeltas: 2,
+		},
+		{
+			name: "a net change of zero writes nothing",
+			change: func(ctx *RegistryContext) error {
+				if err := addCount(ctx, counterProperties, 1); err != nil {
+					return err
+				}
+				return addCount(ctx, counterProperties, -1)
+			},
+			wantCount:  2,
+			wantDeltas: 2,
+		},
+		{
+			name:       "setting the counter replaces the deltas with a base",
+			change:     func(ctx *RegistryContext) error { return setCount(ctx, counterProperties, 7) },
+			wantCount:  7,
+			wantDeltas: 0,
+		},
+		{
+			name:       "deltas add to the base",
+			change:     func(ctx *RegistryContext) error { return addCount(ctx, counterProperties, 1) },
+			wantCount:  8,
+			wantDeltas: 1,
+		},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commit(fmt.Sprintf("tx%d", i), test.change)
+			assert.Equal(t, test.wantCount, read(counterProperties))
+			assert.Equal(t, test.wantDeltas, deltas(counterProperties))
+		})
+	}
+	assert.Equal(t, 0, read(counterListed), "other counters are untouched")
+}
+
+func TestRegistrySummary(t *testing.T) {
+	stub := shimtest.NewMockStub("realestate", nil)
+	ctx := new(RegistryContext)
+	ctx.SetStub(stub)
+	stub.MockTransactionStart("setup")
+	for name, count := range map[string]int{counterProperties: 10, counterListed: 4, counterRetired: 2, counterSales: 3} {
+		require.NoError(t, addCount(ctx, name, count))
+	}
+	require.NoError(t, flushCounters(ctx))
+	stub.MockTransactionEnd("setup")
+
+	summary, err := new(RealEstate).GetRegistrySummary(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &RegistrySummary{Properties: 10, Listed: 4, Unlisted: 4, Retired: 2, Sales: 3}, summary)
+}
+
+func TestCountersFollowTransactions(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	registrar := testClient{email: "registrar@example.com", role: roleRegistrar}
+	r := new(RealEstate)
+
+	stub.TransientMap = map[string][]byte{userTransientKey: []byte(`{"address":"1 Kenyatta Avenue"}`)}
+	stub.mustInvoke(buyer, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterUser(ctx, "u1", "Buyer", buyer.email)
+	})
+	stub.TransientMap = nil
+	for propertyId, isListed := range map[string]bool{"p1": true, "p2": true, "p3": false} {
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Plot", "Machakos", 10, owner.email, 100000, "USD", isListed, "")
+		})
+	}
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.BuyProperty(ctx, "p1", buyer.email, owner.email)
+		return err
+	})
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		children := []PropertyPart{
+			{Id: "p3a", Title: "Plot A", Location: "Machakos", Size: 4, Price: 40000, Currency: "USD"},
+			{Id: "p3b", Title: "Plot B", Location: "Machakos", Size: 6, Price: 60000, Currency: "USD"},
+		}
+		_, err := r.SubdivideProperty(ctx, "p3", children, owner.email)
+		return err
+	})
+
+	summary := func() *RegistrySummary {
+		var summary *RegistrySummary
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			summary, err = r.GetRegistrySummary(ctx)
+			return err
+		})
+		return summary
+	}
+	// p1 was unlisted by its sale; p3 was retired and its children start unlisted.
+	want := &RegistrySummary{Users: 1, Properties: 5, Listed: 1, Unlisted: 3, Retired: 1, Sales: 1}
+	assert.Equal(t, want, summary())
+
+	// Recounting from the records agrees with the counters kept transaction by transaction.
+	stub.mustInvoke(registrar, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RebuildIndexes(ctx)
+	})
+	assert.Equal(t, want, summary())
+}
@@ -0,0 +1,340 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Delegation is a power of attorney letting an agent act for a principal on one property, or on
+// all of the principal's properties when PropertyId is DelegationAllProperties.
+type Delegation struct {
+	Id             string   `json:"id"`
+	PrincipalEmail string   `json:"principal_email"`
+	AgentEmail     string   `json:"agent_email"`
+	Scope          []string `json:"scope" metadata:",optional"`
+	PropertyId     string   `json:"property_id"`
+	Expiry         string   `json:"expiry"`
+	Status         string   `json:"status"`
+}
+
+// AgentAction records a listing or sale an agent carried out on a principal's behalf.
+type AgentAction struct {
+	PropertyId     string `json:"property_id"`
+	TransactionId  string `json:"transaction_id"`
+	AgentEmail     string `json:"agent_email"`
+	PrincipalEmail string `json:"principal_email"`
+	Action         string `json:"action"`
+	Result         string `json:"result"`
+	Date           string `json:"date"`
+}
+
+const delegationCompositeKey = "delegation~principalEmail~delegationId~agentEmail~scope~propertyId~expiry~status"
+const agentActionCompositeKey = "agentaction~propertyId~transactionId~agentEmail~principalEmail~action~result~date"
+
+const (
+	DelegationActive  = "Active"
+	DelegationRevoked = "Revoked"
+
+	DelegationScopeList = "list"
+	DelegationScopeSell = "sell"
+
+	DelegationAllProperties = "*"
+)
+
+var delegationScopes = map[string]bool{
+	DelegationScopeList: true,
+	DelegationScopeSell: true,
+}
+
+func (r *RealEstate) GrantDelegation(ctx contractapi.TransactionContextInterface, delegationId string, principalEmail string, agentEmail string, scope []string, propertyId string, expiry string) error {
+	if agentEmail == "" || agentEmail == principalEmail {
+		return invalidArgument("agent must be someone other than the principal")
+	}
+	if len(scope) == 0 {
+		return invalidArgument("a delegation needs at least one allowed action")
+	}
+	for _, action := range scope {
+		if !delegationScopes[action] {
+			return invalidArgument("unknown delegated action %q", action)
+		}
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return invalidArgument("invalid expiry: %s", expiry)
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !expiresAt.After(now) {
+		return invalidArgument("expiry must be in the future")
+	}
+	if propertyId != DelegationAllProperties {
+		property, _, err := r.getProperty(ctx, propertyId)
+		if err != nil {
+			return err
+		}
+		if !ownsShare(property, principalEmail) {
+			return permissionDenied("only an owner of the property can delegate it")
+		}
+	}
+	if _, _, err := r.getDelegation(ctx, principalEmail, delegationId); err == nil {
+		return alreadyExists("delegation already exists")
+	}
+	return r.putDelegation(ctx, Delegation{
+		Id:             delegationId,
+		PrincipalEmail: principalEmail,
+		AgentEmail:     agentEmail,
+		Scope:          scope,
+		PropertyId:     propertyId,
+		Expiry:         expiresAt.UTC().Format(time.RFC3339),
+		Status:         DelegationActive,
+	})
+}
+
+func (r *RealEstate) RevokeDelegation(ctx contractapi.TransactionContextInterface, principalEmail string, delegationId string) error {
+	delegation, delegationKey, err := r.getDelegation(ctx, principalEmail, delegationId)
+	if err != nil {
+		return err
+	}
+	if delegation.Status != DelegationActive {
+		return invalidArgument("delegation is already revoked")
+	}
+	err = ctx.GetStub().DelState(delegationKey)
+	if err != nil {
+		return errors.New("failed to delete old delegation state")
+	}
+	delegation.Status = DelegationRevoked
+	return r.putDelegation(ctx, *delegation)
+}
+
+func (r *RealEstate) GetDelegations(ctx contractapi.TransactionContextInterface, principalEmail string) ([]Delegation, error) {
+	var delegations []Delegation
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(delegationCompositeKey, []string{"delegation", principalEmail})
+	if err != nil {
+		return nil, errors.New("failed to get delegations")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over delegations")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		delegations = append(delegations, delegationFromKeyParts(keyParts))
+	}
+	return delegations, nil
+}
+
+// UpdateFlagAsAgent lists a property on behalf of principalEmail under a delegation that
+// allows listing. The listing counts as the principal's consent.
+func (r *RealEstate) UpdateFlagAsAgent(ctx contractapi.TransactionContextInterface, propertyId string, agentEmail string, principalEmail string) (string, error) {
+	if err := r.checkDelegation(ctx, propertyId, agentEmail, principalEmail, DelegationScopeList); err != nil {
+		return "", err
+	}
+	result, err := r.UpdateFlag(ctx, propertyId, principalEmail)
+	if err != nil {
+		return "", err
+	}
+	if err := r.recordAgentAction(ctx, propertyId, agentEmail, principalEmail, DelegationScopeList, result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// BuyPropertyAsAgent sells a property on behalf of principalEmail under a delegation that
+// allows selling. It returns the transaction id, or an empty string while consent is pending.
+func (r *RealEstate) BuyPropertyAsAgent(ctx contractapi.TransactionContextInterface, propertyId string, buyerEmail string, agentEmail string, principalEmail string) (string, error) {
+	if err := r.checkDelegation(ctx, propertyId, agentEmail, principalEmail, DelegationScopeSell); err != nil {
+		return "", err
+	}
+	transactionId, err := r.BuyProperty(ctx, propertyId, buyerEmail, principalEmail)
+	if err != nil {
+		return "", err
+	}
+	result := TransactionCompleted
+	if transactionId == "" {
+		result = ConsentPending
+	}
+	if err := r.recordAgentAction(ctx, propertyId, agentEmail, principalEmail, DelegationScopeSell, result); err != nil {
+		return "", err
+	}
+	if transactionId != "" {
+		err := r.updateTransactionDetail(ctx, transactionId, func(detail *transactionDetail) {
+			detail.AgentEmail = agentEmail
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return transactionId, nil
+}
+
+func (r *RealEstate) GetAgentActions(ctx contractapi.TransactionContextInterface, propertyId string) ([]AgentAction, error) {
+	var actions []AgentAction
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(agentActionCompositeKey, []string{"agentaction", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get agent actions")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over agent actions")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		actions = append(actions, AgentAction{
+			PropertyId:     keyParts[1],
+			TransactionId:  keyParts[2],
+			AgentEmail:     keyParts[3],
+			PrincipalEmail: keyParts[4],
+			Action:         keyParts[5],
+			Result:         keyParts[6],
+			Date:           keyParts[7],
+		})
+	}
+	return actions, nil
+}
+
+// checkDelegation makes sure the principal has an active, unexpired delegation allowing the agent
+// to take the action on the property.
+func (r *RealEstate) checkDelegation(ctx contractapi.TransactionContextInterface, propertyId string, agentEmail string, principalEmail string, action string) error {
+	delegations, err := r.GetDelegations(ctx, principalEmail)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	for _, delegation := range delegations {
+		if delegation.Status != DelegationActive || delegation.AgentEmail != agentEmail {
+			continue
+		}
+		if delegation.PropertyId != propertyId && delegation.PropertyId != DelegationAllProperties {
+			continue
+		}
+		expiresAt, err := time.Parse(time.RFC3339, delegation.Expiry)
+		if err != nil || !expiresAt.After(now) {
+			continue
+		}
+		for _, allowed := range delegation.Scope {
+			if allowed == action {
+				return nil
+			}
+		}
+	}
+	return permissionDenied("%s holds no delegation from %s to %s this property", agentEmail, principalEmail, action)
+}
+
+// getSaleAgents maps the ids of sales carried out by an agent to the agent's email. Only
+// RebuildIndexes scans them, to backfill transaction details.
+func (r *RealEstate) getSaleAgents(ctx contractapi.TransactionContextInterface) (map[string]string, error) {
+	agents := make(map[string]string)
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(agentActionCompositeKey, []string{"agentaction"})
+	if err != nil {
+		return nil, errors.New("failed to get agent actions")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over agent actions")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		if keyParts[5] == DelegationScopeSell {
+			agents[keyParts[2]] = keyParts[3]
+		}
+	}
+	return agents, nil
+}
+
+func (r *RealEstate) recordAgentAction(ctx contractapi.TransactionContextInterface, propertyId string, agentEmail string, principalEmail string, action string, result string) error {
+	date, err := lineageDate(ctx)
+	if err != nil {
+		return err
+	}
+	actionKey, err := ctx.GetStub().CreateCompositeKey(agentActionCompositeKey, []string{"agentaction", propertyId, ctx.GetStub().GetTxID(), agentEmail, principalEmail, action, result, date})
+	if err != nil {
+		log.Println("failed to create composite key for agent action")
+		return errors.New("failed to create composite key for agent action")
+	}
+	err = ctx.GetStub().PutState(actionKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put agent action in world state")
+		return errors.New("failed to put agent action in world state")
+	}
+	return nil
+}
+
+func (r *RealEstate) getDelegation(ctx contractapi.TransactionContextInterface, principalEmail string, delegationId string) (*Delegation, string, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(delegationCompositeKey, []string{"delegation", principalEmail, delegationId})
+	if err != nil {
+		return nil, "", errors.New("failed to read delegation from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return nil, "", notFound("delegation %s not found", delegationId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return nil, "", errors.New("failed to iterate over delegations")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return nil, "", fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	delegation := delegationFromKeyParts(keyParts)
+	return &delegation, queryResponse.Key, nil
+}
+
+func (r *RealEstate) putDelegation(ctx contractapi.TransactionContextInterface, delegation Delegation) error {
+	delegationKey, err := ctx.GetStub().CreateCompositeKey(delegationCompositeKey, []string{"delegation", delegation.PrincipalEmail, delegation.Id, delegation.AgentEmail, strings.Join(delegation.Scope, ","), delegation.PropertyId, delegation.Expiry, delegation.Status})
+	if err != nil {
+		log.Println("failed to create composite key for delegation")
+		return errors.New("failed to create composite key for delegation")
+	}
+	err = ctx.GetStub().PutState(delegationKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put delegation in world state")
+		return errors.New("failed to put delegation in world state")
+	}
+	return nil
+}
+
+func delegationFromKeyParts(keyParts []string) Delegation {
+	return Delegation{
+		Id:             keyParts[2],
+		PrincipalEmail: keyParts[1],
+		AgentEmail:     keyParts[3],
+		Scope:          strings.Split(keyParts[4], ","),
+		PropertyId:     keyParts[5],
+		Expiry:         keyParts[6],
+		Status:         keyParts[7],
+	}
+}
+
+func ownsShare(property *Property, email string) bool {
+	for _, owner := range property.Owners {
+		if owner.Email == email {
+			return true
+		}
+	}
+	return false
+}
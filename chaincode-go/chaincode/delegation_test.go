@@ -0,0 +1,86 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerOfAttorney(t *testing.T) {
+	stub := newTestStub(t)
+	principal := testClient{email: "principal@example.com"}
+	agent := testClient{email: "agent@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	r := new(RealEstate)
+	now := time.Now().UTC().Truncate(time.Second)
+	expiry := now.Add(24 * time.Hour).Format(time.RFC3339)
+
+	for _, propertyId := range []string{"p1", "p2"} {
+		stub.mustInvoke(principal, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Plot", "Thika", 10, principal.email, 100000, "USD", false, "")
+		})
+	}
+	grant := func(delegationId string, scope []string, propertyId string) error {
+		return stub.invoke(principal, now, func(ctx contractapi.TransactionContextInterface) error {
+			return r.GrantDelegation(ctx, delegationId, principal.email, agent.email, scope, propertyId, expiry)
+		})
+	}
+	list := func(propertyId string, at time.Time) error {
+		return stub.invoke(agent, at, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := r.UpdateFlagAsAgent(ctx, propertyId, agent.email, principal.email)
+			return err
+		})
+	}
+	sell := func(propertyId string) (string, error) {
+		var transactionId string
+		err := stub.invoke(agent, now, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			transactionId, err = r.BuyPropertyAsAgent(ctx, propertyId, buyer.email, agent.email, principal.email)
+			return err
+		})
+		return transactionId, err
+	}
+
+	assert.ErrorContains(t, grant("d0", []string{"mortgage"}, "p1"), `unknown delegated action "mortgage"`)
+	require.NoError(t, grant("d1", []string{DelegationScopeList}, "p1"))
+	assert.ErrorContains(t, grant("d1", []string{DelegationScopeList}, "p1"), ErrAlreadyExists)
+
+	_, err := sell("p1")
+	assert.ErrorContains(t, err, ErrPermissionDenied, "d1 only allows listing")
+	assert.ErrorContains(t, list("p2", now), ErrPermissionDenied, "d1 only covers p1")
+	require.NoError(t, list("p1", now))
+
+	require.NoError(t, grant("d2", []string{DelegationScopeSell}, DelegationAllProperties))
+	transactionId, err := sell("p1")
+	require.NoError(t, err)
+	require.NotEmpty(t, transactionId)
+
+	var transactions []Transaction
+	var actions []AgentAction
+	stub.mustInvoke(principal, func(ctx contractapi.TransactionContextInterface) error {
+		if transactions, err = r.GetTransactionsByProperty(ctx, "p1"); err != nil {
+			return err
+		}
+		actions, err = r.GetAgentActions(ctx, "p1")
+		return err
+	})
+	require.Len(t, transactions, 1)
+	assert.Equal(t, agent.email, transactions[0].AgentEmail)
+	assert.Equal(t, buyer.email, transactions[0].BuyerEmail)
+	require.Len(t, actions, 2)
+	for _, action := range actions {
+		assert.Equal(t, agent.email, action.AgentEmail)
+		assert.Equal(t, principal.email, action.PrincipalEmail)
+	}
+
+	// Delegations lapse at their expiry and end for good when revoked.
+	require.NoError(t, grant("d3", []string{DelegationScopeList}, "p2"))
+	assert.ErrorContains(t, list("p2", now.Add(25*time.Hour)), ErrPermissionDenied)
+	stub.mustInvoke(principal, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RevokeDelegation(ctx, principal.email, "d3")
+	})
+	assert.ErrorContains(t, list("p2", now), ErrPermissionDenied)
+}
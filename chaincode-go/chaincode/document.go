@@ -0,0 +1,109 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Document anchors a file kept in the off-chain blob store by its SHA-256 hash.
+type Document struct {
+	Id            string `json:"id"`
+	PropertyId    string `json:"property_id"`
+	Name          string `json:"name"`
+	Hash          string `json:"hash"`
+	MediaType     string `json:"media_type"`
+	UploaderEmail string `json:"uploader_email"`
+	UploadedAt    string `json:"uploaded_at"`
+}
+
+const documentCompositeKey = "document~propertyId~documentId~name~hash~mediaType~uploaderEmail~uploadedAt"
+
+// AttachDocument anchors a document uploaded by uploaderEmail, who must own a share of the
+// property. Admins attach documents to any property, recorded under their own identity.
+func (r *RealEstate) AttachDocument(ctx contractapi.TransactionContextInterface, propertyId string, documentId string, name string, hash string, mediaType string, uploaderEmail string) error {
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	role, err := clientRole(ctx)
+	if err != nil {
+		return err
+	}
+	if role == roleAdmin {
+		if uploaderEmail, err = clientName(ctx); err != nil {
+			return err
+		}
+	} else if !ownsShare(property, uploaderEmail) {
+		return permissionDenied("only an owner of the property can attach documents")
+	}
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != 32 {
+		return invalidArgument("hash must be a hex encoded SHA-256 digest")
+	}
+	if _, err := r.GetDocument(ctx, propertyId, documentId); err == nil {
+		return alreadyExists("document already exists")
+	}
+	uploadedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	documentKey, err := ctx.GetStub().CreateCompositeKey(documentCompositeKey, []string{"document", propertyId, documentId, name, hash, mediaType, uploaderEmail, uploadedAt.UTC().Format(transactionDateLayout)})
+	if err != nil {
+		log.Println("failed to create composite key for document")
+		return errors.New("failed to create composite key for document")
+	}
+	err = ctx.GetStub().PutState(documentKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put document in world state")
+		return errors.New("failed to put document in world state")
+	}
+	return nil
+}
+
+func (r *RealEstate) GetDocument(ctx contractapi.TransactionContextInterface, propertyId string, documentId string) (*Document, error) {
+	documents, err := r.getDocuments(ctx, []string{"document", propertyId, documentId})
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return nil, notFound("document %s not found", documentId)
+	}
+	return &documents[0], nil
+}
+
+func (r *RealEstate) GetDocuments(ctx contractapi.TransactionContextInterface, propertyId string) ([]Document, error) {
+	return r.getDocuments(ctx, []string{"document", propertyId})
+}
+
+func (r *RealEstate) getDocuments(ctx contractapi.TransactionContextInterface, attributes []string) ([]Document, error) {
+	var documents []Document
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(documentCompositeKey, attributes)
+	if err != nil {
+		return nil, errors.New("failed to get documents")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over documents")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		documents = append(documents, Document{
+			Id:            keyParts[2],
+			PropertyId:    keyParts[1],
+			Name:          keyParts[3],
+			Hash:          keyParts[4],
+			MediaType:     keyParts[5],
+			UploaderEmail: keyParts[6],
+			UploadedAt:    keyParts[7],
+		})
+	}
+	return documents, nil
+}
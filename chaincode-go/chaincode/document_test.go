@@ -0,0 +1,49 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachDocument(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	stranger := testClient{email: "stranger@example.com"}
+	admin := testClient{email: "admin@example.com", role: roleAdmin}
+	r := new(RealEstate)
+
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Nairobi", 10, owner.email, 100, "USD", false, "")
+	})
+	digest := sha256.Sum256([]byte("title deed"))
+	hash := hex.EncodeToString(digest[:])
+	attach := func(client testClient, documentId string, hash string, uploaderEmail string) error {
+		return stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			return r.AttachDocument(ctx, "p1", documentId, "deed.pdf", hash, "application/pdf", uploaderEmail)
+		})
+	}
+
+	assert.ErrorContains(t, attach(owner, "d1", "not-a-digest", owner.email), ErrInvalidArgument)
+	assert.ErrorContains(t, attach(stranger, "d1", hash, stranger.email), ErrPermissionDenied)
+	require.NoError(t, attach(owner, "d1", hash, owner.email))
+	assert.ErrorContains(t, attach(owner, "d1", hash, owner.email), ErrAlreadyExists)
+	// An admin is recorded as the uploader whoever they name.
+	require.NoError(t, attach(admin, "d2", hash, owner.email))
+
+	var documents []Document
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		documents, err = r.GetDocuments(ctx, "p1")
+		return err
+	})
+	require.Len(t, documents, 2)
+	assert.Equal(t, owner.email, documents[0].UploaderEmail)
+	assert.Equal(t, admin.email, documents[1].UploaderEmail)
+	assert.Equal(t, hash, documents[1].Hash)
+}
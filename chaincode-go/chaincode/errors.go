@@ -0,0 +1,60 @@
+package chaincode
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Error codes prefix the messages of errors returned to clients as "CODE: message", so that
+// clients can tell a rejected input from a missing record without parsing the text after it.
+const (
+	ErrInvalidArgument  = "INVALID_ARGUMENT"
+	ErrNotFound         = "NOT_FOUND"
+	ErrAlreadyExists    = "ALREADY_EXISTS"
+	ErrPermissionDenied = "PERMISSION_DENIED"
+)
+
+// Error is a chaincode error carrying one of the error codes.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func invalidArgument(format string, args ...interface{}) error {
+	return &Error{Code: ErrInvalidArgument, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &Error{Code: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func alreadyExists(format string, args ...interface{}) error {
+	return &Error{Code: ErrAlreadyExists, Message: fmt.Sprintf(format, args...)}
+}
+
+func permissionDenied(format string, args ...interface{}) error {
+	return &Error{Code: ErrPermissionDenied, Message: fmt.Sprintf(format, args...)}
+}
+
+// requireFields reports the first of the named values that is blank.
+func requireFields(fields ...string) error {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if strings.TrimSpace(fields[i+1]) == "" {
+			return invalidArgument("%s is required", fields[i])
+		}
+	}
+	return nil
+}
+
+func validateEmail(field string, email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return invalidArgument("%s is not a valid email address: %q", field, email)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Freeze is a court or registrar hold on a property. The authority is the client that placed it,
+// as named by its certificate. Released freezes are kept as an audit trail.
+type Freeze struct {
+	Id             string `json:"id"`
+	PropertyId     string `json:"property_id"`
+	AuthorityEmail string `json:"authority_email"`
+	AuthorityRole  string `json:"authority_role"`
+	Reason         string `json:"reason"`
+	Reference      string `json:"reference"`
+	FrozenAt       string `json:"frozen_at"`
+	Status         string `json:"status"`
+	ReleasedBy     string `json:"released_by"`
+	ReleasedAt     string `json:"released_at"`
+	ReleaseReason  string `json:"release_reason"`
+}
+
+const freezeCompositeKey = "freeze~propertyId~freezeId~authorityEmail~authorityRole~reason~reference~frozenAt~status~releasedBy~releasedAt~releaseReason"
+
+const (
+	FreezeActive   = "Active"
+	FreezeReleased = "Released"
+)
+
+func (r *RealEstate) FreezeProperty(ctx contractapi.TransactionContextInterface, propertyId string, freezeId string, reason string, reference string) error {
+	authorityRole, err := requireRole(ctx, "freeze properties", roleRegistrar, roleCourt)
+	if err != nil {
+		return err
+	}
+	authorityEmail, err := clientName(ctx)
+	if err != nil {
+		return err
+	}
+	if _, _, err := r.getProperty(ctx, propertyId); err != nil {
+		return err
+	}
+	if reason == "" || reference == "" {
+		return invalidArgument("a freeze needs a reason and a reference number")
+	}
+	freezes, err := r.GetFreezes(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	for _, freeze := range freezes {
+		if freeze.Id == freezeId {
+			return alreadyExists("freeze already exists")
+		}
+		if freeze.Status == FreezeActive && freeze.Reference == reference {
+			return invalidArgument("property is already frozen under reference %s", reference)
+		}
+	}
+	frozenAt, err := lineageDate(ctx)
+	if err != nil {
+		return err
+	}
+	return r.putFreeze(ctx, Freeze{
+		Id:             freezeId,
+		PropertyId:     propertyId,
+		AuthorityEmail: authorityEmail,
+		AuthorityRole:  authorityRole,
+		Reason:         reason,
+		Reference:      reference,
+		FrozenAt:       frozenAt,
+		Status:         FreezeActive,
+	})
+}
+
+func (r *RealEstate) UnfreezeProperty(ctx contractapi.TransactionContextInterface, propertyId string, freezeId string, reason string) error {
+	if _, err := requireRole(ctx, "unfreeze properties", roleRegistrar, roleCourt); err != nil {
+		return err
+	}
+	authorityEmail, err := clientName(ctx)
+	if err != nil {
+		return err
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(freezeCompositeKey, []string{"freeze", propertyId, freezeId})
+	if err != nil {
+		return errors.New("failed to read freeze from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return notFound("freeze %s not found", freezeId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return errors.New("failed to iterate over freezes")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	freeze := freezeFromKeyParts(keyParts)
+	if freeze.Status != FreezeActive {
+		return invalidArgument("freeze is already released")
+	}
+	if reason == "" {
+		return invalidArgument("releasing a freeze needs a reason")
+	}
+	releasedAt, err := lineageDate(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(queryResponse.Key)
+	if err != nil {
+		return errors.New("failed to delete old freeze state")
+	}
+	freeze.Status = FreezeReleased
+	freeze.ReleasedBy = authorityEmail
+	freeze.ReleasedAt = releasedAt
+	freeze.ReleaseReason = reason
+	return r.putFreeze(ctx, freeze)
+}
+
+func (r *RealEstate) GetFreezes(ctx contractapi.TransactionContextInterface, propertyId string) ([]Freeze, error) {
+	var freezes []Freeze
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(freezeCompositeKey, []string{"freeze", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get freezes")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over freezes")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		freezes = append(freezes, freezeFromKeyParts(keyParts))
+	}
+	return freezes, nil
+}
+
+// ensureNotFrozen blocks listing, selling and encumbering a property under an active freeze.
+func (r *RealEstate) ensureNotFrozen(ctx contractapi.TransactionContextInterface, propertyId string) error {
+	frozen, err := r.isFrozen(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	if frozen {
+		return invalidArgument("property is frozen by a legal hold")
+	}
+	return nil
+}
+
+func (r *RealEstate) isFrozen(ctx contractapi.TransactionContextInterface, propertyId string) (bool, error) {
+	freezes, err := r.GetFreezes(ctx, propertyId)
+	if err != nil {
+		return false, err
+	}
+	for _, freeze := range freezes {
+		if freeze.Status == FreezeActive {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RealEstate) putFreeze(ctx contractapi.TransactionContextInterface, freeze Freeze) error {
+	freezeKey, err := ctx.GetStub().CreateCompositeKey(freezeCompositeKey, []string{"freeze", freeze.PropertyId, freeze.Id, freeze.AuthorityEmail, freeze.AuthorityRole, freeze.Reason, freeze.Reference, freeze.FrozenAt, freeze.Status, freeze.ReleasedBy, freeze.ReleasedAt, freeze.ReleaseReason})
+	if err != nil {
+		log.Println("failed to create composite key for freeze")
+		return errors.New("failed to create composite key for freeze")
+	}
+	err = ctx.GetStub().PutState(freezeKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put freeze in world state")
+		return errors.New("failed to put freeze in world state")
+	}
+	return nil
+}
+
+func freezeFromKeyParts(keyParts []string) Freeze {
+	return Freeze{
+		Id:             keyParts[2],
+		PropertyId:     keyParts[1],
+		AuthorityEmail: keyParts[3],
+		AuthorityRole:  keyParts[4],
+		Reason:         keyParts[5],
+		Reference:      keyParts[6],
+		FrozenAt:       keyParts[7],
+		Status:         keyParts[8],
+		ReleasedBy:     keyParts[9],
+		ReleasedAt:     keyParts[10],
+		ReleaseReason:  keyParts[11],
+	}
+}
@@ -0,0 +1,83 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalHolds(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	court := testClient{email: "clerk@court.example.com", role: roleCourt}
+	registrar := testClient{email: "registrar@example.com", role: roleRegistrar}
+	lender := testClient{email: "bank@example.com", role: roleLender}
+	r := new(RealEstate)
+
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Kisumu", 10, owner.email, 100000, "USD", true, "")
+	})
+	freeze := func(client testClient, freezeId string, reference string) error {
+		return stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			return r.FreezeProperty(ctx, "p1", freezeId, "boundary dispute", reference)
+		})
+	}
+	unfreeze := func(client testClient, freezeId string) error {
+		return stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			return r.UnfreezeProperty(ctx, "p1", freezeId, "settled")
+		})
+	}
+	actions := map[string]func() error{
+		"sale": func() error {
+			return stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+				_, err := r.BuyProperty(ctx, "p1", buyer.email, owner.email)
+				return err
+			})
+		},
+		"share transfer": func() error {
+			return stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+				return r.TransferShare(ctx, "p1", owner.email, buyer.email, "10")
+			})
+		},
+		"lien": func() error {
+			return stub.invoke(lender, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+				return r.RegisterLien(ctx, "l1", "p1", 5000, "USD", "1")
+			})
+		},
+	}
+
+	assert.ErrorContains(t, freeze(owner, "f1", "HC-1/2026"), ErrPermissionDenied)
+	require.NoError(t, freeze(court, "f1", "HC-1/2026"))
+	assert.ErrorContains(t, freeze(registrar, "f2", "HC-1/2026"), "already frozen under reference HC-1/2026")
+	require.NoError(t, freeze(registrar, "f2", "REG-7"))
+	for name, action := range actions {
+		assert.ErrorContains(t, action(), "frozen", name)
+	}
+
+	// The property stays frozen until every hold on it is released.
+	require.NoError(t, unfreeze(court, "f1"))
+	assert.ErrorContains(t, unfreeze(court, "f1"), "already released")
+	assert.ErrorContains(t, actions["sale"](), "frozen")
+	require.NoError(t, unfreeze(registrar, "f2"))
+
+	var freezes []Freeze
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		freezes, err = r.GetFreezes(ctx, "p1")
+		return err
+	})
+	require.Len(t, freezes, 2)
+	for _, freeze := range freezes {
+		assert.Equal(t, FreezeReleased, freeze.Status)
+		assert.Equal(t, "settled", freeze.ReleaseReason)
+	}
+	assert.Equal(t, court.email, freezes[0].AuthorityEmail)
+	assert.Equal(t, roleCourt, freezes[0].AuthorityRole)
+	assert.Equal(t, registrar.email, freezes[1].ReleasedBy)
+	assert.NoError(t, actions["share transfer"]())
+	assert.NoError(t, actions["lien"]())
+}
@@ -0,0 +1,65 @@
+package chaincode
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Privileged functions check the role attribute of the client certificate rather than its MSP,
+// since every user of an organization shares its MSP id. The certificate authority adds the
+// attribute at enrollment, for example with --id.attrs 'role=auditor:ecert'.
+const roleAttribute = "role"
+
+const (
+	roleAdmin     = "admin"
+	roleRegistrar = "registrar"
+	roleCourt     = "court"
+	roleAppraiser = "appraiser"
+	roleAuditor   = "auditor"
+	roleLender    = "lender"
+)
+
+// clientRole returns the role attribute of the client certificate, or "" when it has none.
+func clientRole(ctx contractapi.TransactionContextInterface) (string, error) {
+	role, _, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return "", errors.New("failed to get client role")
+	}
+	return role, nil
+}
+
+// requireRole fails unless the client certificate carries one of roles. action completes the
+// message "client is not authorized to ...".
+func requireRole(ctx contractapi.TransactionContextInterface, action string, roles ...string) (string, error) {
+	role, err := clientRole(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, allowed := range roles {
+		if role == allowed {
+			return role, nil
+		}
+	}
+	return "", permissionDenied("client is not authorized to %s", action)
+}
+
+// emailAttribute holds the email of the client, for records that name who signed them.
+const emailAttribute = "email"
+
+// clientName names the client in the records it signs: the email attribute of its certificate,
+// or the certificate's common name, which the certificate authority sets to the enrollment id.
+func clientName(ctx contractapi.TransactionContextInterface) (string, error) {
+	email, found, err := ctx.GetClientIdentity().GetAttributeValue(emailAttribute)
+	if err != nil {
+		return "", errors.New("failed to get client email")
+	}
+	if found && email != "" {
+		return email, nil
+	}
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil || cert == nil {
+		return "", errors.New("failed to get client certificate")
+	}
+	return cert.Subject.CommonName, nil
+}
@@ -0,0 +1,270 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Secondary indexes map a lookup value to the ids of the records holding it. They are written in
+// the same transaction as the record itself, so they can never drift from the primary keys.
+const ownerIndexCompositeKey = "owner~property~ownerEmail~propertyId"
+const locationIndexCompositeKey = "location~property~location~propertyId"
+const buyerIndexCompositeKey = "buyer~transaction~buyerEmail~transactionId"
+const sellerIndexCompositeKey = "seller~transaction~sellerEmail~transactionId"
+const propertyTransactionIndexCompositeKey = "property~transaction~propertyId~transactionId"
+const emailIndexCompositeKey = "email~user~email~userId"
+
+// GetPropertiesByOwner returns every property in which ownerEmail holds a share.
+func (r *RealEstate) GetPropertiesByOwner(ctx contractapi.TransactionContextInterface, ownerEmail string) ([]Property, error) {
+	propertyIds, err := r.indexLookup(ctx, ownerIndexCompositeKey, []string{"owner", ownerEmail})
+	if err != nil {
+		return nil, err
+	}
+	return r.getProperties(ctx, propertyIds)
+}
+
+// GetPropertiesByLocation returns the properties registered at a location, ignoring case.
+func (r *RealEstate) GetPropertiesByLocation(ctx contractapi.TransactionContextInterface, location string) ([]Property, error) {
+	propertyIds, err := r.indexLookup(ctx, locationIndexCompositeKey, []string{"location", normalizeLocation(location)})
+	if err != nil {
+		return nil, err
+	}
+	return r.getProperties(ctx, propertyIds)
+}
+
+// GetTransactionsByParty returns the transactions in which email was the buyer or the seller.
+func (r *RealEstate) GetTransactionsByParty(ctx contractapi.TransactionContextInterface, email string) ([]Transaction, error) {
+	bought, err := r.indexLookup(ctx, buyerIndexCompositeKey, []string{"buyer", email})
+	if err != nil {
+		return nil, err
+	}
+	sold, err := r.indexLookup(ctx, sellerIndexCompositeKey, []string{"seller", email})
+	if err != nil {
+		return nil, err
+	}
+	transactionIds := []string{}
+	seen := make(map[string]bool)
+	for _, transactionId := range append(bought, sold...) {
+		if !seen[transactionId] {
+			seen[transactionId] = true
+			transactionIds = append(transactionIds, transactionId)
+		}
+	}
+	return r.getTransactions(ctx, transactionIds)
+}
+
+// GetTransactionsByProperty returns every transaction recorded against a property.
+func (r *RealEstate) GetTransactionsByProperty(ctx contractapi.TransactionContextInterface, propertyId string) ([]Transaction, error) {
+	transactionIds, err := r.indexLookup(ctx, propertyTransactionIndexCompositeKey, []string{"property", propertyId})
+	if err != nil {
+		return nil, err
+	}
+	if transactionIds == nil {
+		transactionIds = []string{}
+	}
+	return r.getTransactions(ctx, transactionIds)
+}
+
+// RebuildIndexes writes the secondary index keys and query documents of every user, property,
+// shareholding, transaction and open auction, rewrites the transaction details and recounts the
+// registry counters. It backfills ledgers that hold records from before the indexes existed and is
+// safe to run again at any time.
+func (r *RealEstate) RebuildIndexes(ctx contractapi.TransactionContextInterface) error {
+	users, err := r.GetAllUsers(ctx)
+	if err != nil {
+		return err
+	}
+	counts := map[string]int{counterUsers: len(users)}
+	for _, user := range users {
+		if err := r.putIndex(ctx, emailIndexCompositeKey, "email", user.Email, user.UserId); err != nil {
+			return err
+		}
+	}
+	properties, err := r.GetAllProperty(ctx)
+	if err != nil {
+		return err
+	}
+	counts[counterProperties] = len(properties)
+	for _, property := range properties {
+		if property.Retired {
+			counts[counterRetired]++
+		} else if property.IsListed {
+			counts[counterListed]++
+		}
+		if err := r.putIndex(ctx, locationIndexCompositeKey, "location", normalizeLocation(property.Location), property.Id); err != nil {
+			return err
+		}
+		if err := r.putPropertyDocument(ctx, property.Id, property.Title, property.Location, formatAmount(property.Size), property.OwnerEmail, formatMoney(property.Price, property.Currency), strconv.FormatBool(property.IsListed), property.Retired); err != nil {
+			return err
+		}
+		for _, owner := range property.Owners {
+			if err := r.putIndex(ctx, ownerIndexCompositeKey, "owner", owner.Email, property.Id); err != nil {
+				return err
+			}
+		}
+	}
+	transactions, err := r.getTransactions(ctx, nil)
+	if err != nil {
+		return err
+	}
+	details, err := r.backfillTransactionDetails(ctx, transactions)
+	if err != nil {
+		return err
+	}
+	for _, transaction := range transactions {
+		transaction.Type, transaction.Heirs = details[transaction.Id].Type, details[transaction.Id].Heirs
+		switch transaction.Type {
+		case TransactionTypeSale:
+			counts[counterSales]++
+		case TransactionTypeReversal:
+			counts[counterReversals]++
+		case TransactionTypeSuccession:
+			counts[counterSuccessions]++
+		}
+		if err := r.indexTransaction(ctx, transaction.Id, transaction.PropertyId, transaction.BuyerEmail, transaction.SellerEmail); err != nil {
+			return err
+		}
+		if err := r.putTransactionDocument(ctx, []string{"transaction", transaction.Id, transaction.PropertyId, transaction.BuyerEmail, transaction.SellerEmail, "", transaction.Date, transaction.Status}); err != nil {
+			return err
+		}
+		for _, heir := range transaction.Heirs {
+			if err := r.putIndex(ctx, buyerIndexCompositeKey, "buyer", heir.Email, transaction.Id); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.indexOpenAuctions(ctx); err != nil {
+		return err
+	}
+	for _, name := range counterNames {
+		if err := setCount(ctx, name, counts[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putTransaction writes a transaction key, its buyer, seller and property index keys and its
+// query document.
+func (r *RealEstate) putTransaction(ctx contractapi.TransactionContextInterface, attributes []string, value []byte) error {
+	if err := putKey(ctx, transactionCompositeKey, versionedValue(assetTransaction, value), attributes...); err != nil {
+		return err
+	}
+	if err := r.indexTransaction(ctx, attributes[1], attributes[2], attributes[3], attributes[4]); err != nil {
+		return err
+	}
+	return r.putTransactionDocument(ctx, attributes)
+}
+
+func (r *RealEstate) indexTransaction(ctx contractapi.TransactionContextInterface, transactionId string, propertyId string, buyerEmail string, sellerEmail string) error {
+	if err := r.putIndex(ctx, buyerIndexCompositeKey, "buyer", buyerEmail, transactionId); err != nil {
+		return err
+	}
+	if err := r.putIndex(ctx, sellerIndexCompositeKey, "seller", sellerEmail, transactionId); err != nil {
+		return err
+	}
+	return r.putIndex(ctx, propertyTransactionIndexCompositeKey, "property", propertyId, transactionId)
+}
+
+func (r *RealEstate) putIndex(ctx contractapi.TransactionContextInterface, index string, name string, value string, id string) error {
+	return putKey(ctx, index, []byte{0x00}, name, value, id)
+}
+
+func (r *RealEstate) deleteIndex(ctx contractapi.TransactionContextInterface, index string, name string, value string, id string) error {
+	return deleteKey(ctx, index, name, value, id)
+}
+
+// indexLookup returns the ids stored under an index value, in key order.
+func (r *RealEstate) indexLookup(ctx contractapi.TransactionContextInterface, index string, attributes []string) ([]string, error) {
+	var ids []string
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s index", attributes[0])
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate over %s index", attributes[0])
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		ids = append(ids, keyParts[2])
+	}
+	return ids, nil
+}
+
+func (r *RealEstate) getProperties(ctx contractapi.TransactionContextInterface, propertyIds []string) ([]Property, error) {
+	var properties []Property
+	for _, propertyId := range propertyIds {
+		property, _, err := r.getProperty(ctx, propertyId)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, *property)
+	}
+	return properties, nil
+}
+
+func normalizeLocation(location string) string {
+	return strings.ToLower(strings.TrimSpace(location))
+}
+
+// backfillTransactionDetails rewrites the details of transactions from the type, reversal,
+// succession and agent records they were collected from and returns them by transaction id.
+// Reads do not see the transaction's own writes, so callers must use the returned details.
+func (r *RealEstate) backfillTransactionDetails(ctx contractapi.TransactionContextInterface, transactions []Transaction) (map[string]transactionDetail, error) {
+	types, err := r.getTransactionTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reversals, err := r.getReversals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	successions, err := r.getSuccessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := r.getSaleAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	details := make(map[string]transactionDetail)
+	for _, transaction := range transactions {
+		detail := transactionDetail{Type: TransactionTypeSale, AgentEmail: agents[transaction.Id]}
+		if transactionType, ok := types[transaction.Id]; ok {
+			detail.Type = transactionType
+		}
+		if reversal, ok := reversals[transaction.Id]; ok {
+			if reversal.TransactionId == transaction.Id {
+				detail.ReversedBy = reversal.CompensatingId
+			} else {
+				detail.Reverses = reversal.TransactionId
+				detail.ApprovedBy = reversal.RegistrarEmail
+			}
+			detail.Justification = reversal.Justification
+		}
+		if record, ok := successions[transaction.Id]; ok {
+			detail.ApprovedBy = record.registrarEmail
+			detail.Heirs = record.heirs
+			detail.ProbateHash = record.probateHash
+		}
+		data, err := json.Marshal(detail)
+		if err != nil {
+			return nil, err
+		}
+		if err := putKey(ctx, transactionDetailCompositeKey, data, "transactiondetail", transaction.Id); err != nil {
+			return nil, err
+		}
+		details[transaction.Id] = detail
+	}
+	return details, nil
+}
@@ -0,0 +1,79 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionDetails(t *testing.T) {
+	stub := newTestStub(t)
+	seller := testClient{email: "seller@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	registrar := testClient{email: "registrar@example.com", role: roleRegistrar}
+	r := new(RealEstate)
+
+	stub.mustInvoke(seller, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Nairobi", 10, seller.email, 100, "USD", true, "")
+	})
+	var saleId, reversalId string
+	stub.mustInvoke(seller, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		saleId, err = r.BuyProperty(ctx, "p1", buyer.email, seller.email)
+		return err
+	})
+	stub.mustInvoke(registrar, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		reversalId, err = r.ReverseTransaction(ctx, saleId, "forged deed")
+		return err
+	})
+
+	history := func() []Transaction {
+		var transactions []Transaction
+		stub.mustInvoke(buyer, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			transactions, err = r.GetTransactionsByProperty(ctx, "p1")
+			return err
+		})
+		require.Len(t, transactions, 2)
+		return transactions
+	}
+	check := func(transactions []Transaction) {
+		sale, reversal := transactions[0], transactions[1]
+		assert.Equal(t, saleId, sale.Id)
+		assert.Equal(t, TransactionTypeSale, sale.Type)
+		assert.Equal(t, TransactionReversed, sale.Status)
+		assert.Equal(t, reversalId, sale.ReversedBy)
+		assert.Equal(t, "forged deed", sale.Justification)
+		assert.Equal(t, reversalId, reversal.Id)
+		assert.Equal(t, TransactionTypeReversal, reversal.Type)
+		assert.Equal(t, saleId, reversal.Reverses)
+		assert.Equal(t, registrar.email, reversal.ApprovedBy)
+	}
+	check(history())
+
+	// Ledgers written before transaction details existed only have the records they are built
+	// from; RebuildIndexes backfills them.
+	for key := range stub.State {
+		if strings.Contains(key, transactionDetailCompositeKey) {
+			delete(stub.State, key)
+		}
+	}
+	assert.Equal(t, TransactionTypeSale, history()[1].Type)
+	stub.mustInvoke(registrar, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RebuildIndexes(ctx)
+	})
+	check(history())
+
+	var summary *RegistrySummary
+	stub.mustInvoke(buyer, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		summary, err = r.GetRegistrySummary(ctx)
+		return err
+	})
+	assert.Equal(t, 1, summary.Sales)
+	assert.Equal(t, 1, summary.Reversals)
+}
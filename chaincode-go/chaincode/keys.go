@@ -0,0 +1,54 @@
+package chaincode
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// createKey builds a composite key of objectType. The first attribute is the record's short name,
+// such as "property" or "owner", and is used in error messages.
+func createKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		log.Println("failed to create composite key for", attributes[0])
+		return "", fmt.Errorf("failed to create composite key for %s", attributes[0])
+	}
+	return key, nil
+}
+
+// putKey writes a composite key of objectType with value.
+func putKey(ctx contractapi.TransactionContextInterface, objectType string, value []byte, attributes ...string) error {
+	key, err := createKey(ctx, objectType, attributes...)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, value); err != nil {
+		log.Println("failed to put", attributes[0], "in world state")
+		return fmt.Errorf("failed to put %s in world state", attributes[0])
+	}
+	return nil
+}
+
+// deleteKey removes a composite key of objectType.
+func deleteKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) error {
+	key, err := createKey(ctx, objectType, attributes...)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return fmt.Errorf("failed to delete %s from world state", attributes[0])
+	}
+	return nil
+}
+
+// keyExists reports whether any key of objectType starts with the given attributes.
+func keyExists(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (bool, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s from world state", attributes[0])
+	}
+	defer resultIterator.Close()
+	return resultIterator.HasNext(), nil
+}
@@ -0,0 +1,421 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Lease rent and deposit are minor units of Currency. The end date is exclusive: once it has
+// passed, the lease no longer binds the property, whatever its status.
+type Lease struct {
+	Id             string `json:"id"`
+	PropertyId     string `json:"property_id"`
+	LandlordEmail  string `json:"landlord_email"`
+	TenantEmail    string `json:"tenant_email"`
+	StartDate      string `json:"start_date"`
+	EndDate        string `json:"end_date"`
+	Rent           int64  `json:"rent"`
+	Deposit        int64  `json:"deposit"`
+	Currency       string `json:"currency"`
+	LandlordSigned bool   `json:"landlord_signed"`
+	TenantSigned   bool   `json:"tenant_signed"`
+	Status         string `json:"status"`
+}
+
+const leaseCompositeKey = "lease~propertyId~leaseId~landlordEmail~tenantEmail~startDate~endDate~rent~deposit~landlordSigned~tenantSigned~status"
+const leaseRenewalCompositeKey = "leaserenewal~propertyId~leaseId~endDate~rent~signerEmail"
+
+const leaseDateLayout = "2006-01-02"
+
+const (
+	LeasePending    = "Pending"
+	LeaseActive     = "Active"
+	LeaseTerminated = "Terminated"
+)
+
+func (r *RealEstate) CreateLease(ctx contractapi.TransactionContextInterface, leaseId string, propertyId string, landlordEmail string, tenantEmail string, startDate string, endDate string, rent int64, deposit int64, currency string) error {
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	if property.Retired {
+		return invalidArgument("cannot lease a retired property")
+	}
+	if property.Frozen {
+		return invalidArgument("cannot lease a frozen property")
+	}
+	isOwner := false
+	for _, owner := range property.Owners {
+		if owner.Email == landlordEmail {
+			isOwner = true
+		}
+	}
+	if !isOwner {
+		return permissionDenied("landlord is not an owner of the property")
+	}
+	if landlordEmail == tenantEmail {
+		return invalidArgument("tenant cannot be the landlord")
+	}
+	start, end, err := parseLeaseTerm(startDate, endDate)
+	if err != nil {
+		return err
+	}
+	if err := validateMoney("rent", rent, currency); err != nil {
+		return err
+	}
+	if deposit < 0 {
+		return invalidArgument("deposit must not be negative")
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	leases, err := r.GetLeases(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	for _, lease := range leases {
+		if lease.Id == leaseId {
+			return alreadyExists("lease %s already exists", leaseId)
+		}
+		if !lease.inForce(now) {
+			continue
+		}
+		otherStart, otherEnd, err := parseLeaseTerm(lease.StartDate, lease.EndDate)
+		if err != nil {
+			return err
+		}
+		if start.Before(otherEnd) && otherStart.Before(end) {
+			return invalidArgument("lease term overlaps existing lease %s", lease.Id)
+		}
+	}
+	return r.putLease(ctx, Lease{
+		Id:            leaseId,
+		PropertyId:    propertyId,
+		LandlordEmail: landlordEmail,
+		TenantEmail:   tenantEmail,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		Rent:          rent,
+		Deposit:       deposit,
+		Currency:      currency,
+		Status:        LeasePending,
+	})
+}
+
+func (r *RealEstate) SignLease(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string, signerEmail string) (string, error) {
+	lease, leaseKey, err := r.getLease(ctx, propertyId, leaseId)
+	if err != nil {
+		return "", err
+	}
+	if lease.Status != LeasePending {
+		return "", invalidArgument("lease is %s and cannot be signed", lease.Status)
+	}
+	switch signerEmail {
+	case lease.LandlordEmail:
+		lease.LandlordSigned = true
+	case lease.TenantEmail:
+		lease.TenantSigned = true
+	default:
+		return "", permissionDenied("only the landlord or tenant can sign the lease")
+	}
+	if lease.LandlordSigned && lease.TenantSigned {
+		lease.Status = LeaseActive
+	}
+	err = ctx.GetStub().DelState(leaseKey)
+	if err != nil {
+		return "", errors.New("failed to delete old lease state")
+	}
+	err = r.putLease(ctx, *lease)
+	if err != nil {
+		return "", err
+	}
+	return lease.Status, nil
+}
+
+// RenewLease extends an active lease once both the landlord and the tenant have requested the same
+// new terms. The rent is in minor units of the lease's currency.
+func (r *RealEstate) RenewLease(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string, endDate string, rent int64, signerEmail string) (string, error) {
+	lease, leaseKey, err := r.getLease(ctx, propertyId, leaseId)
+	if err != nil {
+		return "", err
+	}
+	if lease.Status != LeaseActive {
+		return "", invalidArgument("only an active lease can be renewed")
+	}
+	if signerEmail != lease.LandlordEmail && signerEmail != lease.TenantEmail {
+		return "", permissionDenied("only the landlord or tenant can renew the lease")
+	}
+	if _, _, err := parseLeaseTerm(lease.EndDate, endDate); err != nil {
+		return "", invalidArgument("renewal must end after the current lease end date")
+	}
+	if err := validateMoney("rent", rent, lease.Currency); err != nil {
+		return "", err
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !lease.inForce(now) {
+		return "", invalidArgument("lease has already ended")
+	}
+	renewedRent := formatMoney(rent, lease.Currency)
+
+	otherParty := lease.TenantEmail
+	if signerEmail == lease.TenantEmail {
+		otherParty = lease.LandlordEmail
+	}
+	otherKey, err := ctx.GetStub().CreateCompositeKey(leaseRenewalCompositeKey, []string{"leaserenewal", propertyId, leaseId, endDate, renewedRent, otherParty})
+	if err != nil {
+		return "", errors.New("failed to create composite key for lease renewal")
+	}
+	otherSigned, err := ctx.GetStub().GetState(otherKey)
+	if err != nil {
+		return "", errors.New("failed to read lease renewal from world state")
+	}
+	if otherSigned == nil {
+		renewalKey, err := ctx.GetStub().CreateCompositeKey(leaseRenewalCompositeKey, []string{"leaserenewal", propertyId, leaseId, endDate, renewedRent, signerEmail})
+		if err != nil {
+			return "", errors.New("failed to create composite key for lease renewal")
+		}
+		err = ctx.GetStub().PutState(renewalKey, []byte{0x00})
+		if err != nil {
+			return "", errors.New("failed to put lease renewal in world state")
+		}
+		return ConsentPending, nil
+	}
+
+	if err := r.clearLeaseRenewals(ctx, propertyId, leaseId); err != nil {
+		return "", err
+	}
+	err = ctx.GetStub().DelState(leaseKey)
+	if err != nil {
+		return "", errors.New("failed to delete old lease state")
+	}
+	lease.EndDate = endDate
+	lease.Rent = rent
+	err = r.putLease(ctx, *lease)
+	if err != nil {
+		return "", err
+	}
+	return ConsentApproved, nil
+}
+
+func (r *RealEstate) TerminateLease(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string, requesterEmail string) error {
+	lease, leaseKey, err := r.getLease(ctx, propertyId, leaseId)
+	if err != nil {
+		return err
+	}
+	if lease.Status == LeaseTerminated {
+		return invalidArgument("lease is already terminated")
+	}
+	if requesterEmail != lease.LandlordEmail && requesterEmail != lease.TenantEmail {
+		return permissionDenied("only the landlord or tenant can terminate the lease")
+	}
+	if err := r.clearLeaseRenewals(ctx, propertyId, leaseId); err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(leaseKey)
+	if err != nil {
+		return errors.New("failed to delete old lease state")
+	}
+	lease.Status = LeaseTerminated
+	return r.putLease(ctx, *lease)
+}
+
+func (r *RealEstate) GetLeases(ctx contractapi.TransactionContextInterface, propertyId string) ([]Lease, error) {
+	var leases []Lease
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(leaseCompositeKey, []string{"lease", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get leases")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over leases")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		lease, err := leaseFromKeyParts(keyParts)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, *lease)
+	}
+	return leases, nil
+}
+
+// transferLeases makes the buyer the landlord of every lease that is still in force, so that
+// a sale of a leased property always happens subject to the existing tenancy. A non-empty
+// landlordEmail limits the handover to that landlord's leases.
+func (r *RealEstate) transferLeases(ctx contractapi.TransactionContextInterface, propertyId string, landlordEmail string, buyerEmail string) error {
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(leaseCompositeKey, []string{"lease", propertyId})
+	if err != nil {
+		return errors.New("failed to get leases")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return errors.New("failed to iterate over leases")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		lease, err := leaseFromKeyParts(keyParts)
+		if err != nil {
+			return err
+		}
+		if !lease.inForce(now) || (landlordEmail != "" && lease.LandlordEmail != landlordEmail) {
+			continue
+		}
+		if lease.TenantEmail == buyerEmail {
+			return invalidArgument("tenant taking over the property must terminate the lease first")
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return errors.New("failed to delete old lease state")
+		}
+		lease.LandlordEmail = buyerEmail
+		if err := r.putLease(ctx, *lease); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RealEstate) getLease(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string) (*Lease, string, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(leaseCompositeKey, []string{"lease", propertyId, leaseId})
+	if err != nil {
+		log.Println("failed to read lease from world state")
+		return nil, "", errors.New("failed to read lease from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return nil, "", notFound("lease %s not found", leaseId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return nil, "", errors.New("failed to iterate over leases")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return nil, "", fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	lease, err := leaseFromKeyParts(keyParts)
+	if err != nil {
+		return nil, "", err
+	}
+	return lease, queryResponse.Key, nil
+}
+
+func (r *RealEstate) putLease(ctx contractapi.TransactionContextInterface, lease Lease) error {
+	leaseKey, err := ctx.GetStub().CreateCompositeKey(leaseCompositeKey, []string{"lease", lease.PropertyId, lease.Id, lease.LandlordEmail, lease.TenantEmail, lease.StartDate, lease.EndDate, formatMoney(lease.Rent, lease.Currency), formatMoney(lease.Deposit, lease.Currency), strconv.FormatBool(lease.LandlordSigned), strconv.FormatBool(lease.TenantSigned), lease.Status})
+	if err != nil {
+		log.Println("failed to create composite key for lease")
+		return errors.New("failed to create composite key for lease")
+	}
+	err = ctx.GetStub().PutState(leaseKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put lease in world state")
+		return errors.New("failed to put lease in world state")
+	}
+	return nil
+}
+
+func (r *RealEstate) clearLeaseRenewals(ctx contractapi.TransactionContextInterface, propertyId string, leaseId string) error {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(leaseRenewalCompositeKey, []string{"leaserenewal", propertyId, leaseId})
+	if err != nil {
+		return errors.New("failed to get lease renewals")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return errors.New("failed to iterate over lease renewals")
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return errors.New("failed to delete lease renewal")
+		}
+	}
+	return nil
+}
+
+// leaseFromKeyParts also reads leases written before currencies were introduced, whose rent and
+// deposit are decimals in defaultCurrency.
+func leaseFromKeyParts(keyParts []string) (*Lease, error) {
+	rent, currency, err := parseMoney(keyParts[7])
+	if err != nil {
+		return nil, err
+	}
+	deposit, _, err := parseMoney(keyParts[8])
+	if err != nil {
+		return nil, err
+	}
+	landlordSigned, err := strconv.ParseBool(keyParts[9])
+	if err != nil {
+		return nil, err
+	}
+	tenantSigned, err := strconv.ParseBool(keyParts[10])
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{
+		Id:             keyParts[2],
+		PropertyId:     keyParts[1],
+		LandlordEmail:  keyParts[3],
+		TenantEmail:    keyParts[4],
+		StartDate:      keyParts[5],
+		EndDate:        keyParts[6],
+		Rent:           rent,
+		Deposit:        deposit,
+		Currency:       currency,
+		LandlordSigned: landlordSigned,
+		TenantSigned:   tenantSigned,
+		Status:         keyParts[11],
+	}, nil
+}
+
+// inForce reports whether the lease still binds the property at now: it is not terminated and
+// its end date has not been reached.
+func (lease *Lease) inForce(now time.Time) bool {
+	if lease.Status == LeaseTerminated {
+		return false
+	}
+	end, err := time.Parse(leaseDateLayout, lease.EndDate)
+	return err != nil || now.Before(end)
+}
+
+func parseLeaseTerm(startDate string, endDate string) (time.Time, time.Time, error) {
+	start, err := time.Parse(leaseDateLayout, startDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, invalidArgument("invalid start date: %s", startDate)
+	}
+	end, err := time.Parse(leaseDateLayout, endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, invalidArgument("invalid end date: %s", endDate)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, invalidArgument("end date must be after start date")
+	}
+	return start, end, nil
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,95 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaseLifecycle(t *testing.T) {
+	stub := newTestStub(t)
+	landlord := testClient{email: "landlord@example.com"}
+	tenant := testClient{email: "tenant@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	r := new(RealEstate)
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	stub.mustInvoke(landlord, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Flat", "Mombasa", 80, landlord.email, 100000, "USD", false, "")
+	})
+	create := func(leaseId string, startDate string, endDate string) error {
+		return stub.invoke(landlord, now, func(ctx contractapi.TransactionContextInterface) error {
+			return r.CreateLease(ctx, leaseId, "p1", landlord.email, tenant.email, startDate, endDate, 1500, 3000, "USD")
+		})
+	}
+	sign := func(signer testClient) (string, error) {
+		var status string
+		err := stub.invoke(signer, now, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			status, err = r.SignLease(ctx, "p1", "l1", signer.email)
+			return err
+		})
+		return status, err
+	}
+	renew := func(signer testClient, endDate string) string {
+		var status string
+		require.NoError(t, stub.invoke(signer, now, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			status, err = r.RenewLease(ctx, "p1", "l1", endDate, 1600, signer.email)
+			return err
+		}))
+		return status
+	}
+	leases := func() []Lease {
+		var leases []Lease
+		stub.mustInvoke(tenant, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			leases, err = r.GetLeases(ctx, "p1")
+			return err
+		})
+		return leases
+	}
+
+	require.NoError(t, create("l1", "2026-03-01", "2027-03-01"))
+	assert.ErrorContains(t, create("l2", "2026-06-01", "2026-09-01"), "overlaps existing lease l1")
+	assert.ErrorContains(t, create("l3", "2026-03-01", "2026-03-01"), ErrInvalidArgument)
+
+	_, err := sign(buyer)
+	assert.ErrorContains(t, err, ErrPermissionDenied)
+	status, err := sign(landlord)
+	require.NoError(t, err)
+	assert.Equal(t, LeasePending, status)
+	status, err = sign(tenant)
+	require.NoError(t, err)
+	assert.Equal(t, LeaseActive, status)
+
+	// A renewal needs both parties to ask for the same terms.
+	assert.Equal(t, ConsentPending, renew(landlord, "2028-03-01"))
+	assert.Equal(t, ConsentPending, renew(tenant, "2027-09-01"))
+	assert.Equal(t, "2027-03-01", leases()[0].EndDate)
+	assert.Equal(t, ConsentApproved, renew(tenant, "2028-03-01"))
+	lease := leases()[0]
+	assert.Equal(t, "2028-03-01", lease.EndDate)
+	assert.Equal(t, int64(1600), lease.Rent)
+
+	// The buyer takes the property subject to the tenancy.
+	require.NoError(t, stub.invoke(landlord, now, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.BuyProperty(ctx, "p1", buyer.email, landlord.email)
+		return err
+	}))
+	lease = leases()[0]
+	assert.Equal(t, buyer.email, lease.LandlordEmail)
+	assert.Equal(t, LeaseActive, lease.Status)
+
+	err = stub.invoke(landlord, now, func(ctx contractapi.TransactionContextInterface) error {
+		return r.TerminateLease(ctx, "p1", "l1", landlord.email)
+	})
+	assert.ErrorContains(t, err, ErrPermissionDenied, "the seller is no longer a party to the lease")
+	require.NoError(t, stub.invoke(tenant, now, func(ctx contractapi.TransactionContextInterface) error {
+		return r.TerminateLease(ctx, "p1", "l1", tenant.email)
+	}))
+	assert.Equal(t, LeaseTerminated, leases()[0].Status)
+}
@@ -0,0 +1,177 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Lien amounts are minor units of Currency, stored in the key like property prices. Liens
+// registered before currencies were introduced hold a decimal in defaultCurrency.
+type Lien struct {
+	Id          string `json:"id"`
+	PropertyId  string `json:"property_id"`
+	LenderEmail string `json:"lender_email"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Priority    int    `json:"priority"`
+	Status      string `json:"status"`
+}
+
+const lienCompositeKey = "lien~propertyId~lienId~lenderEmail~amount~priority~status"
+
+const (
+	LienActive   = "Active"
+	LienReleased = "Released"
+)
+
+// RegisterLien encumbers a property on behalf of the lender signing the transaction, who must
+// hold the lender role. The lien is recorded under the lender's name from the certificate.
+func (r *RealEstate) RegisterLien(ctx contractapi.TransactionContextInterface, lienId string, propertyId string, amount int64, currency string, priority string) error {
+	if _, err := requireRole(ctx, "register liens", roleLender); err != nil {
+		return err
+	}
+	lenderEmail, err := clientName(ctx)
+	if err != nil {
+		return err
+	}
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	if property.Retired {
+		return invalidArgument("cannot register a lien on a retired property")
+	}
+	if property.Frozen {
+		return invalidArgument("cannot register a lien on a frozen property")
+	}
+	if err := validateMoney("lien amount", amount, currency); err != nil {
+		return err
+	}
+	lienPriority, err := strconv.Atoi(priority)
+	if err != nil || lienPriority < 1 {
+		return invalidArgument("invalid lien priority: %s", priority)
+	}
+	liens, err := r.GetLiens(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	for _, lien := range liens {
+		if lien.Id == lienId {
+			return alreadyExists("lien %s already exists", lienId)
+		}
+		if lien.Status == LienActive && lien.Priority == lienPriority {
+			return alreadyExists("an active lien with priority %d already exists", lienPriority)
+		}
+	}
+	return r.putLien(ctx, propertyId, lienId, lenderEmail, formatMoney(amount, currency), priority, LienActive)
+}
+
+// ReleaseLien releases a lien. Only the lender who registered it may release it.
+func (r *RealEstate) ReleaseLien(ctx contractapi.TransactionContextInterface, propertyId string, lienId string) error {
+	if _, err := requireRole(ctx, "release liens", roleLender); err != nil {
+		return err
+	}
+	lenderEmail, err := clientName(ctx)
+	if err != nil {
+		return err
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(lienCompositeKey, []string{"lien", propertyId, lienId})
+	if err != nil {
+		log.Println("failed to read lien from world state")
+		return errors.New("failed to read lien from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return notFound("lien %s not found", lienId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return errors.New("failed to iterate over liens")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	if keyParts[3] != lenderEmail {
+		return permissionDenied("only the lender can release the lien")
+	}
+	if keyParts[6] != LienActive {
+		return invalidArgument("lien is already released")
+	}
+	err = ctx.GetStub().DelState(queryResponse.Key)
+	if err != nil {
+		return errors.New("failed to delete old lien state")
+	}
+	return r.putLien(ctx, propertyId, lienId, lenderEmail, keyParts[4], keyParts[5], LienReleased)
+}
+
+func (r *RealEstate) GetLiens(ctx contractapi.TransactionContextInterface, propertyId string) ([]Lien, error) {
+	var liens []Lien
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(lienCompositeKey, []string{"lien", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get liens")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over liens")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		amount, currency, err := parseMoney(keyParts[4])
+		if err != nil {
+			return nil, err
+		}
+		priority, err := strconv.Atoi(keyParts[5])
+		if err != nil {
+			return nil, err
+		}
+		lien := Lien{
+			Id:          keyParts[2],
+			PropertyId:  keyParts[1],
+			LenderEmail: keyParts[3],
+			Amount:      amount,
+			Currency:    currency,
+			Priority:    priority,
+			Status:      keyParts[6],
+		}
+		liens = append(liens, lien)
+	}
+	return liens, nil
+}
+
+// hasActiveLien reports whether the property is still encumbered by an unreleased lien.
+func (r *RealEstate) hasActiveLien(ctx contractapi.TransactionContextInterface, propertyId string) (bool, error) {
+	liens, err := r.GetLiens(ctx, propertyId)
+	if err != nil {
+		return false, err
+	}
+	for _, lien := range liens {
+		if lien.Status == LienActive {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RealEstate) putLien(ctx contractapi.TransactionContextInterface, propertyId string, lienId string, lenderEmail string, amount string, priority string, status string) error {
+	lienKey, err := ctx.GetStub().CreateCompositeKey(lienCompositeKey, []string{"lien", propertyId, lienId, lenderEmail, amount, priority, status})
+	if err != nil {
+		log.Println("failed to create composite key for lien")
+		return errors.New("failed to create composite key for lien")
+	}
+	err = ctx.GetStub().PutState(lienKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put lien in world state")
+		return errors.New("failed to put lien in world state")
+	}
+	return nil
+}
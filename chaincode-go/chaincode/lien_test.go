@@ -0,0 +1,59 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiens(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	lender := testClient{email: "bank@example.com", role: roleLender}
+	otherLender := testClient{email: "other-bank@example.com", role: roleLender}
+	r := new(RealEstate)
+
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Nairobi", 10, owner.email, 100, "USD", true, "")
+	})
+	register := func(client testClient, lienId string, priority string) error {
+		return stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterLien(ctx, lienId, "p1", 5000, "USD", priority)
+		})
+	}
+	release := func(client testClient, lienId string) error {
+		return stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			return r.ReleaseLien(ctx, "p1", lienId)
+		})
+	}
+	buy := func() error {
+		return stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := r.BuyProperty(ctx, "p1", buyer.email, owner.email)
+			return err
+		})
+	}
+
+	assert.ErrorContains(t, register(owner, "l1", "1"), ErrPermissionDenied, "only lenders register liens")
+	require.NoError(t, register(lender, "l1", "1"))
+	assert.ErrorContains(t, register(otherLender, "l2", "1"), ErrAlreadyExists, "priorities are unique among active liens")
+
+	var liens []Lien
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		liens, err = r.GetLiens(ctx, "p1")
+		return err
+	})
+	require.Len(t, liens, 1)
+	assert.Equal(t, Lien{Id: "l1", PropertyId: "p1", LenderEmail: lender.email, Amount: 5000, Currency: "USD", Priority: 1, Status: LienActive}, liens[0])
+
+	assert.ErrorContains(t, buy(), "active liens")
+	assert.ErrorContains(t, release(owner, "l1"), ErrPermissionDenied, "owners cannot release liens")
+	assert.ErrorContains(t, release(otherLender, "l1"), ErrPermissionDenied, "only the lender who registered the lien releases it")
+	require.NoError(t, release(lender, "l1"))
+	assert.ErrorContains(t, release(lender, "l1"), ErrInvalidArgument)
+	assert.NoError(t, buy())
+}
@@ -0,0 +1,371 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PropertyPart describes a parcel created by a subdivision or a merge.
+type PropertyPart struct {
+	Id           string  `json:"id"`
+	Title        string  `json:"title"`
+	Location     string  `json:"location"`
+	Size         float64 `json:"size"`
+	Price        int64   `json:"price"`
+	Currency     string  `json:"currency"`
+	BoundaryHash string  `json:"boundary_hash" metadata:",optional"`
+}
+
+type LineageLink struct {
+	Relation   string `json:"relation"`
+	PropertyId string `json:"property_id"`
+	Event      string `json:"event"`
+	Date       string `json:"date"`
+}
+
+const lineageCompositeKey = "lineage~propertyId~relation~relatedPropertyId~event~date"
+const retiredCompositeKey = "retired~propertyId~event"
+
+const (
+	LineageParent = "parent"
+	LineageChild  = "child"
+
+	EventSubdivision = "Subdivision"
+	EventMerge       = "Merge"
+
+	ConsentSubdividePrefix = "subdivide:"
+	ConsentMergePrefix     = "merge:"
+)
+
+// SubdivideProperty splits a parcel into children whose sizes add up to the parent's. The
+// children keep the parent's share split and the parent is retired once the co-owners consent.
+func (r *RealEstate) SubdivideProperty(ctx contractapi.TransactionContextInterface, propertyId string, children []PropertyPart, ownerEmail string) (string, error) {
+	if len(children) < 2 {
+		return "", invalidArgument("a subdivision needs at least two children")
+	}
+	parent, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if err := r.ensureRestructurable(ctx, parent); err != nil {
+		return "", err
+	}
+	total := 0.0
+	childIds := make(map[string]bool)
+	for _, child := range children {
+		if childIds[child.Id] {
+			return "", invalidArgument("child id %s is used twice", child.Id)
+		}
+		childIds[child.Id] = true
+		if err := r.validatePropertyPart(ctx, child); err != nil {
+			return "", err
+		}
+		total = roundAmount(total + child.Size)
+	}
+	if total != roundAmount(parent.Size) {
+		return "", invalidArgument("children sizes add up to %.2f but the parent is %.2f", total, parent.Size)
+	}
+	action, err := restructureAction(ConsentSubdividePrefix, children)
+	if err != nil {
+		return "", err
+	}
+	approved, err := r.recordConsent(ctx, propertyId, parent.OwnerEmail, action, ownerEmail)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return ConsentPending, nil
+	}
+
+	date, err := lineageDate(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, child := range children {
+		if err := r.createPart(ctx, child, parent.OwnerEmail, parent.Owners); err != nil {
+			return "", err
+		}
+		if err := r.linkLineage(ctx, propertyId, child.Id, EventSubdivision, date); err != nil {
+			return "", err
+		}
+	}
+	if err := r.retireProperty(ctx, parent, EventSubdivision); err != nil {
+		return "", err
+	}
+	return ConsentApproved, nil
+}
+
+// MergeProperties combines parcels held by exactly the same owners into one new parcel and
+// retires the originals.
+func (r *RealEstate) MergeProperties(ctx contractapi.TransactionContextInterface, propertyIds []string, merged PropertyPart, ownerEmail string) (string, error) {
+	if len(propertyIds) < 2 {
+		return "", invalidArgument("a merge needs at least two properties")
+	}
+	var parents []*Property
+	seen := make(map[string]bool)
+	size := 0.0
+	for _, propertyId := range propertyIds {
+		if seen[propertyId] {
+			return "", invalidArgument("property %s is listed twice", propertyId)
+		}
+		seen[propertyId] = true
+		parent, _, err := r.getProperty(ctx, propertyId)
+		if err != nil {
+			return "", err
+		}
+		if err := r.ensureRestructurable(ctx, parent); err != nil {
+			return "", err
+		}
+		if len(parents) > 0 && !sameOwners(parents[0].Owners, parent.Owners) {
+			return "", invalidArgument("only properties with the same owners can be merged")
+		}
+		parents = append(parents, parent)
+		size = roundAmount(size + parent.Size)
+	}
+	merged.Size = size
+	if err := r.validatePropertyPart(ctx, merged); err != nil {
+		return "", err
+	}
+	action, err := restructureAction(ConsentMergePrefix, struct {
+		PropertyIds []string     `json:"property_ids"`
+		Merged      PropertyPart `json:"merged"`
+	}{propertyIds, merged})
+	if err != nil {
+		return "", err
+	}
+	approved, err := r.recordConsent(ctx, propertyIds[0], parents[0].OwnerEmail, action, ownerEmail)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return ConsentPending, nil
+	}
+
+	date, err := lineageDate(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := r.createPart(ctx, merged, parents[0].OwnerEmail, parents[0].Owners); err != nil {
+		return "", err
+	}
+	for _, parent := range parents {
+		if err := r.linkLineage(ctx, parent.Id, merged.Id, EventMerge, date); err != nil {
+			return "", err
+		}
+		if err := r.retireProperty(ctx, parent, EventMerge); err != nil {
+			return "", err
+		}
+	}
+	return ConsentApproved, nil
+}
+
+func (r *RealEstate) GetLineage(ctx contractapi.TransactionContextInterface, propertyId string) ([]LineageLink, error) {
+	var links []LineageLink
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(lineageCompositeKey, []string{"lineage", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get lineage")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over lineage")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		links = append(links, LineageLink{Relation: keyParts[2], PropertyId: keyParts[3], Event: keyParts[4], Date: keyParts[5]})
+	}
+	return links, nil
+}
+
+// ensureActive rejects changes to a property that was retired by a subdivision or merge.
+func (r *RealEstate) ensureActive(ctx contractapi.TransactionContextInterface, propertyId string) error {
+	retired, err := r.retiredBy(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	if retired != "" {
+		return invalidArgument("property was retired by a %s", retired)
+	}
+	return nil
+}
+
+// retiredBy returns the event that retired the property, or an empty string while it is active.
+func (r *RealEstate) retiredBy(ctx contractapi.TransactionContextInterface, propertyId string) (string, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(retiredCompositeKey, []string{"retired", propertyId})
+	if err != nil {
+		return "", errors.New("failed to read retirement from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return "", nil
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return "", errors.New("failed to iterate over retirements")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return "", fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	return keyParts[2], nil
+}
+
+// ensureRestructurable only lets unencumbered, unfrozen, unlisted and untenanted parcels be split or merged.
+func (r *RealEstate) ensureRestructurable(ctx contractapi.TransactionContextInterface, property *Property) error {
+	if property.Retired {
+		return invalidArgument("property %s is retired", property.Id)
+	}
+	if property.IsListed {
+		return invalidArgument("property %s is listed for sale", property.Id)
+	}
+	if property.Frozen {
+		return invalidArgument("property %s is frozen by a legal hold", property.Id)
+	}
+	encumbered, err := r.hasActiveLien(ctx, property.Id)
+	if err != nil {
+		return err
+	}
+	if encumbered {
+		return invalidArgument("property %s has active liens", property.Id)
+	}
+	leases, err := r.GetLeases(ctx, property.Id)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	for _, lease := range leases {
+		if lease.inForce(now) {
+			return invalidArgument("property %s has a lease in force", property.Id)
+		}
+	}
+	return nil
+}
+
+func (r *RealEstate) validatePropertyPart(ctx contractapi.TransactionContextInterface, part PropertyPart) error {
+	if part.Id == "" || part.Title == "" || part.Location == "" {
+		return invalidArgument("new parcels need an id, title and location")
+	}
+	if part.Size <= 0 {
+		return invalidArgument("parcel %s needs a positive size", part.Id)
+	}
+	if err := validateMoney("price of parcel "+part.Id, part.Price, part.Currency); err != nil {
+		return err
+	}
+	if _, _, err := r.getProperty(ctx, part.Id); err == nil {
+		return alreadyExists("property %s already exists", part.Id)
+	}
+	return nil
+}
+
+// createPart registers a new parcel held with the given share split.
+func (r *RealEstate) createPart(ctx contractapi.TransactionContextInterface, part PropertyPart, ownerEmail string, owners []Owner) error {
+	err := r.RegisterProperty(ctx, part.Id, part.Title, part.Location, part.Size, ownerEmail, part.Price, part.Currency, false, part.BoundaryHash)
+	if err != nil {
+		return err
+	}
+	if len(owners) < 2 {
+		return nil
+	}
+	// RegisterProperty made ownerEmail the sole owner; replace that with the inherited shares.
+	ownershipKey, err := ctx.GetStub().CreateCompositeKey(ownershipCompositeKey, []string{"ownership", part.Id, ownerEmail, strconv.Itoa(fullShare)})
+	if err != nil {
+		return errors.New("failed to create composite key for ownership")
+	}
+	if err := ctx.GetStub().DelState(ownershipKey); err != nil {
+		return errors.New("failed to delete old ownership state")
+	}
+	for _, owner := range owners {
+		if err := r.putOwnership(ctx, part.Id, owner.Email, int(math.Round(owner.Share*100))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RealEstate) retireProperty(ctx contractapi.TransactionContextInterface, property *Property, event string) error {
+	retiredKey, err := ctx.GetStub().CreateCompositeKey(retiredCompositeKey, []string{"retired", property.Id, event})
+	if err != nil {
+		log.Println("failed to create composite key for retirement")
+		return errors.New("failed to create composite key for retirement")
+	}
+	err = ctx.GetStub().PutState(retiredKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put retirement in world state")
+		return errors.New("failed to put retirement in world state")
+	}
+	err = r.putPropertyDocument(ctx, property.Id, property.Title, property.Location, formatAmount(property.Size), property.OwnerEmail, formatMoney(property.Price, property.Currency), strconv.FormatBool(property.IsListed), true)
+	if err != nil {
+		return err
+	}
+	return addCount(ctx, counterRetired, 1)
+}
+
+func (r *RealEstate) linkLineage(ctx contractapi.TransactionContextInterface, parentId string, childId string, event string, date string) error {
+	links := [][]string{
+		{"lineage", parentId, LineageChild, childId, event, date},
+		{"lineage", childId, LineageParent, parentId, event, date},
+	}
+	for _, attributes := range links {
+		lineageKey, err := ctx.GetStub().CreateCompositeKey(lineageCompositeKey, attributes)
+		if err != nil {
+			log.Println("failed to create composite key for lineage")
+			return errors.New("failed to create composite key for lineage")
+		}
+		err = ctx.GetStub().PutState(lineageKey, []byte{0x00})
+		if err != nil {
+			log.Println("failed to put lineage in world state")
+			return errors.New("failed to put lineage in world state")
+		}
+	}
+	return nil
+}
+
+func lineageDate(ctx contractapi.TransactionContextInterface) (string, error) {
+	now, err := txTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	return now.UTC().Format(transactionDateLayout), nil
+}
+
+// restructureAction ties co-owner consent to the exact layout being approved, so owners who
+// agreed to one split do not count towards a different one.
+func restructureAction(prefix string, layout interface{}) (string, error) {
+	data, err := json.Marshal(layout)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return prefix + hex.EncodeToString(hash[:8]), nil
+}
+
+func sameOwners(a []Owner, b []Owner) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	shares := make(map[string]float64)
+	for _, owner := range a {
+		shares[owner.Email] = owner.Share
+	}
+	for _, owner := range b {
+		if share, ok := shares[owner.Email]; !ok || share != owner.Share {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubdivideAndMerge(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	partner := testClient{email: "partner@example.com"}
+	r := new(RealEstate)
+
+	for _, propertyId := range []string{"p1", "p2"} {
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Farm", "Nakuru", 100, owner.email, 500000, "USD", false, "")
+		})
+	}
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.TransferShare(ctx, "p1", owner.email, partner.email, "50")
+	})
+	part := func(id string, size float64) PropertyPart {
+		return PropertyPart{Id: id, Title: "Farm " + id, Location: "Nakuru", Size: size, Price: 250000, Currency: "USD"}
+	}
+	subdivide := func(client testClient, children ...PropertyPart) (string, error) {
+		var status string
+		err := stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			status, err = r.SubdivideProperty(ctx, "p1", children, client.email)
+			return err
+		})
+		return status, err
+	}
+	property := func(propertyId string) Property {
+		var detail *PropertyDetail
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			detail, err = r.GetProperty(ctx, propertyId)
+			return err
+		})
+		return detail.Property
+	}
+
+	_, err := subdivide(owner, part("p1a", 60), part("p1b", 50))
+	assert.ErrorContains(t, err, "children sizes add up to 110.00 but the parent is 100.00")
+
+	// Each co-owner's consent counts only towards the layout they agreed to.
+	status, err := subdivide(owner, part("p1a", 60), part("p1b", 40))
+	require.NoError(t, err)
+	assert.Equal(t, ConsentPending, status)
+	status, err = subdivide(partner, part("p1a", 50), part("p1b", 50))
+	require.NoError(t, err)
+	assert.Equal(t, ConsentPending, status)
+	status, err = subdivide(partner, part("p1a", 60), part("p1b", 40))
+	require.NoError(t, err)
+	assert.Equal(t, ConsentApproved, status)
+
+	assert.True(t, property("p1").Retired)
+	for _, childId := range []string{"p1a", "p1b"} {
+		assert.ElementsMatch(t, []Owner{{Email: owner.email, Share: 50}, {Email: partner.email, Share: 50}}, property(childId).Owners)
+	}
+	assert.Equal(t, 60.0, property("p1a").Size)
+	err = stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.BuyProperty(ctx, "p1", partner.email, owner.email)
+		return err
+	})
+	assert.ErrorContains(t, err, "retired by a Subdivision")
+
+	merge := func(client testClient, propertyIds ...string) (string, error) {
+		var status string
+		err := stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			status, err = r.MergeProperties(ctx, propertyIds, part("m1", 0), client.email)
+			return err
+		})
+		return status, err
+	}
+	_, err = merge(owner, "p1a", "p2")
+	assert.ErrorContains(t, err, "same owners")
+	_, err = merge(owner, "p1a", "p1")
+	assert.ErrorContains(t, err, "property p1 is retired")
+	status, err = merge(owner, "p1a", "p1b")
+	require.NoError(t, err)
+	assert.Equal(t, ConsentPending, status)
+	status, err = merge(partner, "p1a", "p1b")
+	require.NoError(t, err)
+	assert.Equal(t, ConsentApproved, status)
+	assert.Equal(t, 100.0, property("m1").Size)
+
+	var lineage []LineageLink
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		lineage, err = r.GetLineage(ctx, "p1a")
+		return err
+	})
+	require.Len(t, lineage, 2)
+	relations := map[string]string{}
+	for _, link := range lineage {
+		relations[link.Relation] = link.PropertyId + " " + link.Event
+	}
+	assert.Equal(t, map[string]string{LineageParent: "p1 " + EventSubdivision, LineageChild: "m1 " + EventMerge}, relations)
+}
@@ -0,0 +1,261 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// listingCompositeKey holds the window of a property's current or upcoming listing. Times are
+// RFC3339 in UTC and compared against transaction timestamps, never the peer's clock.
+const listingCompositeKey = "listing~propertyId~startsAt~endsAt~status"
+
+const (
+	ListingScheduled = "Scheduled"
+	ListingLive      = "Live"
+
+	// defaultListingPeriod applies to listings made through UpdateFlag, which takes no end time.
+	defaultListingPeriod = 90 * 24 * time.Hour
+)
+
+type listing struct {
+	propertyId string
+	startsAt   time.Time
+	endsAt     time.Time
+	status     string
+	key        string
+}
+
+// ScheduleListing lists a property for sale between startsAt and endsAt. A start time in the
+// past lists it straight away; otherwise StartScheduledListings puts it live once it is due.
+func (r *RealEstate) ScheduleListing(ctx contractapi.TransactionContextInterface, propertyId string, ownerEmail string, startsAt string, endsAt string) (string, error) {
+	start, err := time.Parse(time.RFC3339, startsAt)
+	if err != nil {
+		return "", invalidArgument("invalid start time: %s", startsAt)
+	}
+	end, err := time.Parse(time.RFC3339, endsAt)
+	if err != nil {
+		return "", invalidArgument("invalid end time: %s", endsAt)
+	}
+	now, err := txTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !end.After(start) || !end.After(now) {
+		return "", invalidArgument("end time must be after the start time and in the future")
+	}
+	property, propertyKey, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if property.IsListed {
+		return "", invalidArgument("property already listed for sale")
+	}
+	if property.Retired {
+		return "", invalidArgument("cannot list a retired property")
+	}
+	if property.Frozen {
+		return "", invalidArgument("property is frozen by a legal hold")
+	}
+	current, err := r.getListing(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if current != nil {
+		return "", invalidArgument("property already has a scheduled listing")
+	}
+	action := ConsentList + ":" + start.UTC().Format(time.RFC3339) + "/" + end.UTC().Format(time.RFC3339)
+	approved, err := r.recordConsent(ctx, propertyId, property.OwnerEmail, action, ownerEmail)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return ConsentPending, nil
+	}
+	if start.After(now) {
+		if err := r.putListing(ctx, propertyId, start, end, ListingScheduled); err != nil {
+			return "", err
+		}
+		return ListingScheduled, nil
+	}
+	if err := r.setListed(ctx, property, propertyKey, true); err != nil {
+		return "", err
+	}
+	if err := r.putListing(ctx, propertyId, now, end, ListingLive); err != nil {
+		return "", err
+	}
+	return ListingLive, nil
+}
+
+// StartScheduledListings puts every scheduled listing whose start time has passed live and
+// returns the ids of the properties it listed. Listings of properties that were frozen or
+// retired in the meantime stay scheduled until they expire.
+func (r *RealEstate) StartScheduledListings(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listings, err := r.getListings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var started []string
+	for _, scheduled := range listings {
+		if scheduled.status != ListingScheduled || scheduled.startsAt.After(now) || !scheduled.endsAt.After(now) {
+			continue
+		}
+		property, propertyKey, err := r.getProperty(ctx, scheduled.propertyId)
+		if err != nil {
+			return nil, err
+		}
+		if property.Retired || property.Frozen {
+			continue
+		}
+		if !property.IsListed {
+			if err := r.setListed(ctx, property, propertyKey, true); err != nil {
+				return nil, err
+			}
+		}
+		if err := ctx.GetStub().DelState(scheduled.key); err != nil {
+			return nil, errors.New("failed to delete old listing state")
+		}
+		if err := r.putListing(ctx, scheduled.propertyId, scheduled.startsAt, scheduled.endsAt, ListingLive); err != nil {
+			return nil, err
+		}
+		started = append(started, scheduled.propertyId)
+	}
+	return started, nil
+}
+
+// ExpireListings unlists every property whose listing has run past its end time and drops
+// scheduled listings that never went live. Properties under auction are left alone until the
+// auction closes. It returns the ids of the properties it unlisted.
+func (r *RealEstate) ExpireListings(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listings, err := r.getListings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var expired []string
+	for _, overdue := range listings {
+		if overdue.endsAt.After(now) {
+			continue
+		}
+		auction, err := r.openAuctionFor(ctx, overdue.propertyId)
+		if err != nil {
+			return nil, err
+		}
+		if auction != nil {
+			continue
+		}
+		if err := ctx.GetStub().DelState(overdue.key); err != nil {
+			return nil, errors.New("failed to delete old listing state")
+		}
+		if overdue.status != ListingLive {
+			continue
+		}
+		property, propertyKey, err := r.getProperty(ctx, overdue.propertyId)
+		if err != nil {
+			return nil, err
+		}
+		if property.IsListed {
+			if err := r.setListed(ctx, property, propertyKey, false); err != nil {
+				return nil, err
+			}
+			expired = append(expired, overdue.propertyId)
+		}
+	}
+	return expired, nil
+}
+
+// clearListing drops the listing window of a property that is no longer for sale.
+func (r *RealEstate) clearListing(ctx contractapi.TransactionContextInterface, propertyId string) error {
+	current, err := r.getListing(ctx, propertyId)
+	if err != nil || current == nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(current.key); err != nil {
+		return errors.New("failed to delete old listing state")
+	}
+	return nil
+}
+
+func (r *RealEstate) setListed(ctx contractapi.TransactionContextInterface, property *Property, propertyKey string, isListed bool) error {
+	err := ctx.GetStub().DelState(propertyKey)
+	if err != nil {
+		return errors.New("failed to delete old property state")
+	}
+	err = r.putProperty(ctx, property.Id, property.Title, property.Location, formatAmount(property.Size), property.OwnerEmail, formatMoney(property.Price, property.Currency), strconv.FormatBool(isListed))
+	if err != nil {
+		return err
+	}
+	if isListed && !property.IsListed {
+		if err := putPriceRecord(ctx, property.Id, PriceListed, formatMoney(property.Price, property.Currency)); err != nil {
+			return err
+		}
+	}
+	return countListing(ctx, property.IsListed, isListed)
+}
+
+func (r *RealEstate) getListing(ctx contractapi.TransactionContextInterface, propertyId string) (*listing, error) {
+	listings, err := r.queryListings(ctx, []string{"listing", propertyId})
+	if err != nil || len(listings) == 0 {
+		return nil, err
+	}
+	return &listings[0], nil
+}
+
+func (r *RealEstate) getListings(ctx contractapi.TransactionContextInterface) ([]listing, error) {
+	return r.queryListings(ctx, []string{"listing"})
+}
+
+func (r *RealEstate) queryListings(ctx contractapi.TransactionContextInterface, attributes []string) ([]listing, error) {
+	var listings []listing
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(listingCompositeKey, attributes)
+	if err != nil {
+		return nil, errors.New("failed to get listings")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over listings")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		start, err := time.Parse(time.RFC3339, keyParts[2])
+		if err != nil {
+			return nil, err
+		}
+		end, err := time.Parse(time.RFC3339, keyParts[3])
+		if err != nil {
+			return nil, err
+		}
+		listings = append(listings, listing{propertyId: keyParts[1], startsAt: start, endsAt: end, status: keyParts[4], key: queryResponse.Key})
+	}
+	return listings, nil
+}
+
+func (r *RealEstate) putListing(ctx contractapi.TransactionContextInterface, propertyId string, startsAt time.Time, endsAt time.Time, status string) error {
+	listingKey, err := ctx.GetStub().CreateCompositeKey(listingCompositeKey, []string{"listing", propertyId, startsAt.UTC().Format(time.RFC3339), endsAt.UTC().Format(time.RFC3339), status})
+	if err != nil {
+		log.Println("failed to create composite key for listing")
+		return errors.New("failed to create composite key for listing")
+	}
+	err = ctx.GetStub().PutState(listingKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put listing in world state")
+		return errors.New("failed to put listing in world state")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledListings(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	operator := testClient{email: "scheduler@example.com"}
+	r := new(RealEstate)
+	start := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	for _, propertyId := range []string{"p1", "p2", "p3"} {
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Plot", "Nyeri", 10, owner.email, 100000, "USD", false, "")
+		})
+	}
+	schedule := func(propertyId string, from time.Time, to time.Time) (string, error) {
+		var status string
+		err := stub.invoke(owner, start, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			status, err = r.ScheduleListing(ctx, propertyId, owner.email, from.Format(time.RFC3339), to.Format(time.RFC3339))
+			return err
+		})
+		return status, err
+	}
+	job := func(run func(ctx contractapi.TransactionContextInterface) ([]string, error), at time.Time) []string {
+		var propertyIds []string
+		require.NoError(t, stub.invoke(operator, at, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			propertyIds, err = run(ctx)
+			return err
+		}))
+		return propertyIds
+	}
+	listed := func() int {
+		var summary *RegistrySummary
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			summary, err = r.GetRegistrySummary(ctx)
+			return err
+		})
+		return summary.Listed
+	}
+
+	status, err := schedule("p1", start.Add(day), start.Add(8*day))
+	require.NoError(t, err)
+	assert.Equal(t, ListingScheduled, status)
+	_, err = schedule("p1", start.Add(2*day), start.Add(4*day))
+	assert.ErrorContains(t, err, "already has a scheduled listing")
+	_, err = schedule("p2", start.Add(-day), start.Add(-time.Hour))
+	assert.ErrorContains(t, err, ErrInvalidArgument, "a window that has already ended")
+	for _, propertyId := range []string{"p2", "p3"} {
+		status, err = schedule(propertyId, start.Add(-time.Hour), start.Add(2*day))
+		require.NoError(t, err)
+		assert.Equal(t, ListingLive, status, "a start in the past lists straight away")
+	}
+	assert.Equal(t, 2, listed())
+
+	// An auction running past the end of p3's listing keeps it listed until the auction closes.
+	require.NoError(t, stub.invoke(owner, start, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.CreateAuction(ctx, "a1", "p3", owner.email, start.Add(5*day).Format(time.RFC3339))
+		return err
+	}))
+
+	assert.Empty(t, job(r.StartScheduledListings, start.Add(12*time.Hour)))
+	assert.Equal(t, []string{"p1"}, job(r.StartScheduledListings, start.Add(day)))
+	assert.Empty(t, job(r.StartScheduledListings, start.Add(day+time.Hour)), "listings start once")
+	assert.Equal(t, 3, listed())
+
+	assert.Equal(t, []string{"p2"}, job(r.ExpireListings, start.Add(3*day)))
+	assert.Equal(t, 2, listed())
+	require.NoError(t, stub.invoke(owner, start.Add(6*day), func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.CloseAuction(ctx, "a1")
+		return err
+	}))
+	assert.ElementsMatch(t, []string{"p1", "p3"}, job(r.ExpireListings, start.Add(9*day)))
+	assert.Equal(t, 0, listed())
+}
@@ -0,0 +1,126 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Prices and sale amounts are integers in the minor unit of an ISO 4217 currency, cents for USD,
+// so they add up exactly. Keys hold an amount as "<minor units> <currency>", for example
+// "12500000 USD". Records written before currencies were introduced hold a decimal such as
+// "125000.00" in defaultCurrency; they are upgraded like any older schema version.
+const defaultCurrency = "USD"
+
+// currencies are the accepted ISO 4217 codes and the number of digits of their minor unit.
+var currencies = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"INR": 2,
+	"KES": 2,
+	"JPY": 0,
+	"BHD": 3,
+}
+
+// Currency is an accepted currency and the number of digits of its minor unit. Default marks the
+// currency of amounts recorded before currencies were introduced.
+type Currency struct {
+	Code    string `json:"code"`
+	Digits  int    `json:"digits"`
+	Default bool   `json:"default"`
+}
+
+// GetCurrencies lists the accepted currencies by code, so that clients convert amounts with the
+// same minor units as the chaincode.
+func (r *RealEstate) GetCurrencies(ctx contractapi.TransactionContextInterface) ([]Currency, error) {
+	list := make([]Currency, 0, len(currencies))
+	for code, digits := range currencies {
+		list = append(list, Currency{Code: code, Digits: digits, Default: code == defaultCurrency})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list, nil
+}
+
+func validateCurrency(currency string) error {
+	if _, ok := currencies[currency]; !ok {
+		return invalidArgument("unsupported currency %q", currency)
+	}
+	return nil
+}
+
+// validateMoney checks that name is a positive amount in an accepted currency.
+func validateMoney(name string, amount int64, currency string) error {
+	if amount <= 0 {
+		return invalidArgument("%s must be a positive amount", name)
+	}
+	return validateCurrency(currency)
+}
+
+func formatMoney(amount int64, currency string) string {
+	return strconv.FormatInt(amount, 10) + " " + currency
+}
+
+// parseMoney reads an amount written by formatMoney, or a legacy decimal in defaultCurrency.
+func parseMoney(value string) (int64, string, error) {
+	units, currency, found := strings.Cut(value, " ")
+	if !found {
+		amount, err := legacyMinorUnits(value)
+		return amount, defaultCurrency, err
+	}
+	amount, err := strconv.ParseInt(units, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid amount: %s", value)
+	}
+	return amount, currency, nil
+}
+
+// isLegacyMoney reports whether value was written before currencies were introduced.
+func isLegacyMoney(value string) bool {
+	return value != "" && !strings.Contains(value, " ")
+}
+
+// legacyMinorUnits converts a decimal amount of defaultCurrency into minor units. Legacy amounts
+// were floats rounded to the minor unit, so rounding recovers them exactly.
+func legacyMinorUnits(value string) (int64, error) {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", value)
+	}
+	return minorUnits(amount, defaultCurrency), nil
+}
+
+// minorUnits rounds a decimal amount of currency to its minor unit.
+func minorUnits(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(currencies[currency])))
+}
+
+// UnmarshalJSON also reads sale details stored before currencies were introduced, whose amount
+// is a decimal in defaultCurrency.
+func (s *SalePrivateDetails) UnmarshalJSON(data []byte) error {
+	type saleDetails SalePrivateDetails
+	var details struct {
+		saleDetails
+		Amount json.Number `json:"amount"`
+	}
+	if err := json.Unmarshal(data, &details); err != nil {
+		return err
+	}
+	*s = SalePrivateDetails(details.saleDetails)
+	if details.Amount == "" {
+		return nil
+	}
+	var err error
+	if s.Currency == "" {
+		s.Currency = defaultCurrency
+		s.Amount, err = legacyMinorUnits(details.Amount.String())
+		return err
+	}
+	s.Amount, err = details.Amount.Int64()
+	return err
+}
@@ -0,0 +1,202 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		currency string
+		want     string
+	}{
+		{12500000, "USD", "12500000 USD"},
+		{0, "EUR", "0 EUR"},
+		{1500, "JPY", "1500 JPY"},
+		{-250, "GBP", "-250 GBP"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, formatMoney(test.amount, test.currency))
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantAmount   int64
+		wantCurrency string
+		wantErr      bool
+	}{
+		{name: "minor units", value: "12500000 USD", wantAmount: 12500000, wantCurrency: "USD"},
+		{name: "currency without minor unit", value: "1500 JPY", wantAmount: 1500, wantCurrency: "JPY"},
+		{name: "three digit minor unit", value: "1250 BHD", wantAmount: 1250, wantCurrency: "BHD"},
+		{name: "legacy decimal", value: "125000.00", wantAmount: 12500000, wantCurrency: defaultCurrency},
+		{name: "legacy decimal below one unit", value: "0.1", wantAmount: 10, wantCurrency: defaultCurrency},
+		{name: "legacy decimal with one digit", value: "125000.5", wantAmount: 12500050, wantCurrency: defaultCurrency},
+		{name: "invalid minor units", value: "12.5 USD", wantErr: true},
+		{name: "invalid legacy decimal", value: "abc", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amount, currency, err := parseMoney(test.value)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAmount, amount)
+			assert.Equal(t, test.wantCurrency, currency)
+		})
+	}
+}
+
+func TestParseMoneyRoundTrip(t *testing.T) {
+	for _, currency := range []string{"USD", "JPY", "BHD"} {
+		amount, parsed, err := parseMoney(formatMoney(987654321, currency))
+		require.NoError(t, err)
+		assert.Equal(t, int64(987654321), amount)
+		assert.Equal(t, currency, parsed)
+	}
+}
+
+func TestValidateMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		currency string
+		wantErr  bool
+	}{
+		{name: "valid", amount: 100, currency: "KES"},
+		{name: "zero", amount: 0, currency: "USD", wantErr: true},
+		{name: "negative", amount: -1, currency: "USD", wantErr: true},
+		{name: "unknown currency", amount: 100, currency: "XYZ", wantErr: true},
+		{name: "lower case currency", amount: 100, currency: "usd", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateMoney("price", test.amount, test.currency)
+			if !test.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			var chaincodeErr *Error
+			require.ErrorAs(t, err, &chaincodeErr)
+			assert.Equal(t, ErrInvalidArgument, chaincodeErr.Code)
+		})
+	}
+}
+
+func TestSalePrivateDetailsUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want SalePrivateDetails
+	}{
+		{
+			name: "current",
+			data: `{"amount":12500000,"currency":"EUR","total_tax":1250}`,
+			want: SalePrivateDetails{Amount: 12500000, Currency: "EUR", TotalTax: 1250},
+		},
+		{
+			name: "legacy decimal",
+			data: `{"amount":125000.5}`,
+			want: SalePrivateDetails{Amount: 12500050, Currency: defaultCurrency},
+		},
+		{
+			name: "no amount",
+			data: `{"currency":"USD"}`,
+			want: SalePrivateDetails{Currency: "USD"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sale SalePrivateDetails
+			require.NoError(t, json.Unmarshal([]byte(test.data), &sale))
+			assert.Equal(t, test.want, sale)
+		})
+	}
+}
+
+func TestSalesInSeveralCurrencies(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	admin := testClient{email: "admin@example.com", role: roleAdmin}
+	auditor := testClient{email: "auditor@example.com", role: roleAuditor}
+	r := new(RealEstate)
+	at := time.Date(2026, 7, 14, 12, 0, 0, 0, time.UTC)
+
+	schedules := []struct {
+		jurisdiction string
+		currency     string
+		brackets     []TaxBracket
+	}{
+		{"nairobi", "KES", []TaxBracket{{Min: 0, Max: 100000000, Rate: 1}, {Min: 100000000, Max: 0, Rate: 4}}},
+		{DefaultJurisdiction, "USD", []TaxBracket{{Rate: 2}}},
+		// Setting the yen schedule leaves the dollar schedule of the same jurisdiction in place.
+		{DefaultJurisdiction, "JPY", []TaxBracket{{Rate: 2.5}}},
+	}
+	for _, schedule := range schedules {
+		stub.mustInvoke(admin, func(ctx contractapi.TransactionContextInterface) error {
+			return r.SetTaxSchedule(ctx, schedule.jurisdiction, schedule.currency, schedule.brackets)
+		})
+	}
+
+	err := stub.invoke(owner, at, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p0", "Plot", "nairobi", 10, owner.email, 100, "usd", true, "")
+	})
+	assert.ErrorContains(t, err, ErrInvalidArgument)
+
+	sales := []struct {
+		propertyId string
+		location   string
+		price      int64
+		currency   string
+		wantDuty   int64
+	}{
+		// KES 2,500,000.00: 1% of the first million and 4% of the rest.
+		{"p1", "nairobi", 250000000, "KES", 1000000 + 6000000},
+		// The yen has no minor unit, so duty rounds to whole yen.
+		{"p2", "osaka", 1234567, "JPY", 30864},
+		{"p3", "osaka", 99999, "USD", 2000},
+	}
+	for _, sale := range sales {
+		require.NoError(t, stub.invoke(owner, at, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, sale.propertyId, "Plot", sale.location, 10, owner.email, sale.price, sale.currency, true, "")
+		}))
+		require.NoError(t, stub.invoke(owner, at, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := r.BuyProperty(ctx, sale.propertyId, buyer.email, owner.email)
+			return err
+		}))
+		var transactions []Transaction
+		stub.mustInvoke(auditor, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			transactions, err = r.GetTransactionsByProperty(ctx, sale.propertyId)
+			return err
+		})
+		require.Len(t, transactions, 1)
+		assert.Equal(t, sale.price, transactions[0].Amount, sale.propertyId)
+		assert.Equal(t, sale.currency, transactions[0].Currency, sale.propertyId)
+		assert.Equal(t, sale.wantDuty, transactions[0].TotalTax, sale.propertyId)
+	}
+
+	// Amounts in different currencies are never added together.
+	var report []TaxReportEntry
+	stub.mustInvoke(auditor, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		report, err = r.GetTaxReport(ctx, "2026-07-01", "2026-07-31")
+		return err
+	})
+	assert.ElementsMatch(t, []TaxReportEntry{
+		{Period: "2026-07", Jurisdiction: "nairobi", Currency: "KES", Transactions: 1, SalesVolume: 250000000, TotalDuty: 7000000},
+		{Period: "2026-07", Jurisdiction: DefaultJurisdiction, Currency: "JPY", Transactions: 1, SalesVolume: 1234567, TotalDuty: 30864},
+		{Period: "2026-07", Jurisdiction: DefaultJurisdiction, Currency: "USD", Transactions: 1, SalesVolume: 99999, TotalDuty: 2000},
+	}, report)
+}
@@ -0,0 +1,376 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+type Owner struct {
+	Email string  `json:"email"`
+	Share float64 `json:"share"`
+}
+
+// Shares are stored as basis points (1/100 of a percent) so that they always sum to exactly 100%.
+const fullShare = 10000
+
+const ownershipCompositeKey = "ownership~propertyId~ownerEmail~share"
+const consentCompositeKey = "consent~propertyId~action~ownerEmail"
+const consentPolicyCompositeKey = "consentpolicy~propertyId~threshold"
+
+const (
+	ConsentList          = "list"
+	ConsentSellPrefix    = "sell:"
+	ConsentAuctionPrefix = "auction:"
+	ConsentPolicyPrefix  = "threshold:"
+	ConsentApproved      = "Approved"
+	ConsentPending       = "PendingConsent"
+)
+
+type shareholding struct {
+	email       string
+	basisPoints int
+	key         string
+}
+
+// TransferShare moves share percent of the property from fromEmail to toEmail. Like a sale, it is
+// refused while the property is retired, frozen, encumbered by a lien or under auction.
+func (r *RealEstate) TransferShare(ctx contractapi.TransactionContextInterface, propertyId string, fromEmail string, toEmail string, share string) error {
+	if fromEmail == toEmail {
+		return invalidArgument("cannot transfer a share to the same owner")
+	}
+	basisPoints, err := parseShare(share)
+	if err != nil {
+		return err
+	}
+	property, propertyKey, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	if property.Retired {
+		return invalidArgument("cannot transfer shares of a retired property")
+	}
+	if property.Frozen {
+		return invalidArgument("cannot transfer shares of a frozen property")
+	}
+	if err := r.ensureTransferable(ctx, propertyId); err != nil {
+		return err
+	}
+	holdings, err := r.getShareholdings(ctx, propertyId, property.OwnerEmail)
+	if err != nil {
+		return err
+	}
+	var from, to *shareholding
+	for i := range holdings {
+		switch holdings[i].email {
+		case fromEmail:
+			from = &holdings[i]
+		case toEmail:
+			to = &holdings[i]
+		}
+	}
+	if from == nil {
+		return invalidArgument("sender is not an owner of the property")
+	}
+	if from.basisPoints < basisPoints {
+		return invalidArgument("sender only holds %.2f%% of the property", float64(from.basisPoints)/100)
+	}
+	if err := r.deleteShareholding(ctx, propertyId, *from); err != nil {
+		return err
+	}
+	if from.basisPoints > basisPoints {
+		if err := r.putOwnership(ctx, propertyId, fromEmail, from.basisPoints-basisPoints); err != nil {
+			return err
+		}
+	}
+	received := basisPoints
+	if to != nil {
+		if err := r.deleteShareholding(ctx, propertyId, *to); err != nil {
+			return err
+		}
+		received += to.basisPoints
+	}
+	if err := r.putOwnership(ctx, propertyId, toEmail, received); err != nil {
+		return err
+	}
+	// Pending consents were given under the old share split, so they no longer count.
+	if err := r.clearConsents(ctx, propertyId, ""); err != nil {
+		return err
+	}
+	if property.OwnerEmail == fromEmail && from.basisPoints == basisPoints {
+		err = ctx.GetStub().DelState(propertyKey)
+		if err != nil {
+			return errors.New("failed to delete old property state")
+		}
+		return r.putProperty(ctx, propertyId, property.Title, property.Location, strconv.FormatFloat(property.Size, 'f', 2, 64), toEmail, formatMoney(property.Price, property.Currency), strconv.FormatBool(property.IsListed))
+	}
+	return nil
+}
+
+func (r *RealEstate) SetConsentThreshold(ctx contractapi.TransactionContextInterface, propertyId string, threshold string, ownerEmail string) (string, error) {
+	basisPoints, err := parseShare(threshold)
+	if err != nil {
+		return "", err
+	}
+	if basisPoints <= fullShare/2 {
+		return "", invalidArgument("consent threshold must be a majority above 50%%")
+	}
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	// Changing the policy itself always needs every co-owner to agree.
+	approved, err := r.recordConsentWithThreshold(ctx, propertyId, property.OwnerEmail, ConsentPolicyPrefix+strconv.Itoa(basisPoints), ownerEmail, fullShare)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return ConsentPending, nil
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(consentPolicyCompositeKey, []string{"consentpolicy", propertyId})
+	if err != nil {
+		return "", errors.New("failed to read consent policy from world state")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return "", errors.New("failed to iterate over consent policies")
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return "", errors.New("failed to delete old consent policy")
+		}
+	}
+	policyKey, err := ctx.GetStub().CreateCompositeKey(consentPolicyCompositeKey, []string{"consentpolicy", propertyId, strconv.Itoa(basisPoints)})
+	if err != nil {
+		return "", errors.New("failed to create composite key for consent policy")
+	}
+	err = ctx.GetStub().PutState(policyKey, []byte{0x00})
+	if err != nil {
+		return "", errors.New("failed to put consent policy in world state")
+	}
+	return ConsentApproved, nil
+}
+
+func (r *RealEstate) GetOwners(ctx contractapi.TransactionContextInterface, propertyId string) ([]Owner, error) {
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	return property.Owners, nil
+}
+
+// loadOwnership fills in the co-owners and consent threshold of a property read from its composite key.
+func (r *RealEstate) loadOwnership(ctx contractapi.TransactionContextInterface, property *Property) error {
+	holdings, err := r.getShareholdings(ctx, property.Id, property.OwnerEmail)
+	if err != nil {
+		return err
+	}
+	property.Owners = nil
+	for _, holding := range holdings {
+		property.Owners = append(property.Owners, Owner{Email: holding.email, Share: float64(holding.basisPoints) / 100})
+	}
+	threshold, err := r.getConsentThreshold(ctx, property.Id)
+	if err != nil {
+		return err
+	}
+	property.ConsentThreshold = float64(threshold) / 100
+	return nil
+}
+
+// getShareholdings returns the recorded shares of a property. Properties registered before
+// co-ownership existed have no ownership keys and are treated as wholly owned by ownerEmail.
+func (r *RealEstate) getShareholdings(ctx contractapi.TransactionContextInterface, propertyId string, ownerEmail string) ([]shareholding, error) {
+	var holdings []shareholding
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(ownershipCompositeKey, []string{"ownership", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get owners")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over owners")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		basisPoints, err := strconv.Atoi(keyParts[3])
+		if err != nil {
+			return nil, err
+		}
+		holdings = append(holdings, shareholding{email: keyParts[2], basisPoints: basisPoints, key: queryResponse.Key})
+	}
+	if len(holdings) == 0 {
+		holdings = append(holdings, shareholding{email: ownerEmail, basisPoints: fullShare})
+	}
+	return holdings, nil
+}
+
+func (r *RealEstate) getConsentThreshold(ctx contractapi.TransactionContextInterface, propertyId string) (int, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(consentPolicyCompositeKey, []string{"consentpolicy", propertyId})
+	if err != nil {
+		return 0, errors.New("failed to read consent policy from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return fullShare, nil
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return 0, errors.New("failed to iterate over consent policies")
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+	if splitKeyErr != nil {
+		return 0, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	return strconv.Atoi(keyParts[2])
+}
+
+// recordConsent registers ownerEmail's agreement to action and reports whether the co-owners
+// who have agreed so far hold enough of the property to meet its consent threshold.
+func (r *RealEstate) recordConsent(ctx contractapi.TransactionContextInterface, propertyId string, primaryOwner string, action string, ownerEmail string) (bool, error) {
+	threshold, err := r.getConsentThreshold(ctx, propertyId)
+	if err != nil {
+		return false, err
+	}
+	return r.recordConsentWithThreshold(ctx, propertyId, primaryOwner, action, ownerEmail, threshold)
+}
+
+func (r *RealEstate) recordConsentWithThreshold(ctx contractapi.TransactionContextInterface, propertyId string, primaryOwner string, action string, ownerEmail string, threshold int) (bool, error) {
+	holdings, err := r.getShareholdings(ctx, propertyId, primaryOwner)
+	if err != nil {
+		return false, err
+	}
+	shares := make(map[string]int)
+	for _, holding := range holdings {
+		shares[holding.email] = holding.basisPoints
+	}
+	if _, ok := shares[ownerEmail]; !ok {
+		return false, permissionDenied("only an owner of the property can give consent")
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(consentCompositeKey, []string{"consent", propertyId, action})
+	if err != nil {
+		return false, errors.New("failed to get consents")
+	}
+	defer resultIterator.Close()
+	consented := map[string]bool{ownerEmail: true}
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return false, errors.New("failed to iterate over consents")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return false, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		consented[keyParts[3]] = true
+	}
+	total := 0
+	for email := range consented {
+		total += shares[email]
+	}
+	if total >= threshold {
+		return true, r.clearConsents(ctx, propertyId, action)
+	}
+	consentKey, err := ctx.GetStub().CreateCompositeKey(consentCompositeKey, []string{"consent", propertyId, action, ownerEmail})
+	if err != nil {
+		return false, errors.New("failed to create composite key for consent")
+	}
+	err = ctx.GetStub().PutState(consentKey, []byte{0x00})
+	if err != nil {
+		return false, errors.New("failed to put consent in world state")
+	}
+	return false, nil
+}
+
+// clearConsents removes recorded consents for one action, or for every action when action is empty.
+func (r *RealEstate) clearConsents(ctx contractapi.TransactionContextInterface, propertyId string, action string) error {
+	attributes := []string{"consent", propertyId}
+	if action != "" {
+		attributes = append(attributes, action)
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(consentCompositeKey, attributes)
+	if err != nil {
+		return errors.New("failed to get consents")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return errors.New("failed to iterate over consents")
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return errors.New("failed to delete consent")
+		}
+	}
+	return nil
+}
+
+// setSoleOwner replaces every recorded share of the property with a single 100% holding.
+func (r *RealEstate) setSoleOwner(ctx contractapi.TransactionContextInterface, propertyId string, ownerEmail string) error {
+	return r.setOwners(ctx, propertyId, ownerEmail, []shareholding{{email: ownerEmail, basisPoints: fullShare}})
+}
+
+// setOwners replaces every recorded share of the property, whose primary owner is ownerEmail,
+// with holdings.
+func (r *RealEstate) setOwners(ctx contractapi.TransactionContextInterface, propertyId string, ownerEmail string, holdings []shareholding) error {
+	current, err := r.getShareholdings(ctx, propertyId, ownerEmail)
+	if err != nil {
+		return err
+	}
+	for _, holding := range current {
+		if err := r.deleteShareholding(ctx, propertyId, holding); err != nil {
+			return err
+		}
+	}
+	if err := r.clearConsents(ctx, propertyId, ""); err != nil {
+		return err
+	}
+	for _, holding := range holdings {
+		if err := r.putOwnership(ctx, propertyId, holding.email, holding.basisPoints); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RealEstate) putOwnership(ctx contractapi.TransactionContextInterface, propertyId string, ownerEmail string, basisPoints int) error {
+	if err := putKey(ctx, ownershipCompositeKey, []byte{0x00}, "ownership", propertyId, ownerEmail, strconv.Itoa(basisPoints)); err != nil {
+		return err
+	}
+	return r.putIndex(ctx, ownerIndexCompositeKey, "owner", ownerEmail, propertyId)
+}
+
+func (r *RealEstate) deleteShareholding(ctx contractapi.TransactionContextInterface, propertyId string, holding shareholding) error {
+	if err := r.deleteIndex(ctx, ownerIndexCompositeKey, "owner", holding.email, propertyId); err != nil {
+		return err
+	}
+	// Implicit holdings of legacy properties have no key of their own.
+	if holding.key == "" {
+		return nil
+	}
+	if err := ctx.GetStub().DelState(holding.key); err != nil {
+		return errors.New("failed to delete old ownership state")
+	}
+	return nil
+}
+
+// parseShare converts a percentage such as "12.5" into basis points.
+func parseShare(share string) (int, error) {
+	percentage, err := strconv.ParseFloat(strings.TrimSpace(share), 64)
+	if err != nil {
+		return 0, invalidArgument("invalid share: %s", share)
+	}
+	basisPoints := int(math.Round(percentage * 100))
+	if basisPoints <= 0 || basisPoints > fullShare {
+		return 0, invalidArgument("share must be between 0 and 100, got %s", share)
+	}
+	return basisPoints, nil
+}
@@ -0,0 +1,62 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferShare(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	partner := testClient{email: "partner@example.com"}
+	lender := testClient{email: "bank@example.com", role: roleLender}
+	r := new(RealEstate)
+
+	for _, propertyId := range []string{"p1", "p2"} {
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Plot", "Nairobi", 10, owner.email, 100, "USD", true, "")
+		})
+	}
+	transfer := func(propertyId string, share string) error {
+		return stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			return r.TransferShare(ctx, propertyId, owner.email, partner.email, share)
+		})
+	}
+	owners := func(propertyId string) []Owner {
+		var owners []Owner
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			owners, err = r.GetOwners(ctx, propertyId)
+			return err
+		})
+		return owners
+	}
+
+	// A lien blocks share transfers just as it blocks sales.
+	stub.mustInvoke(lender, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterLien(ctx, "l1", "p1", 5000, "USD", "1")
+	})
+	err := transfer("p1", "25")
+	assert.ErrorContains(t, err, ErrInvalidArgument)
+	assert.ErrorContains(t, err, "active liens")
+	assert.Equal(t, []Owner{{Email: owner.email, Share: 100}}, owners("p1"))
+	stub.mustInvoke(lender, func(ctx contractapi.TransactionContextInterface) error {
+		return r.ReleaseLien(ctx, "p1", "l1")
+	})
+	require.NoError(t, transfer("p1", "25"))
+	assert.ElementsMatch(t, []Owner{{Email: owner.email, Share: 75}, {Email: partner.email, Share: 25}}, owners("p1"))
+
+	// So does an open auction.
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.CreateAuction(ctx, "a1", "p2", owner.email, time.Now().Add(time.Hour).Format(time.RFC3339))
+		return err
+	})
+	err = transfer("p2", "25")
+	assert.ErrorContains(t, err, ErrInvalidArgument)
+	assert.ErrorContains(t, err, "under auction a1")
+	assert.Equal(t, []Owner{{Email: owner.email, Share: 100}}, owners("p2"))
+}
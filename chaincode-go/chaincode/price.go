@@ -0,0 +1,126 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Every listing, reprice and sale of a property leaves a price record. Listings and reprices keep
+// the asking price in the key. Sale amounts are private, so a sale's record only names its
+// transaction and the amount is resolved from the private collection for authorized clients.
+const priceCompositeKey = "price~propertyId~date~transactionId~event~price"
+
+const (
+	PriceListed   = "Listed"
+	PriceRepriced = "Repriced"
+	PriceSold     = "Sold"
+
+	ConsentRepricePrefix = "reprice:"
+)
+
+type PriceRecord struct {
+	PropertyId    string `json:"property_id"`
+	Date          string `json:"date"`
+	TransactionId string `json:"transaction_id"`
+	Event         string `json:"event"`
+	Price         int64  `json:"price"`
+	Currency      string `json:"currency"`
+}
+
+// RepriceProperty changes the asking price of a property, in minor units of its currency, once
+// co-owners holding the consent threshold have agreed to the same price.
+func (r *RealEstate) RepriceProperty(ctx contractapi.TransactionContextInterface, propertyId string, price int64, ownerEmail string) (string, error) {
+	property, propertyKey, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if property.Retired {
+		return "", invalidArgument("cannot reprice a retired property")
+	}
+	if property.Frozen {
+		return "", invalidArgument("property is frozen by a legal hold")
+	}
+	if err := validateMoney("price", price, property.Currency); err != nil {
+		return "", err
+	}
+	if price == property.Price {
+		return "", invalidArgument("property is already priced at %d %s", price, property.Currency)
+	}
+	newPrice := formatMoney(price, property.Currency)
+	approved, err := r.recordConsent(ctx, propertyId, property.OwnerEmail, ConsentRepricePrefix+newPrice, ownerEmail)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return ConsentPending, nil
+	}
+	if err := ctx.GetStub().DelState(propertyKey); err != nil {
+		return "", errors.New("failed to delete old property state")
+	}
+	err = r.putProperty(ctx, propertyId, property.Title, property.Location, formatAmount(property.Size), property.OwnerEmail, newPrice, strconv.FormatBool(property.IsListed))
+	if err != nil {
+		return "", err
+	}
+	if err := putPriceRecord(ctx, propertyId, PriceRepriced, newPrice); err != nil {
+		return "", err
+	}
+	return ConsentApproved, nil
+}
+
+// GetPriceHistory returns the price records of a property, oldest first. Sale records carry
+// their amount only for clients allowed to read private data.
+func (r *RealEstate) GetPriceHistory(ctx contractapi.TransactionContextInterface, propertyId string) ([]PriceRecord, error) {
+	if _, _, err := r.getProperty(ctx, propertyId); err != nil {
+		return nil, err
+	}
+	authorized, err := canReadPrivateData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []PriceRecord
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(priceCompositeKey, []string{"price", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get price history")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over price history")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		record := PriceRecord{
+			PropertyId:    keyParts[1],
+			Date:          keyParts[2],
+			TransactionId: keyParts[3],
+			Event:         keyParts[4],
+		}
+		if keyParts[4] == PriceSold {
+			sale, err := r.transactionSale(ctx, keyParts[3], keyParts[5], authorized)
+			if err != nil {
+				return nil, err
+			}
+			record.Price, record.Currency = sale.Amount, sale.Currency
+		} else if record.Price, record.Currency, err = parseMoney(keyParts[5]); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// putPriceRecord records a price event of the current transaction. price is empty for sales.
+func putPriceRecord(ctx contractapi.TransactionContextInterface, propertyId string, event string, price string) error {
+	date, err := lineageDate(ctx)
+	if err != nil {
+		return err
+	}
+	return putKey(ctx, priceCompositeKey, []byte{0x00}, "price", propertyId, date, ctx.GetStub().GetTxID(), event, price)
+}
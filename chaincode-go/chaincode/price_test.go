@@ -0,0 +1,68 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceHistory(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	auditor := testClient{email: "auditor@example.com", role: roleAuditor}
+	r := new(RealEstate)
+	day := time.Date(2026, 2, 2, 8, 0, 0, 0, time.UTC)
+
+	require.NoError(t, stub.invoke(owner, day, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Kitale", 10, owner.email, 100000, "USD", false, "")
+	}))
+	require.NoError(t, stub.invoke(owner, day.Add(24*time.Hour), func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.UpdateFlag(ctx, "p1", owner.email)
+		return err
+	}))
+	require.NoError(t, stub.invoke(owner, day.Add(48*time.Hour), func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.RepriceProperty(ctx, "p1", 90000, owner.email)
+		return err
+	}))
+	stub.TransientMap = map[string][]byte{saleTransientKey: []byte(`{"amount":85000}`)}
+	var saleId string
+	require.NoError(t, stub.invoke(owner, day.Add(72*time.Hour), func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		saleId, err = r.BuyProperty(ctx, "p1", buyer.email, owner.email)
+		return err
+	}))
+	stub.TransientMap = nil
+
+	history := func(client testClient) []PriceRecord {
+		var records []PriceRecord
+		stub.mustInvoke(client, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			records, err = r.GetPriceHistory(ctx, "p1")
+			return err
+		})
+		return records
+	}
+	records := history(auditor)
+	require.Len(t, records, 3)
+	var events []string
+	var prices []int64
+	for _, record := range records {
+		events = append(events, record.Event)
+		prices = append(prices, record.Price)
+		assert.Equal(t, "USD", record.Currency)
+	}
+	assert.Equal(t, []string{PriceListed, PriceRepriced, PriceSold}, events)
+	assert.Equal(t, []int64{100000, 90000, 85000}, prices, "the sale record carries the negotiated amount")
+	assert.Equal(t, saleId, records[2].TransactionId)
+	assert.Equal(t, "2026-02-05 08:00:00", records[2].Date)
+
+	// Asking prices are public, sale amounts are not.
+	records = history(buyer)
+	require.Len(t, records, 3)
+	assert.Equal(t, int64(90000), records[1].Price)
+	assert.Zero(t, records[2].Price)
+}
@@ -0,0 +1,117 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Collection names must match chaincode-go/collections_config.json.
+const (
+	userPrivateCollection        = "userPrivateCollection"
+	transactionPrivateCollection = "transactionPrivateCollection"
+)
+
+// Transient map keys used by clients to pass private fields.
+const (
+	userTransientKey = "user"
+	saleTransientKey = "sale"
+)
+
+// privateDataOrgs are the organizations whose peers hold the private collections.
+var privateDataOrgs = map[string]bool{
+	"Org1MSP": true,
+}
+
+// privateDataRoles are the roles allowed to read private user and sale details.
+var privateDataRoles = map[string]bool{
+	roleAdmin:     true,
+	roleRegistrar: true,
+	roleAuditor:   true,
+}
+
+type UserPrivateDetails struct {
+	Address  string `json:"address"`
+	Contact  string `json:"contact"`
+	Password string `json:"password"`
+}
+
+type SalePrivateDetails struct {
+	Amount       int64     `json:"amount"`
+	Currency     string    `json:"currency"`
+	Jurisdiction string    `json:"jurisdiction,omitempty"`
+	Tax          []TaxLine `json:"tax,omitempty"`
+	TotalTax     int64     `json:"total_tax,omitempty"`
+}
+
+// readTransient decodes the JSON value stored under key in the proposal's transient map.
+func readTransient(ctx contractapi.TransactionContextInterface, key string, value interface{}) error {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return errors.New("failed to read transient data")
+	}
+	data, ok := transientMap[key]
+	if !ok {
+		return invalidArgument("%s must be passed in the transient map", key)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("failed to decode transient %s: %v", key, err)
+	}
+	return nil
+}
+
+// putPrivate writes value to a private data collection and returns the hex SHA-256 of the
+// stored bytes, which callers anchor on the public state.
+func putPrivate(ctx contractapi.TransactionContextInterface, collection string, key string, value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, key, data); err != nil {
+		return nil, fmt.Errorf("failed to put private data in %s", collection)
+	}
+	hash := sha256.Sum256(data)
+	return []byte(hex.EncodeToString(hash[:])), nil
+}
+
+// getPrivate reads value from a private data collection, reporting false when nothing is stored.
+func getPrivate(ctx contractapi.TransactionContextInterface, collection string, key string, value interface{}) (bool, error) {
+	data, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read private data from %s", collection)
+	}
+	if data == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// canReadPrivateData only lets clients with a private data role see private fields, through a
+// peer of their own organization, and only when that organization is a member of the private
+// collections.
+func canReadPrivateData(ctx contractapi.TransactionContextInterface) (bool, error) {
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, errors.New("failed to get client MSP id")
+	}
+	peerMSPID, err := shim.GetMSPID()
+	if err != nil {
+		return false, errors.New("failed to get peer MSP id")
+	}
+	if clientMSPID != peerMSPID || !privateDataOrgs[clientMSPID] {
+		return false, nil
+	}
+	role, err := clientRole(ctx)
+	if err != nil {
+		return false, err
+	}
+	return privateDataRoles[role], nil
+}
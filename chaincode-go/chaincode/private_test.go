@@ -0,0 +1,87 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivateDetails(t *testing.T) {
+	stub := newTestStub(t)
+	seller := testClient{email: "seller@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	auditor := testClient{email: "auditor@example.com", role: roleAuditor}
+	r := new(RealEstate)
+
+	transient := func(key string, value interface{}) {
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+		stub.TransientMap = map[string][]byte{key: data}
+	}
+	register := func() error {
+		return stub.invoke(buyer, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterUser(ctx, "u1", "Buyer", buyer.email)
+		})
+	}
+	assert.ErrorContains(t, register(), "user must be passed in the transient map")
+	transient(userTransientKey, UserPrivateDetails{Address: "12 Moi Avenue", Contact: "+254700000000", Password: "secret"})
+	require.NoError(t, register())
+	stub.TransientMap = nil
+	for key, value := range stub.State {
+		if strings.Contains(key, userCompositeKey) {
+			assert.NotContains(t, key+string(value), "Moi Avenue", "private details stay off the public state")
+		}
+	}
+
+	users := func(client testClient) []User {
+		var users []User
+		stub.mustInvoke(client, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			users, err = r.GetAllUsers(ctx)
+			return err
+		})
+		require.Len(t, users, 1)
+		return users
+	}
+	assert.Empty(t, users(buyer)[0].Address)
+	assert.Equal(t, "12 Moi Avenue", users(auditor)[0].Address)
+
+	// A negotiated price is passed privately and must be in the listing's currency.
+	stub.mustInvoke(seller, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Nairobi", 10, seller.email, 100000, "USD", true, "")
+	})
+	buy := func(amount int64, currency string) error {
+		transient(saleTransientKey, map[string]interface{}{"amount": amount, "currency": currency})
+		defer func() { stub.TransientMap = nil }()
+		return stub.invoke(seller, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := r.BuyProperty(ctx, "p1", buyer.email, seller.email)
+			return err
+		})
+	}
+	assert.ErrorContains(t, buy(95000, "EUR"), "must be in USD")
+	require.NoError(t, buy(95000, "USD"))
+
+	sale := func(client testClient) Transaction {
+		var transactions []Transaction
+		stub.mustInvoke(client, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			transactions, err = r.GetTransactionsByProperty(ctx, "p1")
+			return err
+		})
+		require.Len(t, transactions, 1)
+		return transactions[0]
+	}
+	assert.Zero(t, sale(buyer).Amount)
+	assert.Equal(t, int64(95000), sale(auditor).Amount)
+	assert.Equal(t, "USD", sale(auditor).Currency)
+
+	// Peers of organizations outside the collections never hand out private details.
+	t.Setenv("CORE_PEER_LOCALMSPID", "Org2MSP")
+	assert.Zero(t, sale(auditor).Amount)
+	assert.Empty(t, users(auditor)[0].Address)
+}
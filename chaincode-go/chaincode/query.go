@@ -0,0 +1,277 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Every property and transaction has a JSON query document next to its composite keys so that
+// CouchDB can answer rich queries. The documents only select records; results are always loaded
+// from the composite keys. Their indexes ship in META-INF/statedb/couchdb/indexes.
+const queryDocumentCompositeKey = "querydoc~docType~id"
+
+const (
+	docTypeProperty    = "property"
+	docTypeTransaction = "transaction"
+
+	defaultQueryPageSize = 20
+	maxQueryPageSize     = 100
+)
+
+// QueryCondition is one clause of a rich query: Field compared to Value with Op. Only the
+// fields and operators in propertyQueryFields and transactionQueryFields are accepted, so
+// callers can never hand raw Mango selectors to the state database.
+type QueryCondition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+type PropertyPage struct {
+	Properties []Property `json:"properties" metadata:",optional"`
+	Bookmark   string     `json:"bookmark"`
+	Count      int32      `json:"count"`
+}
+
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions" metadata:",optional"`
+	Bookmark     string        `json:"bookmark"`
+	Count        int32         `json:"count"`
+}
+
+type propertyDocument struct {
+	DocType    string  `json:"docType"`
+	Id         string  `json:"id"`
+	Title      string  `json:"title"`
+	Location   string  `json:"location"`
+	Size       float64 `json:"size"`
+	OwnerEmail string  `json:"ownerEmail"`
+	Price      int64   `json:"price"`
+	Currency   string  `json:"currency"`
+	IsListed   bool    `json:"isListed"`
+	Retired    bool    `json:"retired"`
+}
+
+type transactionDocument struct {
+	DocType     string `json:"docType"`
+	Id          string `json:"id"`
+	PropertyId  string `json:"propertyId"`
+	BuyerEmail  string `json:"buyerEmail"`
+	SellerEmail string `json:"sellerEmail"`
+	Date        string `json:"date"`
+	Status      string `json:"status"`
+}
+
+const (
+	fieldString = "string"
+	fieldNumber = "number"
+	fieldBool   = "bool"
+	fieldDate   = "date"
+)
+
+var queryOperators = map[string]string{
+	"eq":  "$eq",
+	"ne":  "$ne",
+	"lt":  "$lt",
+	"lte": "$lte",
+	"gt":  "$gt",
+	"gte": "$gte",
+}
+
+var propertyQueryFields = map[string]string{
+	"title":      fieldString,
+	"location":   fieldString,
+	"ownerEmail": fieldString,
+	"isListed":   fieldBool,
+	"price":      fieldNumber,
+	"currency":   fieldString,
+	"size":       fieldNumber,
+}
+
+var transactionQueryFields = map[string]string{
+	"propertyId":  fieldString,
+	"buyerEmail":  fieldString,
+	"sellerEmail": fieldString,
+	"status":      fieldString,
+	"date":        fieldDate,
+}
+
+// QueryProperties returns one page of the properties matching every condition. Retired parcels
+// never match. Prices are minor units, so a price condition needs a currency condition too. An
+// empty bookmark starts from the first page; the returned bookmark fetches the next one.
+func (r *RealEstate) QueryProperties(ctx contractapi.TransactionContextInterface, conditions []QueryCondition, pageSize int32, bookmark string) (*PropertyPage, error) {
+	propertyIds, next, count, err := r.runQuery(ctx, docTypeProperty, propertyQueryFields, conditions, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := r.getProperties(ctx, propertyIds)
+	if err != nil {
+		return nil, err
+	}
+	return &PropertyPage{Properties: properties, Bookmark: next, Count: count}, nil
+}
+
+// QueryTransactions returns one page of the transactions matching every condition. Dates compare
+// as "2006-01-02 15:04:05" strings, so a bare day such as "2024-03-01" matches from its start.
+// Each match is read by its id, so a page costs the same however large the ledger is.
+func (r *RealEstate) QueryTransactions(ctx contractapi.TransactionContextInterface, conditions []QueryCondition, pageSize int32, bookmark string) (*TransactionPage, error) {
+	transactionIds, next, count, err := r.runQuery(ctx, docTypeTransaction, transactionQueryFields, conditions, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	if transactionIds == nil {
+		transactionIds = []string{}
+	}
+	transactions, err := r.getTransactions(ctx, transactionIds)
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionPage{Transactions: transactions, Bookmark: next, Count: count}, nil
+}
+
+func (r *RealEstate) runQuery(ctx contractapi.TransactionContextInterface, docType string, fields map[string]string, conditions []QueryCondition, pageSize int32, bookmark string) ([]string, string, int32, error) {
+	if pageSize == 0 {
+		pageSize = defaultQueryPageSize
+	}
+	if pageSize < 0 || pageSize > maxQueryPageSize {
+		return nil, "", 0, invalidArgument("page size must be between 1 and %d", maxQueryPageSize)
+	}
+	selector, err := buildSelector(docType, fields, conditions)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	query, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return nil, "", 0, err
+	}
+	resultIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(query), pageSize, bookmark)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to query %ss: %v", docType, err)
+	}
+	defer resultIterator.Close()
+
+	var ids []string
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("failed to iterate over %ss", docType)
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, "", 0, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		ids = append(ids, keyParts[2])
+	}
+	return ids, metadata.Bookmark, metadata.FetchedRecordsCount, nil
+}
+
+// buildSelector turns whitelisted conditions into a Mango selector. Conditions on the same field
+// are combined, so "price gte 100" and "price lte 500" select a range.
+func buildSelector(docType string, fields map[string]string, conditions []QueryCondition) (map[string]interface{}, error) {
+	selector := map[string]interface{}{"docType": docType}
+	for _, condition := range conditions {
+		kind, ok := fields[condition.Field]
+		if !ok {
+			return nil, invalidArgument("cannot query %ss by %q", docType, condition.Field)
+		}
+		operator, ok := queryOperators[condition.Op]
+		if !ok {
+			return nil, invalidArgument("unknown operator %q", condition.Op)
+		}
+		var value interface{}
+		switch kind {
+		case fieldNumber:
+			number, err := strconv.ParseFloat(condition.Value, 64)
+			if err != nil {
+				return nil, invalidArgument("%s must be a number", condition.Field)
+			}
+			value = number
+		case fieldBool:
+			if operator != "$eq" && operator != "$ne" {
+				return nil, invalidArgument("%s only supports eq and ne", condition.Field)
+			}
+			flag, err := strconv.ParseBool(condition.Value)
+			if err != nil {
+				return nil, invalidArgument("%s must be true or false", condition.Field)
+			}
+			value = flag
+		case fieldDate:
+			if _, err := time.Parse(leaseDateLayout, condition.Value); err != nil {
+				if _, err := time.Parse(transactionDateLayout, condition.Value); err != nil {
+					return nil, invalidArgument("%s must be a date", condition.Field)
+				}
+			}
+			value = condition.Value
+		default:
+			if operator != "$eq" && operator != "$ne" {
+				return nil, invalidArgument("%s only supports eq and ne", condition.Field)
+			}
+			value = condition.Value
+			if condition.Field == "location" {
+				value = normalizeLocation(condition.Value)
+			}
+		}
+		clause, ok := selector[condition.Field].(map[string]interface{})
+		if !ok {
+			clause = make(map[string]interface{})
+			selector[condition.Field] = clause
+		}
+		if _, exists := clause[operator]; exists {
+			return nil, invalidArgument("%s %s is given twice", condition.Field, condition.Op)
+		}
+		clause[operator] = value
+	}
+	if docType == docTypeProperty {
+		if _, ok := selector["price"]; ok {
+			if currency, _ := selector["currency"].(map[string]interface{}); currency["$eq"] == nil {
+				return nil, invalidArgument("price can only be queried together with currency eq")
+			}
+		}
+		// Documents written before retirement was recorded in them have no retired field.
+		selector["$or"] = []interface{}{
+			map[string]interface{}{"retired": false},
+			map[string]interface{}{"retired": map[string]interface{}{"$exists": false}},
+		}
+	}
+	return selector, nil
+}
+
+func (r *RealEstate) putPropertyDocument(ctx contractapi.TransactionContextInterface, propertyId string, title string, location string, size string, ownerEmail string, price string, isListed string, retired bool) error {
+	document := propertyDocument{DocType: docTypeProperty, Id: propertyId, Title: title, Location: normalizeLocation(location), OwnerEmail: ownerEmail, Retired: retired}
+	var err error
+	if document.Size, err = strconv.ParseFloat(size, 64); err != nil {
+		return invalidArgument("invalid size: %s", size)
+	}
+	if document.Price, document.Currency, err = parseMoney(price); err != nil {
+		return err
+	}
+	if document.IsListed, err = strconv.ParseBool(isListed); err != nil {
+		return invalidArgument("invalid listing flag: %s", isListed)
+	}
+	return r.putQueryDocument(ctx, docTypeProperty, propertyId, document)
+}
+
+func (r *RealEstate) putTransactionDocument(ctx contractapi.TransactionContextInterface, keyParts []string) error {
+	document := transactionDocument{
+		DocType:     docTypeTransaction,
+		Id:          keyParts[1],
+		PropertyId:  keyParts[2],
+		BuyerEmail:  keyParts[3],
+		SellerEmail: keyParts[4],
+		Date:        keyParts[6],
+		Status:      keyParts[7],
+	}
+	return r.putQueryDocument(ctx, docTypeTransaction, keyParts[1], document)
+}
+
+func (r *RealEstate) putQueryDocument(ctx contractapi.TransactionContextInterface, docType string, id string, document interface{}) error {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return putKey(ctx, queryDocumentCompositeKey, data, "querydoc", docType, id)
+}
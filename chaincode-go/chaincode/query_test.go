@@ -0,0 +1,231 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// notRetired is the clause buildSelector adds to every property selector.
+var notRetired = []interface{}{
+	map[string]interface{}{"retired": false},
+	map[string]interface{}{"retired": map[string]interface{}{"$exists": false}},
+}
+
+func TestBuildSelector(t *testing.T) {
+	tests := []struct {
+		name       string
+		docType    string
+		conditions []QueryCondition
+		want       map[string]interface{}
+	}{
+		{
+			name:    "no conditions",
+			docType: docTypeTransaction,
+			want:    map[string]interface{}{"docType": docTypeTransaction},
+		},
+		{
+			name:       "retired properties are excluded",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "isListed", Op: "eq", Value: "true"}},
+			want: map[string]interface{}{
+				"docType":  docTypeProperty,
+				"isListed": map[string]interface{}{"$eq": true},
+				"$or":      notRetired,
+			},
+		},
+		{
+			name:       "location is normalized",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "location", Op: "eq", Value: "  Nairobi "}},
+			want: map[string]interface{}{
+				"docType":  docTypeProperty,
+				"location": map[string]interface{}{"$eq": "nairobi"},
+				"$or":      notRetired,
+			},
+		},
+		{
+			name:    "price range in a currency",
+			docType: docTypeProperty,
+			conditions: []QueryCondition{
+				{Field: "price", Op: "gte", Value: "100"},
+				{Field: "price", Op: "lte", Value: "500"},
+				{Field: "currency", Op: "eq", Value: "USD"},
+			},
+			want: map[string]interface{}{
+				"docType":  docTypeProperty,
+				"price":    map[string]interface{}{"$gte": 100.0, "$lte": 500.0},
+				"currency": map[string]interface{}{"$eq": "USD"},
+				"$or":      notRetired,
+			},
+		},
+		{
+			name:       "transaction dates",
+			docType:    docTypeTransaction,
+			conditions: []QueryCondition{{Field: "date", Op: "gte", Value: "2024-03-01"}},
+			want: map[string]interface{}{
+				"docType": docTypeTransaction,
+				"date":    map[string]interface{}{"$gte": "2024-03-01"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields := propertyQueryFields
+			if test.docType == docTypeTransaction {
+				fields = transactionQueryFields
+			}
+			selector, err := buildSelector(test.docType, fields, test.conditions)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, selector)
+		})
+	}
+}
+
+func TestBuildSelectorRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		docType    string
+		conditions []QueryCondition
+		wantErr    string
+	}{
+		{
+			name:       "unknown field",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "password", Op: "eq", Value: "x"}},
+			wantErr:    `cannot query propertys by "password"`,
+		},
+		{
+			name:       "unknown operator",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "title", Op: "regex", Value: ".*"}},
+			wantErr:    `unknown operator "regex"`,
+		},
+		{
+			name:       "range on a string",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "title", Op: "gt", Value: "a"}},
+			wantErr:    "title only supports eq and ne",
+		},
+		{
+			name:       "range on a flag",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "isListed", Op: "lt", Value: "true"}},
+			wantErr:    "isListed only supports eq and ne",
+		},
+		{
+			name:       "invalid number",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "size", Op: "gte", Value: "large"}},
+			wantErr:    "size must be a number",
+		},
+		{
+			name:       "invalid date",
+			docType:    docTypeTransaction,
+			conditions: []QueryCondition{{Field: "date", Op: "gte", Value: "March"}},
+			wantErr:    "date must be a date",
+		},
+		{
+			name:    "operator given twice",
+			docType: docTypeProperty,
+			conditions: []QueryCondition{
+				{Field: "size", Op: "gte", Value: "1"},
+				{Field: "size", Op: "gte", Value: "2"},
+			},
+			wantErr: "size gte is given twice",
+		},
+		{
+			name:       "price without a currency",
+			docType:    docTypeProperty,
+			conditions: []QueryCondition{{Field: "price", Op: "lte", Value: "500"}},
+			wantErr:    "price can only be queried together with currency eq",
+		},
+		{
+			name:    "price with an excluded currency",
+			docType: docTypeProperty,
+			conditions: []QueryCondition{
+				{Field: "price", Op: "lte", Value: "500"},
+				{Field: "currency", Op: "ne", Value: "USD"},
+			},
+			wantErr: "price can only be queried together with currency eq",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields := propertyQueryFields
+			if test.docType == docTypeTransaction {
+				fields = transactionQueryFields
+			}
+			_, err := buildSelector(test.docType, fields, test.conditions)
+			var chaincodeErr *Error
+			require.ErrorAs(t, err, &chaincodeErr)
+			assert.Equal(t, ErrInvalidArgument, chaincodeErr.Code)
+			assert.Equal(t, test.wantErr, chaincodeErr.Message)
+		})
+	}
+}
+
+func TestRichQueriesThroughTransactionHooks(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	r := new(RealEstate)
+
+	for _, property := range []struct {
+		id       string
+		price    int64
+		currency string
+		listed   bool
+	}{
+		{"p1", 100, "USD", true},
+		{"p2", 300, "USD", true},
+		{"p3", 500, "USD", true},
+		{"p4", 200, "KES", true},
+		{"p5", 200, "USD", false},
+	} {
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, property.id, "Plot", "Nairobi", 10, owner.email, property.price, property.currency, property.listed, "")
+		})
+	}
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.BuyProperty(ctx, "p3", buyer.email, owner.email)
+		return err
+	})
+
+	// Both queries are paginated, which the peer only allows in transactions that write nothing.
+	conditions := []QueryCondition{
+		{Field: "isListed", Op: "eq", Value: "true"},
+		{Field: "currency", Op: "eq", Value: "USD"},
+		{Field: "price", Op: "lte", Value: "400"},
+	}
+	var page *PropertyPage
+	stub.mustInvoke(buyer, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		page, err = r.QueryProperties(ctx, conditions, 1, "")
+		return err
+	})
+	require.Len(t, page.Properties, 1)
+	assert.Equal(t, "p1", page.Properties[0].Id)
+	require.NotEmpty(t, page.Bookmark)
+	stub.mustInvoke(buyer, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		page, err = r.QueryProperties(ctx, conditions, 1, page.Bookmark)
+		return err
+	})
+	require.Len(t, page.Properties, 1)
+	assert.Equal(t, "p2", page.Properties[0].Id)
+	assert.Empty(t, page.Bookmark)
+
+	var transactions *TransactionPage
+	stub.mustInvoke(buyer, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		transactions, err = r.QueryTransactions(ctx, []QueryCondition{{Field: "buyerEmail", Op: "eq", Value: buyer.email}}, 0, "")
+		return err
+	})
+	require.Len(t, transactions.Transactions, 1)
+	assert.Equal(t, "p3", transactions.Transactions[0].PropertyId)
+	assert.Equal(t, TransactionTypeSale, transactions.Transactions[0].Type)
+	assert.Equal(t, int32(1), transactions.Count)
+}
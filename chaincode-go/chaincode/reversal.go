@@ -0,0 +1,284 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Reversal links a sale undone by a registrar to the compensating transaction that undid it.
+type Reversal struct {
+	TransactionId  string `json:"transaction_id"`
+	CompensatingId string `json:"compensating_id"`
+	PropertyId     string `json:"property_id"`
+	RestoredOwner  string `json:"restored_owner"`
+	PreviousOwner  string `json:"previous_owner"`
+	RegistrarEmail string `json:"registrar_email"`
+	Justification  string `json:"justification"`
+	Date           string `json:"date"`
+}
+
+const reversalCompositeKey = "reversal~transactionId~compensatingId~registrarEmail~justification"
+
+// saleOwnerCompositeKey keeps the shares in basis points that a sale took from its owners, so
+// that reversing the sale restores co-owners as well as the seller.
+const saleOwnerCompositeKey = "saleowner~transactionId~ownerEmail~share"
+
+const (
+	TransactionCompleted = "Completed"
+	TransactionReversed  = "Reversed"
+	TransactionReversal  = "Reversal"
+
+	EventTransactionReversed = "TransactionReversed"
+)
+
+// ReverseTransaction undoes a completed sale: the owners before the sale get their shares back,
+// the sale is marked Reversed and a compensating Reversal transaction records which registrar
+// undid it and why. Only the latest sale of a property can be reversed, and only while the buyer
+// still holds all of it, so later owners are never silently dispossessed. Only registrars may
+// reverse sales. The sale's private details, duty included, stay on record, but GetTaxReport only
+// counts completed sales, so the reversed sale no longer owes duty.
+func (r *RealEstate) ReverseTransaction(ctx contractapi.TransactionContextInterface, transactionId string, justification string) (string, error) {
+	if _, err := requireRole(ctx, "reverse transactions", roleRegistrar); err != nil {
+		return "", err
+	}
+	registrarEmail, err := clientName(ctx)
+	if err != nil {
+		return "", err
+	}
+	if justification == "" {
+		return "", invalidArgument("a reversal needs a justification")
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transactionCompositeKey, []string{"transaction", transactionId})
+	if err != nil {
+		return "", errors.New("failed to read transaction from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return "", notFound("transaction %s not found", transactionId)
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return "", errors.New("failed to iterate over transactions")
+	}
+	keyParts, value, err := readAsset(ctx, assetTransaction, queryResponse.Key, queryResponse.Value)
+	if err != nil {
+		return "", err
+	}
+	if keyParts[7] != TransactionCompleted {
+		return "", invalidArgument("only completed sales can be reversed, transaction is %s", keyParts[7])
+	}
+	detail, err := r.getTransactionDetail(ctx, transactionId)
+	if err != nil {
+		return "", err
+	}
+	if detail.Type != TransactionTypeSale {
+		return "", invalidArgument("only sales can be reversed, transaction is a %s", detail.Type)
+	}
+	propertyId, buyerEmail, sellerEmail, saleDate := keyParts[2], keyParts[3], keyParts[4], keyParts[6]
+
+	property, propertyKey, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if property.Retired {
+		return "", invalidArgument("cannot reverse a sale of a retired property")
+	}
+	if property.OwnerEmail != buyerEmail {
+		return "", invalidArgument("property has changed hands since this sale")
+	}
+	holdings, err := r.getShareholdings(ctx, propertyId, buyerEmail)
+	if err != nil {
+		return "", err
+	}
+	if len(holdings) != 1 || holdings[0].basisPoints != fullShare {
+		return "", invalidArgument("the buyer has shared the property since this sale")
+	}
+	saleOwners, err := r.getSaleOwners(ctx, transactionId, sellerEmail)
+	if err != nil {
+		return "", err
+	}
+	transactions, err := r.GetTransactionsByProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	for _, transaction := range transactions {
+		if transaction.Id != transactionId && transaction.Status == TransactionCompleted && transaction.Date > saleDate {
+			return "", invalidArgument("property changed hands again in transaction %s", transaction.Id)
+		}
+	}
+	auction, err := r.openAuctionFor(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if auction != nil {
+		return "", invalidArgument("property is under auction %s", auction.Id)
+	}
+
+	err = ctx.GetStub().DelState(queryResponse.Key)
+	if err != nil {
+		return "", errors.New("failed to delete old transaction state")
+	}
+	keyParts[7] = TransactionReversed
+	err = r.putTransaction(ctx, keyParts, value)
+	if err != nil {
+		return "", err
+	}
+
+	compensatingId := ctx.GetStub().GetTxID()
+	date, err := lineageDate(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = r.putTransaction(ctx, []string{"transaction", compensatingId, propertyId, sellerEmail, buyerEmail, formatMoney(0, property.Currency), date, TransactionReversal}, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := r.putTransactionType(ctx, compensatingId, TransactionTypeReversal); err != nil {
+		return "", err
+	}
+	reversalKey, err := ctx.GetStub().CreateCompositeKey(reversalCompositeKey, []string{"reversal", transactionId, compensatingId, registrarEmail, justification})
+	if err != nil {
+		log.Println("failed to create composite key for reversal")
+		return "", errors.New("failed to create composite key for reversal")
+	}
+	err = ctx.GetStub().PutState(reversalKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put reversal in world state")
+		return "", errors.New("failed to put reversal in world state")
+	}
+	err = r.updateTransactionDetail(ctx, transactionId, func(detail *transactionDetail) {
+		detail.ReversedBy, detail.Justification = compensatingId, justification
+	})
+	if err != nil {
+		return "", err
+	}
+	err = r.updateTransactionDetail(ctx, compensatingId, func(detail *transactionDetail) {
+		detail.Type, detail.Reverses, detail.ApprovedBy, detail.Justification = TransactionTypeReversal, transactionId, registrarEmail, justification
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = ctx.GetStub().DelState(propertyKey)
+	if err != nil {
+		return "", errors.New("failed to delete old property state")
+	}
+	err = r.putProperty(ctx, propertyId, property.Title, property.Location, formatAmount(property.Size), sellerEmail, formatMoney(property.Price, property.Currency), "false")
+	if err != nil {
+		return "", err
+	}
+	if err := countListing(ctx, property.IsListed, false); err != nil {
+		return "", err
+	}
+	if err := addCount(ctx, counterReversals, 1); err != nil {
+		return "", err
+	}
+	if err := r.setOwners(ctx, propertyId, buyerEmail, saleOwners); err != nil {
+		return "", err
+	}
+	if err := r.clearListing(ctx, propertyId); err != nil {
+		return "", err
+	}
+	if err := r.transferLeases(ctx, propertyId, "", sellerEmail); err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(Reversal{
+		TransactionId:  transactionId,
+		CompensatingId: compensatingId,
+		PropertyId:     propertyId,
+		RestoredOwner:  sellerEmail,
+		PreviousOwner:  buyerEmail,
+		RegistrarEmail: registrarEmail,
+		Justification:  justification,
+		Date:           date,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.GetStub().SetEvent(EventTransactionReversed, payload); err != nil {
+		return "", errors.New("failed to emit reversal event")
+	}
+	return compensatingId, nil
+}
+
+// getReversals maps both the reversed and the compensating transaction ids to their reversal link.
+// Only RebuildIndexes scans them, to backfill transaction details.
+func (r *RealEstate) getReversals(ctx contractapi.TransactionContextInterface) (map[string]Reversal, error) {
+	reversals := make(map[string]Reversal)
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(reversalCompositeKey, []string{"reversal"})
+	if err != nil {
+		return nil, errors.New("failed to get reversals")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over reversals")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		reversal := Reversal{
+			TransactionId:  keyParts[1],
+			CompensatingId: keyParts[2],
+			RegistrarEmail: keyParts[3],
+			Justification:  keyParts[4],
+		}
+		reversals[reversal.TransactionId] = reversal
+		reversals[reversal.CompensatingId] = reversal
+	}
+	return reversals, nil
+}
+
+// recordSaleOwners keeps the shareholdings of the property that the sale transactionId replaces.
+func (r *RealEstate) recordSaleOwners(ctx contractapi.TransactionContextInterface, transactionId string, propertyId string, sellerEmail string) error {
+	holdings, err := r.getShareholdings(ctx, propertyId, sellerEmail)
+	if err != nil {
+		return err
+	}
+	for _, holding := range holdings {
+		if err := putKey(ctx, saleOwnerCompositeKey, []byte{0x00}, "saleowner", transactionId, holding.email, strconv.Itoa(holding.basisPoints)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSaleOwners returns the shareholdings the sale transactionId replaced. Sales recorded before
+// they were kept only had the seller as a known owner.
+func (r *RealEstate) getSaleOwners(ctx contractapi.TransactionContextInterface, transactionId string, sellerEmail string) ([]shareholding, error) {
+	var holdings []shareholding
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(saleOwnerCompositeKey, []string{"saleowner", transactionId})
+	if err != nil {
+		return nil, errors.New("failed to get sale owners")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over sale owners")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		basisPoints, err := strconv.Atoi(keyParts[3])
+		if err != nil {
+			return nil, err
+		}
+		holdings = append(holdings, shareholding{email: keyParts[2], basisPoints: basisPoints})
+	}
+	if len(holdings) == 0 {
+		holdings = append(holdings, shareholding{email: sellerEmail, basisPoints: fullShare})
+	}
+	return holdings, nil
+}
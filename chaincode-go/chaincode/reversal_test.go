@@ -0,0 +1,76 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseTransaction(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	partner := testClient{email: "partner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	registrar := testClient{email: "registrar@example.com", role: roleRegistrar}
+	auditor := testClient{email: "auditor@example.com", role: roleAuditor}
+	r := new(RealEstate)
+
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Nairobi", 10, owner.email, 250000, "USD", true, "")
+	})
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.TransferShare(ctx, "p1", owner.email, partner.email, "40")
+	})
+	// Both co-owners consent to the sale; the second consent completes it.
+	var saleId string
+	for _, seller := range []testClient{owner, partner} {
+		stub.mustInvoke(seller, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			saleId, err = r.BuyProperty(ctx, "p1", buyer.email, seller.email)
+			return err
+		})
+	}
+	require.NotEmpty(t, saleId)
+
+	reverse := func(client testClient, justification string) (string, error) {
+		var compensatingId string
+		err := stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			compensatingId, err = r.ReverseTransaction(ctx, saleId, justification)
+			return err
+		})
+		return compensatingId, err
+	}
+	_, err := reverse(owner, "forged deed")
+	assert.ErrorContains(t, err, ErrPermissionDenied)
+	_, err = reverse(registrar, "")
+	assert.ErrorContains(t, err, ErrInvalidArgument)
+	compensatingId, err := reverse(registrar, "forged deed")
+	require.NoError(t, err)
+	assert.NotEqual(t, saleId, compensatingId)
+	_, err = reverse(registrar, "forged deed")
+	assert.Error(t, err, "a sale is reversed once")
+
+	var owners []Owner
+	var property *PropertyDetail
+	var report []TaxReportEntry
+	today := time.Now().UTC()
+	stub.mustInvoke(auditor, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		if owners, err = r.GetOwners(ctx, "p1"); err != nil {
+			return err
+		}
+		if property, err = r.GetProperty(ctx, "p1"); err != nil {
+			return err
+		}
+		report, err = r.GetTaxReport(ctx, today.Format(leaseDateLayout), today.Format(leaseDateLayout))
+		return err
+	})
+	assert.ElementsMatch(t, []Owner{{Email: owner.email, Share: 60}, {Email: partner.email, Share: 40}}, owners)
+	assert.Contains(t, []string{owner.email, partner.email}, property.Property.OwnerEmail)
+	assert.False(t, property.Property.IsListed)
+	assert.Empty(t, report, "the reversed sale owes no duty")
+}
@@ -0,0 +1,199 @@
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Every user, property and transaction key carries the schema version of its attributes at the
+// front of its value, as "v<version>:" followed by the value proper: a private data hash, or
+// nothing. Keys written before versioning hold the bare value and are version 0. Records are
+// upgraded lazily: readers pass them through readAsset, which applies the registered upgrades in
+// memory, and writers always store the current version, so a record takes the new layout the
+// next time it changes. RunMigration rewrites the records that are still behind.
+const (
+	assetUser        = "user"
+	assetProperty    = "property"
+	assetTransaction = "transaction"
+
+	defaultMigrationBatch = 100
+	maxMigrationBatch     = 500
+)
+
+// upgrade turns the key attributes and value of a record into those of the next schema version.
+type upgrade func(keyParts []string, value []byte) ([]string, []byte, error)
+
+// upgrades holds, per asset, the upgrade from version i to version i+1 at index i. The current
+// version of an asset is the number of its upgrades, so a layout change only needs a new entry.
+var upgrades = map[string][]upgrade{
+	// 1: the record is versioned; the layout is unchanged.
+	assetUser: {sameLayout},
+	// 1: the price is in minor units with its currency.
+	assetProperty: {moneyAttribute(6)},
+	// 1: the public amount is in minor units with its currency.
+	assetTransaction: {moneyAttribute(5)},
+}
+
+var assetObjectTypes = map[string]string{
+	assetUser:        userCompositeKey,
+	assetProperty:    propertCompositeKey,
+	assetTransaction: transactionCompositeKey,
+}
+
+// MigrationProgress reports one batch of a migration. Bookmark marks the last record the batch
+// looked at; pass it to the next batch, until Done.
+type MigrationProgress struct {
+	Asset    string `json:"asset"`
+	Version  int    `json:"version"`
+	Scanned  int    `json:"scanned"`
+	Migrated int    `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+	Done     bool   `json:"done"`
+}
+
+// RunMigration looks at up to batchSize records of asset ("user", "property" or "transaction")
+// after bookmark, upgrades those stored at an older schema version and returns the bookmark of
+// the next batch. Each batch is its own transaction, so callers repeat it until Done. Only admins
+// may run it.
+//
+// The paginated range APIs are only allowed in read-only transactions, and GetStateByRange
+// rejects composite keys, so a batch cannot seek to its bookmark: it iterates the asset's keys
+// from the start and skips up to the bookmark without decoding them. Every batch therefore still
+// reads the keys before it, but it stops after batchSize records instead of counting the whole
+// asset. Upgrading a record can change its key, so the bookmark is the key prefix of the record's
+// id rather than the key itself: the rewritten key shares that prefix and is never looked at twice.
+func (r *RealEstate) RunMigration(ctx contractapi.TransactionContextInterface, asset string, batchSize int32, bookmark string) (*MigrationProgress, error) {
+	if _, err := requireRole(ctx, "run migrations", roleAdmin); err != nil {
+		return nil, err
+	}
+	objectType, ok := assetObjectTypes[asset]
+	if !ok {
+		return nil, invalidArgument("unknown asset %q", asset)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultMigrationBatch
+	}
+	if batchSize > maxMigrationBatch {
+		batchSize = maxMigrationBatch
+	}
+
+	progress := &MigrationProgress{Asset: asset, Version: schemaVersion(asset)}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{asset})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s records", asset)
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate over %s records", asset)
+		}
+		if bookmark != "" && (queryResponse.Key <= bookmark || strings.HasPrefix(queryResponse.Key, bookmark)) {
+			continue
+		}
+		if progress.Scanned == int(batchSize) {
+			return progress, nil
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error splitting key: %s", err.Error())
+		}
+		if bookmark, err = createKey(ctx, objectType, asset, keyParts[1]); err != nil {
+			return nil, err
+		}
+		progress.Scanned++
+		progress.Bookmark = bookmark
+		if version, _ := splitVersion(queryResponse.Value); version == progress.Version {
+			continue
+		}
+		keyParts, value, err := readAsset(ctx, asset, queryResponse.Key, queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return nil, fmt.Errorf("failed to delete old %s state", asset)
+		}
+		if asset == assetProperty {
+			err = r.putProperty(ctx, keyParts[1], keyParts[2], keyParts[3], keyParts[4], keyParts[5], keyParts[6], keyParts[7])
+		} else {
+			err = putKey(ctx, objectType, versionedValue(asset, value), keyParts...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		progress.Migrated++
+	}
+	progress.Bookmark = ""
+	progress.Done = true
+	return progress, nil
+}
+
+func schemaVersion(asset string) int {
+	return len(upgrades[asset])
+}
+
+// versionedValue prefixes value with the current schema version of asset.
+func versionedValue(asset string, value []byte) []byte {
+	return append([]byte("v"+strconv.Itoa(schemaVersion(asset))+":"), value...)
+}
+
+// splitVersion separates the schema version from a stored value.
+func splitVersion(stored []byte) (int, []byte) {
+	end := bytes.IndexByte(stored, ':')
+	if len(stored) == 0 || stored[0] != 'v' || end < 2 {
+		return 0, stored
+	}
+	version, err := strconv.Atoi(string(stored[1:end]))
+	if err != nil {
+		return 0, stored
+	}
+	return version, stored[end+1:]
+}
+
+// readAsset splits the key of an asset record and upgrades its attributes and value, stored as
+// stored, to the current schema version.
+func readAsset(ctx contractapi.TransactionContextInterface, asset string, key string, stored []byte) ([]string, []byte, error) {
+	_, keyParts, err := ctx.GetStub().SplitCompositeKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error splitting key: %s", err.Error())
+	}
+	version, value := splitVersion(stored)
+	steps := upgrades[asset]
+	if version > len(steps) {
+		return nil, nil, fmt.Errorf("%s %s has schema version %d, newer than this chaincode's %d", asset, keyParts[1], version, len(steps))
+	}
+	for _, step := range steps[version:] {
+		upgraded, upgradedValue, err := step(keyParts, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to upgrade %s %s: %v", asset, keyParts[1], err)
+		}
+		keyParts, value = upgraded, upgradedValue
+	}
+	return keyParts, value, nil
+}
+
+func sameLayout(keyParts []string, value []byte) ([]string, []byte, error) {
+	return keyParts, value, nil
+}
+
+// moneyAttribute rewrites a legacy decimal amount at keyParts[part] into minor units of
+// defaultCurrency. Empty amounts, which are kept in a private collection, stay empty.
+func moneyAttribute(part int) upgrade {
+	return func(keyParts []string, value []byte) ([]string, []byte, error) {
+		if !isLegacyMoney(keyParts[part]) {
+			return keyParts, value, nil
+		}
+		amount, currency, err := parseMoney(keyParts[part])
+		if err != nil {
+			return nil, nil, err
+		}
+		upgraded := append([]string(nil), keyParts...)
+		upgraded[part] = formatMoney(amount, currency)
+		return upgraded, value, nil
+	}
+}
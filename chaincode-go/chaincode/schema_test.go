@@ -0,0 +1,185 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		stored      string
+		wantVersion int
+		wantValue   string
+	}{
+		{name: "empty", stored: "", wantVersion: 0, wantValue: ""},
+		{name: "unversioned value", stored: "5f2b", wantVersion: 0, wantValue: "5f2b"},
+		{name: "versioned without value", stored: "v1:", wantVersion: 1, wantValue: ""},
+		{name: "versioned value", stored: "v2:5f2b", wantVersion: 2, wantValue: "5f2b"},
+		{name: "value containing a colon", stored: "v1:a:b", wantVersion: 1, wantValue: "a:b"},
+		{name: "missing version number", stored: "v:5f2b", wantVersion: 0, wantValue: "v:5f2b"},
+		{name: "non-numeric version", stored: "vx:5f2b", wantVersion: 0, wantValue: "vx:5f2b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, value := splitVersion([]byte(test.stored))
+			assert.Equal(t, test.wantVersion, version)
+			assert.Equal(t, test.wantValue, string(value))
+		})
+	}
+}
+
+func TestVersionedValue(t *testing.T) {
+	stored := versionedValue(assetProperty, []byte("5f2b"))
+	version, value := splitVersion(stored)
+	assert.Equal(t, schemaVersion(assetProperty), version)
+	assert.Equal(t, "5f2b", string(value))
+}
+
+func TestMoneyAttribute(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  string
+		want    string
+		wantErr bool
+	}{
+		{name: "legacy decimal", amount: "125000.00", want: "12500000 USD"},
+		{name: "current amount", amount: "900 EUR", want: "900 EUR"},
+		{name: "private amount", amount: "", want: ""},
+		{name: "invalid legacy decimal", amount: "abc", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyParts := []string{"transaction", "t1", test.amount}
+			upgraded, _, err := moneyAttribute(2)(keyParts, nil)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, upgraded[2])
+			assert.Equal(t, test.amount, keyParts[2], "the caller's key parts must not change")
+		})
+	}
+}
+
+func TestReadAsset(t *testing.T) {
+	stub := shimtest.NewMockStub("realestate", nil)
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	propertyKey := func(price string) string {
+		key, err := stub.CreateCompositeKey(propertCompositeKey, []string{"property", "p1", "Plot", "nairobi", "10.00", "a@example.com", price, "true"})
+		require.NoError(t, err)
+		return key
+	}
+
+	tests := []struct {
+		name      string
+		price     string
+		stored    string
+		wantPrice string
+		wantValue string
+		wantErr   bool
+	}{
+		{name: "unversioned legacy record", price: "125000.00", stored: "", wantPrice: "12500000 USD"},
+		{name: "current record", price: "700 KES", stored: "v1:", wantPrice: "700 KES"},
+		{name: "value is kept", price: "700 KES", stored: "v1:5f2b", wantPrice: "700 KES", wantValue: "5f2b"},
+		{name: "newer schema version", price: "700 KES", stored: "v9:", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyParts, value, err := readAsset(ctx, assetProperty, propertyKey(test.price), []byte(test.stored))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantPrice, keyParts[6])
+			assert.Equal(t, "p1", keyParts[1])
+			assert.Equal(t, test.wantValue, string(value))
+		})
+	}
+}
+
+func TestRunMigration(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	admin := testClient{email: "admin@example.com", role: roleAdmin}
+	r := new(RealEstate)
+
+	// Properties written before versioning and currencies: a bare value and a decimal price.
+	stub.MockTransactionStart("legacy")
+	for _, propertyId := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		key, err := stub.CreateCompositeKey(propertCompositeKey, []string{"property", propertyId, "Plot", "nairobi", "10.00", owner.email, "1250.50", "false"})
+		require.NoError(t, err)
+		require.NoError(t, stub.MockStub.PutState(key, []byte{0x00}))
+	}
+	stub.MockTransactionEnd("legacy")
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p6", "Plot", "nairobi", 10, owner.email, 99000, "KES", false, "")
+	})
+
+	// Readers upgrade legacy records in memory before any migration has run.
+	var detail *PropertyDetail
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		detail, err = r.GetProperty(ctx, "p3")
+		return err
+	})
+	assert.Equal(t, int64(125050), detail.Property.Price)
+	assert.Equal(t, "USD", detail.Property.Currency)
+
+	migrate := func(client testClient, bookmark string) (*MigrationProgress, error) {
+		var progress *MigrationProgress
+		err := stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			progress, err = r.RunMigration(ctx, assetProperty, 2, bookmark)
+			return err
+		})
+		return progress, err
+	}
+	_, err := migrate(owner, "")
+	assert.ErrorContains(t, err, ErrPermissionDenied)
+
+	var batches []MigrationProgress
+	for bookmark := ""; ; {
+		progress, err := migrate(admin, bookmark)
+		require.NoError(t, err)
+		batches = append(batches, *progress)
+		if progress.Done {
+			break
+		}
+		require.NotEmpty(t, progress.Bookmark)
+		bookmark = progress.Bookmark
+	}
+	scanned, migrated := 0, 0
+	for _, batch := range batches {
+		assert.LessOrEqual(t, batch.Scanned, 2)
+		assert.Equal(t, 1, batch.Version)
+		scanned += batch.Scanned
+		migrated += batch.Migrated
+	}
+	assert.Len(t, batches, 3)
+	assert.Equal(t, 6, scanned)
+	assert.Equal(t, 5, migrated, "p6 is already current")
+
+	for key, value := range stub.State {
+		if _, keyParts, err := stub.SplitCompositeKey(key); err == nil && strings.HasPrefix(key, "\x00"+propertCompositeKey) {
+			version, _ := splitVersion(value)
+			assert.Equal(t, 1, version, keyParts[1])
+			assert.NotContains(t, keyParts[6], ".", keyParts[1])
+		}
+	}
+
+	// A finished migration has nothing left to upgrade.
+	progress, err := migrate(admin, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Scanned)
+	assert.Zero(t, progress.Migrated)
+}
@@ -0,0 +1,306 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+)
+
+// testMSPID is the organization of every test client and of the peer.
+const testMSPID = "Org1MSP"
+
+// testStub is a MockStub that behaves like a peer where the mock does not: it answers paginated
+// queries, rich queries included, and it enforces the peer's rule that a transaction either runs
+// paginated queries or writes, never both.
+type testStub struct {
+	*shimtest.MockStub
+	t         *testing.T
+	txCount   int
+	paginated bool
+	wrote     bool
+}
+
+// testClient is the identity a transaction is submitted with.
+type testClient struct {
+	email string
+	role  string
+}
+
+func newTestStub(t *testing.T) *testStub {
+	t.Setenv("CORE_PEER_LOCALMSPID", testMSPID)
+	return &testStub{MockStub: shimtest.NewMockStub("realestate", nil), t: t}
+}
+
+// invoke runs fn as one transaction of client, between the contracts' before and after
+// transaction hooks. The transaction time is at, or the current time when at is zero.
+func (s *testStub) invoke(client testClient, at time.Time, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	s.txCount++
+	txId := fmt.Sprintf("tx%03d", s.txCount)
+	s.MockTransactionStart(txId)
+	defer s.MockTransactionEnd(txId)
+	if !at.IsZero() {
+		timestamp, err := ptypes.TimestampProto(at)
+		require.NoError(s.t, err)
+		s.TxTimestamp = timestamp
+	}
+	s.paginated, s.wrote = false, false
+	s.Creator = creator(s.t, client)
+
+	ctx := new(RegistryContext)
+	ctx.SetStub(s)
+	identity, err := cid.New(s)
+	require.NoError(s.t, err)
+	ctx.SetClientIdentity(identity)
+	if err := beforeTransaction(ctx); err != nil {
+		return err
+	}
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	return afterTransaction(ctx, nil)
+}
+
+// mustInvoke is invoke for steps that set up a test and must succeed.
+func (s *testStub) mustInvoke(client testClient, fn func(ctx contractapi.TransactionContextInterface) error) {
+	s.t.Helper()
+	require.NoError(s.t, s.invoke(client, time.Time{}, fn))
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if s.paginated {
+		return errors.New("transaction with paginated queries cannot perform writes")
+	}
+	s.wrote = true
+	return s.MockStub.PutState(key, value)
+}
+
+func (s *testStub) DelState(key string) error {
+	if s.paginated {
+		return errors.New("transaction with paginated queries cannot perform writes")
+	}
+	s.wrote = true
+	return s.MockStub.DelState(key)
+}
+
+func (s *testStub) PutPrivateData(collection string, key string, value []byte) error {
+	if s.paginated {
+		return errors.New("transaction with paginated queries cannot perform writes")
+	}
+	s.wrote = true
+	return s.MockStub.PutPrivateData(collection, key, value)
+}
+
+func (s *testStub) startPaginatedQuery() error {
+	if s.wrote {
+		return errors.New("queries with pagination are supported only in read-only transactions")
+	}
+	s.paginated = true
+	return nil
+}
+
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, attributes []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if err := s.startPaginatedQuery(); err != nil {
+		return nil, nil, err
+	}
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, nil, err
+	}
+	start := prefix
+	if bookmark > start {
+		start = bookmark
+	}
+	return s.page(s.keysBetween(start, prefix+string(utf8.MaxRune)), pageSize)
+}
+
+// GetQueryResultWithPagination understands the selectors buildSelector writes: field
+// comparisons and a $or of them.
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if err := s.startPaginatedQuery(); err != nil {
+		return nil, nil, err
+	}
+	var request struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	if err := json.Unmarshal([]byte(query), &request); err != nil {
+		return nil, nil, err
+	}
+	var keys []string
+	for _, key := range s.keysBetween(bookmark, string(utf8.MaxRune)) {
+		var document map[string]interface{}
+		if json.Unmarshal(s.State[key], &document) == nil && matches(document, request.Selector) {
+			keys = append(keys, key)
+		}
+	}
+	return s.page(keys, pageSize)
+}
+
+func (s *testStub) keysBetween(start string, end string) []string {
+	var keys []string
+	for key := range s.State {
+		if key >= start && key < end {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// page returns the first pageSize keys; the bookmark is the key the next page starts at.
+func (s *testStub) page(keys []string, pageSize int32) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	metadata := &pb.QueryResponseMetadata{}
+	if len(keys) > int(pageSize) {
+		metadata.Bookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+	iterator := &sliceIterator{}
+	for _, key := range keys {
+		iterator.results = append(iterator.results, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	metadata.FetchedRecordsCount = int32(len(keys))
+	return iterator, metadata, nil
+}
+
+func matches(document map[string]interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		if field == "$or" {
+			matched := false
+			for _, alternative := range condition.([]interface{}) {
+				matched = matched || matches(document, alternative.(map[string]interface{}))
+			}
+			if !matched {
+				return false
+			}
+			continue
+		}
+		value, exists := document[field]
+		clause, ok := condition.(map[string]interface{})
+		if !ok {
+			clause = map[string]interface{}{"$eq": condition}
+		}
+		for operator, operand := range clause {
+			if !compare(value, exists, operator, operand) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func compare(value interface{}, exists bool, operator string, operand interface{}) bool {
+	if operator == "$exists" {
+		return exists == operand.(bool)
+	}
+	if !exists {
+		return false
+	}
+	order := 0
+	switch v := value.(type) {
+	case float64:
+		switch number := operand.(float64); {
+		case v < number:
+			order = -1
+		case v > number:
+			order = 1
+		}
+	case string:
+		order = strings.Compare(v, operand.(string))
+	case bool:
+		if v != operand.(bool) {
+			order = 1
+		}
+	}
+	switch operator {
+	case "$eq":
+		return order == 0
+	case "$ne":
+		return order != 0
+	case "$lt":
+		return order < 0
+	case "$lte":
+		return order <= 0
+	case "$gt":
+		return order > 0
+	case "$gte":
+		return order >= 0
+	}
+	return false
+}
+
+type sliceIterator struct {
+	results []*queryresult.KV
+}
+
+func (i *sliceIterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *sliceIterator) Next() (*queryresult.KV, error) {
+	if len(i.results) == 0 {
+		return nil, errors.New("no more results")
+	}
+	result := i.results[0]
+	i.results = i.results[1:]
+	return result, nil
+}
+
+func (i *sliceIterator) Close() error {
+	return nil
+}
+
+var testKey *ecdsa.PrivateKey
+
+// creator serializes an identity of testMSPID whose certificate carries the client's email as
+// its common name and its role and email as attributes.
+func creator(t *testing.T, client testClient) []byte {
+	if testKey == nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		testKey = key
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: client.email},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	attributes := &attrmgr.Attributes{Attrs: map[string]string{emailAttribute: client.email}}
+	if client.role != "" {
+		attributes.Attrs[roleAttribute] = client.role
+	}
+	require.NoError(t, attrmgr.New().AddAttributesToCert(attributes, template))
+	// CreateCertificate only writes ExtraExtensions.
+	template.ExtraExtensions = template.Extensions
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &testKey.PublicKey, testKey)
+	require.NoError(t, err)
+	identity, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   testMSPID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	require.NoError(t, err)
+	return identity
+}
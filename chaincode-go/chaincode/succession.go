@@ -0,0 +1,259 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const successionCompositeKey = "succession~transactionId~deceasedEmail~probateHash~registrarEmail"
+const heirCompositeKey = "heir~transactionId~heirEmail~share"
+
+type succession struct {
+	probateHash    string
+	registrarEmail string
+	heirs          []Owner
+}
+
+// TransferBySuccession passes a deceased owner's stake to their heirs under a probate order.
+// Heir shares are percentages of the deceased's stake and must add up to 100; any other
+// co-owners keep their shares. The transfer is recorded as a Succession transaction with no
+// price, linked to the probate order's document hash and the registrar who approved it, who must
+// be the client.
+func (r *RealEstate) TransferBySuccession(ctx contractapi.TransactionContextInterface, propertyId string, deceasedEmail string, heirs []Owner, probateHash string) (string, error) {
+	if _, err := requireRole(ctx, "approve successions", roleRegistrar); err != nil {
+		return "", err
+	}
+	registrarEmail, err := clientName(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(heirs) == 0 {
+		return "", invalidArgument("a succession needs at least one heir")
+	}
+	if probateHash == "" {
+		return "", invalidArgument("a succession needs the probate order's document hash")
+	}
+	property, propertyKey, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if property.Retired {
+		return "", invalidArgument("cannot transfer a retired property")
+	}
+	if property.Frozen {
+		return "", invalidArgument("cannot transfer a frozen property")
+	}
+	auction, err := r.openAuctionFor(ctx, propertyId)
+	if err != nil {
+		return "", err
+	}
+	if auction != nil {
+		return "", invalidArgument("property is under auction %s", auction.Id)
+	}
+	holdings, err := r.getShareholdings(ctx, propertyId, property.OwnerEmail)
+	if err != nil {
+		return "", err
+	}
+	existing := make(map[string]shareholding)
+	for _, holding := range holdings {
+		existing[holding.email] = holding
+	}
+	deceased, ok := existing[deceasedEmail]
+	if !ok {
+		return "", invalidArgument("deceased is not an owner of the property")
+	}
+
+	inherited, primaryHeir, err := splitStake(deceased.basisPoints, deceasedEmail, heirs)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.deleteShareholding(ctx, propertyId, deceased); err != nil {
+		return "", err
+	}
+	for i, heir := range heirs {
+		received := inherited[i]
+		if holding, ok := existing[heir.Email]; ok {
+			if err := r.deleteShareholding(ctx, propertyId, holding); err != nil {
+				return "", err
+			}
+			received += holding.basisPoints
+		}
+		if err := r.putOwnership(ctx, propertyId, heir.Email, received); err != nil {
+			return "", err
+		}
+	}
+	// Pending consents were given under the old share split, so they no longer count.
+	if err := r.clearConsents(ctx, propertyId, ""); err != nil {
+		return "", err
+	}
+	ownerEmail := property.OwnerEmail
+	if ownerEmail == deceasedEmail {
+		ownerEmail = heirs[primaryHeir].Email
+	}
+	err = ctx.GetStub().DelState(propertyKey)
+	if err != nil {
+		return "", errors.New("failed to delete old property state")
+	}
+	err = r.putProperty(ctx, propertyId, property.Title, property.Location, formatAmount(property.Size), ownerEmail, formatMoney(property.Price, property.Currency), "false")
+	if err != nil {
+		return "", err
+	}
+	if err := countListing(ctx, property.IsListed, false); err != nil {
+		return "", err
+	}
+	if err := addCount(ctx, counterSuccessions, 1); err != nil {
+		return "", err
+	}
+	if err := r.clearListing(ctx, propertyId); err != nil {
+		return "", err
+	}
+	if err := r.transferLeases(ctx, propertyId, deceasedEmail, heirs[primaryHeir].Email); err != nil {
+		return "", err
+	}
+
+	transactionId := ctx.GetStub().GetTxID()
+	date, err := lineageDate(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = r.putTransaction(ctx, []string{"transaction", transactionId, propertyId, heirs[primaryHeir].Email, deceasedEmail, formatMoney(0, property.Currency), date, TransactionCompleted}, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := r.putTransactionType(ctx, transactionId, TransactionTypeSuccession); err != nil {
+		return "", err
+	}
+	successionKey, err := ctx.GetStub().CreateCompositeKey(successionCompositeKey, []string{"succession", transactionId, deceasedEmail, probateHash, registrarEmail})
+	if err != nil {
+		log.Println("failed to create composite key for succession")
+		return "", errors.New("failed to create composite key for succession")
+	}
+	err = ctx.GetStub().PutState(successionKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put succession in world state")
+		return "", errors.New("failed to put succession in world state")
+	}
+	var inheritedShares []Owner
+	for i, heir := range heirs {
+		inheritedShares = append(inheritedShares, Owner{Email: heir.Email, Share: float64(inherited[i]) / 100})
+		heirKey, err := ctx.GetStub().CreateCompositeKey(heirCompositeKey, []string{"heir", transactionId, heir.Email, strconv.Itoa(inherited[i])})
+		if err != nil {
+			log.Println("failed to create composite key for heir")
+			return "", errors.New("failed to create composite key for heir")
+		}
+		err = ctx.GetStub().PutState(heirKey, []byte{0x00})
+		if err != nil {
+			log.Println("failed to put heir in world state")
+			return "", errors.New("failed to put heir in world state")
+		}
+		// Every heir, not just the primary one, finds the succession among their transactions.
+		if err := r.putIndex(ctx, buyerIndexCompositeKey, "buyer", heir.Email, transactionId); err != nil {
+			return "", err
+		}
+	}
+	err = r.updateTransactionDetail(ctx, transactionId, func(detail *transactionDetail) {
+		detail.Type, detail.ApprovedBy, detail.ProbateHash, detail.Heirs = TransactionTypeSuccession, registrarEmail, probateHash, inheritedShares
+	})
+	if err != nil {
+		return "", err
+	}
+	return transactionId, nil
+}
+
+// getSuccessions returns the probate details of every succession transaction by transaction id.
+// Heir shares are reported as percentages of the whole property. Only RebuildIndexes scans them,
+// to backfill transaction details.
+func (r *RealEstate) getSuccessions(ctx contractapi.TransactionContextInterface) (map[string]*succession, error) {
+	successions := make(map[string]*succession)
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(successionCompositeKey, []string{"succession"})
+	if err != nil {
+		return nil, errors.New("failed to get successions")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over successions")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		successions[keyParts[1]] = &succession{probateHash: keyParts[3], registrarEmail: keyParts[4]}
+	}
+
+	heirIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(heirCompositeKey, []string{"heir"})
+	if err != nil {
+		return nil, errors.New("failed to get heirs")
+	}
+	defer heirIterator.Close()
+	for heirIterator.HasNext() {
+		queryResponse, err := heirIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over heirs")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		basisPoints, err := strconv.Atoi(keyParts[3])
+		if err != nil {
+			return nil, err
+		}
+		if record, ok := successions[keyParts[1]]; ok {
+			record.heirs = append(record.heirs, Owner{Email: keyParts[2], Share: float64(basisPoints) / 100})
+		}
+	}
+	return successions, nil
+}
+
+// splitStake divides a stake of basis points among heirs by their percentage shares, which must
+// add up to 100. It returns the basis points each heir inherits and the index of the heir with
+// the largest part.
+func splitStake(stake int, deceasedEmail string, heirs []Owner) ([]int, int, error) {
+	heirPoints := make([]int, len(heirs))
+	seen := make(map[string]bool)
+	total := 0
+	for i, heir := range heirs {
+		if heir.Email == "" || heir.Email == deceasedEmail {
+			return nil, 0, invalidArgument("invalid heir %q", heir.Email)
+		}
+		if seen[heir.Email] {
+			return nil, 0, invalidArgument("heir %s is listed twice", heir.Email)
+		}
+		seen[heir.Email] = true
+		heirPoints[i] = int(math.Round(heir.Share * 100))
+		if heirPoints[i] <= 0 {
+			return nil, 0, invalidArgument("heir %s needs a positive share", heir.Email)
+		}
+		total += heirPoints[i]
+	}
+	if total != fullShare {
+		return nil, 0, invalidArgument("heir shares add up to %.2f%% instead of 100%%", float64(total)/100)
+	}
+	// Split the deceased's stake and give the rounding remainder to the first heir so the
+	// property still adds up to exactly 100%.
+	inherited := make([]int, len(heirs))
+	distributed := 0
+	for i := range heirs {
+		inherited[i] = stake * heirPoints[i] / fullShare
+		distributed += inherited[i]
+	}
+	inherited[0] += stake - distributed
+	primaryHeir := 0
+	for i := range heirs {
+		if inherited[i] == 0 {
+			return nil, 0, invalidArgument("share of heir %s is too small to inherit", heirs[i].Email)
+		}
+		if inherited[i] > inherited[primaryHeir] {
+			primaryHeir = i
+		}
+	}
+	return inherited, primaryHeir, nil
+}
@@ -0,0 +1,168 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStake(t *testing.T) {
+	tests := []struct {
+		name          string
+		stake         int
+		heirs         []Owner
+		wantInherited []int
+		wantPrimary   int
+		wantErr       string
+	}{
+		{
+			name:          "sole heir",
+			stake:         fullShare,
+			heirs:         []Owner{{Email: "a@example.com", Share: 100}},
+			wantInherited: []int{10000},
+		},
+		{
+			name:          "even split of a partial stake",
+			stake:         5000,
+			heirs:         []Owner{{Email: "a@example.com", Share: 50}, {Email: "b@example.com", Share: 50}},
+			wantInherited: []int{2500, 2500},
+		},
+		{
+			name:  "remainder goes to the first heir",
+			stake: 3333,
+			heirs: []Owner{
+				{Email: "a@example.com", Share: 33.33},
+				{Email: "b@example.com", Share: 33.33},
+				{Email: "c@example.com", Share: 33.34},
+			},
+			wantInherited: []int{1112, 1110, 1111},
+		},
+		{
+			name:          "primary heir has the largest part",
+			stake:         fullShare,
+			heirs:         []Owner{{Email: "a@example.com", Share: 25}, {Email: "b@example.com", Share: 75}},
+			wantInherited: []int{2500, 7500},
+			wantPrimary:   1,
+		},
+		{
+			name:    "shares do not add up",
+			stake:   fullShare,
+			heirs:   []Owner{{Email: "a@example.com", Share: 50}, {Email: "b@example.com", Share: 40}},
+			wantErr: "heir shares add up to 90.00% instead of 100%",
+		},
+		{
+			name:    "heir listed twice",
+			stake:   fullShare,
+			heirs:   []Owner{{Email: "a@example.com", Share: 50}, {Email: "a@example.com", Share: 50}},
+			wantErr: "heir a@example.com is listed twice",
+		},
+		{
+			name:    "deceased as heir",
+			stake:   fullShare,
+			heirs:   []Owner{{Email: "dead@example.com", Share: 100}},
+			wantErr: `invalid heir "dead@example.com"`,
+		},
+		{
+			name:    "non-positive share",
+			stake:   fullShare,
+			heirs:   []Owner{{Email: "a@example.com", Share: 100}, {Email: "b@example.com", Share: 0}},
+			wantErr: "heir b@example.com needs a positive share",
+		},
+		{
+			name:    "share too small to inherit",
+			stake:   1,
+			heirs:   []Owner{{Email: "a@example.com", Share: 50}, {Email: "b@example.com", Share: 50}},
+			wantErr: "share of heir b@example.com is too small to inherit",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inherited, primary, err := splitStake(test.stake, "dead@example.com", test.heirs)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, ErrInvalidArgument+": "+test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantInherited, inherited)
+			assert.Equal(t, test.wantPrimary, primary)
+			total := 0
+			for _, points := range inherited {
+				total += points
+			}
+			assert.Equal(t, test.stake, total)
+		})
+	}
+}
+
+func TestTransferBySuccession(t *testing.T) {
+	stub := newTestStub(t)
+	deceased := testClient{email: "deceased@example.com"}
+	partner := testClient{email: "partner@example.com"}
+	child := testClient{email: "child@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	registrar := testClient{email: "registrar@example.com", role: roleRegistrar}
+	r := new(RealEstate)
+
+	stub.mustInvoke(deceased, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "House", "Eldoret", 20, deceased.email, 200000, "USD", true, "")
+	})
+	stub.mustInvoke(deceased, func(ctx contractapi.TransactionContextInterface) error {
+		return r.TransferShare(ctx, "p1", deceased.email, partner.email, "40")
+	})
+	sell := func(seller testClient) string {
+		var transactionId string
+		stub.mustInvoke(seller, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			transactionId, err = r.BuyProperty(ctx, "p1", buyer.email, seller.email)
+			return err
+		})
+		return transactionId
+	}
+	assert.Empty(t, sell(partner), "the sale waits for the other owner")
+
+	heirs := []Owner{{Email: partner.email, Share: 50}, {Email: child.email, Share: 50}}
+	succeed := func(client testClient) (string, error) {
+		var transactionId string
+		err := stub.invoke(client, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			transactionId, err = r.TransferBySuccession(ctx, "p1", deceased.email, heirs, "9c1f0b")
+			return err
+		})
+		return transactionId, err
+	}
+	_, err := succeed(partner)
+	assert.ErrorContains(t, err, ErrPermissionDenied)
+	transactionId, err := succeed(registrar)
+	require.NoError(t, err)
+
+	// The heirs split the deceased's 60%; the surviving partner keeps their own 40% on top.
+	var detail *PropertyDetail
+	stub.mustInvoke(child, func(ctx contractapi.TransactionContextInterface) error {
+		detail, err = r.GetProperty(ctx, "p1")
+		return err
+	})
+	assert.ElementsMatch(t, []Owner{{Email: partner.email, Share: 70}, {Email: child.email, Share: 30}}, detail.Property.Owners)
+	assert.False(t, detail.Property.IsListed)
+
+	var transactions []Transaction
+	stub.mustInvoke(child, func(ctx contractapi.TransactionContextInterface) error {
+		transactions, err = r.GetTransactionsByParty(ctx, child.email)
+		return err
+	})
+	require.Len(t, transactions, 1)
+	succession := transactions[0]
+	assert.Equal(t, transactionId, succession.Id)
+	assert.Equal(t, TransactionTypeSuccession, succession.Type)
+	assert.Equal(t, deceased.email, succession.SellerEmail)
+	// The record keeps what each heir inherited of the whole property.
+	assert.Equal(t, []Owner{{Email: partner.email, Share: 30}, {Email: child.email, Share: 30}}, succession.Heirs)
+	assert.Equal(t, "9c1f0b", succession.ProbateHash)
+	assert.Equal(t, registrar.email, succession.ApprovedBy)
+
+	// Consents given under the old share split no longer count.
+	assert.Empty(t, sell(child))
+	assert.NotEmpty(t, sell(partner))
+}
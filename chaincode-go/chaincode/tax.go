@@ -0,0 +1,287 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TaxBracket charges Rate percent on the part of the sale amount between Min and Max, which are
+// minor units of Currency. A Max of zero leaves the bracket open-ended, which is only allowed for
+// the last bracket. A jurisdiction has one schedule per currency.
+type TaxBracket struct {
+	Min      int64   `json:"min"`
+	Max      int64   `json:"max"`
+	Rate     float64 `json:"rate"`
+	Currency string  `json:"currency" metadata:",optional"`
+}
+
+// TaxLine is the duty charged by one bracket, in minor units of the sale's currency.
+type TaxLine struct {
+	Min     int64   `json:"min"`
+	Max     int64   `json:"max"`
+	Rate    float64 `json:"rate"`
+	Taxable int64   `json:"taxable"`
+	Duty    int64   `json:"duty"`
+}
+
+// TaxReportEntry sums the sales of one month, jurisdiction and currency in minor units.
+type TaxReportEntry struct {
+	Period       string `json:"period"`
+	Jurisdiction string `json:"jurisdiction"`
+	Currency     string `json:"currency"`
+	Transactions int    `json:"transactions"`
+	SalesVolume  int64  `json:"sales_volume"`
+	TotalDuty    int64  `json:"total_duty"`
+}
+
+const taxBracketCompositeKey = "taxbracket~jurisdiction~min~max~rate"
+
+// DefaultJurisdiction is the schedule applied to locations without one of their own.
+const DefaultJurisdiction = "default"
+
+const taxPeriodLayout = "2006-01"
+
+// SetTaxSchedule replaces the brackets of a jurisdiction in currency, leaving its schedules in
+// other currencies alone. Only admins may set tax schedules.
+func (r *RealEstate) SetTaxSchedule(ctx contractapi.TransactionContextInterface, jurisdiction string, currency string, brackets []TaxBracket) error {
+	if _, err := requireRole(ctx, "set tax schedules", roleAdmin); err != nil {
+		return err
+	}
+	if jurisdiction == "" {
+		return invalidArgument("jurisdiction should not be empty")
+	}
+	if err := validateCurrency(currency); err != nil {
+		return err
+	}
+	sort.Slice(brackets, func(i, j int) bool { return brackets[i].Min < brackets[j].Min })
+	for i, bracket := range brackets {
+		if bracket.Currency != "" && bracket.Currency != currency {
+			return invalidArgument("tax bracket starting at %s is not in %s", formatMoney(bracket.Min, bracket.Currency), currency)
+		}
+		if bracket.Min < 0 || bracket.Rate < 0 || bracket.Rate > 100 {
+			return invalidArgument("invalid tax bracket starting at %s", formatMoney(bracket.Min, currency))
+		}
+		if bracket.Max == 0 && i != len(brackets)-1 {
+			return invalidArgument("only the last tax bracket can be open-ended")
+		}
+		if bracket.Max != 0 && bracket.Max <= bracket.Min {
+			return invalidArgument("tax bracket starting at %s must end above its start", formatMoney(bracket.Min, currency))
+		}
+		if i > 0 && bracket.Min < brackets[i-1].Max {
+			return invalidArgument("tax bracket starting at %s overlaps the previous bracket", formatMoney(bracket.Min, currency))
+		}
+	}
+
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(taxBracketCompositeKey, []string{"taxbracket", jurisdiction})
+	if err != nil {
+		return errors.New("failed to get tax schedule")
+	}
+	defer resultIterator.Close()
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return errors.New("failed to iterate over tax brackets")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		_, bracketCurrency, err := parseMoney(keyParts[2])
+		if err != nil {
+			return err
+		}
+		if bracketCurrency != currency {
+			continue
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return errors.New("failed to delete old tax bracket")
+		}
+	}
+
+	for _, bracket := range brackets {
+		bracketKey, err := ctx.GetStub().CreateCompositeKey(taxBracketCompositeKey, []string{"taxbracket", jurisdiction, formatMoney(bracket.Min, currency), formatMoney(bracket.Max, currency), formatAmount(bracket.Rate)})
+		if err != nil {
+			log.Println("failed to create composite key for tax bracket")
+			return errors.New("failed to create composite key for tax bracket")
+		}
+		err = ctx.GetStub().PutState(bracketKey, []byte{0x00})
+		if err != nil {
+			log.Println("failed to put tax bracket in world state")
+			return errors.New("failed to put tax bracket in world state")
+		}
+	}
+	return nil
+}
+
+// GetTaxSchedule returns the brackets of a jurisdiction in every currency it has a schedule in.
+// Brackets written before currencies were introduced are decimals in defaultCurrency.
+func (r *RealEstate) GetTaxSchedule(ctx contractapi.TransactionContextInterface, jurisdiction string) ([]TaxBracket, error) {
+	var brackets []TaxBracket
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(taxBracketCompositeKey, []string{"taxbracket", jurisdiction})
+	if err != nil {
+		return nil, errors.New("failed to get tax schedule")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over tax brackets")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		min, currency, err := parseMoney(keyParts[2])
+		if err != nil {
+			return nil, err
+		}
+		max, _, err := parseMoney(keyParts[3])
+		if err != nil {
+			return nil, err
+		}
+		rate, err := strconv.ParseFloat(keyParts[4], 64)
+		if err != nil {
+			return nil, err
+		}
+		brackets = append(brackets, TaxBracket{Min: min, Max: max, Rate: rate, Currency: currency})
+	}
+	// Keys sort as strings, so restore numeric order within each currency.
+	sort.Slice(brackets, func(i, j int) bool {
+		if brackets[i].Currency != brackets[j].Currency {
+			return brackets[i].Currency < brackets[j].Currency
+		}
+		return brackets[i].Min < brackets[j].Min
+	})
+	return brackets, nil
+}
+
+// GetTaxReport sums the duty of completed sales between from and to (inclusive, YYYY-MM-DD)
+// per month, jurisdiction and currency. Reversed sales were undone, so their duty drops out of the
+// report, and reversals and successions are not sales at all. Sale amounts are private, so only
+// authorized orgs get a report.
+func (r *RealEstate) GetTaxReport(ctx contractapi.TransactionContextInterface, from string, to string) ([]TaxReportEntry, error) {
+	authorized, err := canReadPrivateData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !authorized {
+		return nil, permissionDenied("client is not authorized to read tax data")
+	}
+	start, err := time.Parse(leaseDateLayout, from)
+	if err != nil {
+		return nil, invalidArgument("invalid from date: %s", from)
+	}
+	end, err := time.Parse(leaseDateLayout, to)
+	if err != nil {
+		return nil, invalidArgument("invalid to date: %s", to)
+	}
+	end = end.AddDate(0, 0, 1)
+
+	transactions, err := r.GetAllTransaction(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]*TaxReportEntry)
+	var order []string
+	for _, transaction := range transactions {
+		if transaction.Type != TransactionTypeSale || transaction.Status != TransactionCompleted {
+			continue
+		}
+		date, err := time.Parse(transactionDateLayout, transaction.Date)
+		if err != nil || date.Before(start) || !date.Before(end) {
+			continue
+		}
+		period := date.Format(taxPeriodLayout)
+		key := period + "|" + transaction.Jurisdiction + "|" + transaction.Currency
+		entry, ok := entries[key]
+		if !ok {
+			entry = &TaxReportEntry{Period: period, Jurisdiction: transaction.Jurisdiction, Currency: transaction.Currency}
+			entries[key] = entry
+			order = append(order, key)
+		}
+		entry.Transactions++
+		entry.SalesVolume += transaction.Amount
+		entry.TotalDuty += transaction.TotalTax
+	}
+	sort.Strings(order)
+	report := make([]TaxReportEntry, 0, len(order))
+	for _, key := range order {
+		report = append(report, *entries[key])
+	}
+	return report, nil
+}
+
+// computeTax applies the schedule of the property's location in the sale's currency, or the
+// default schedule in that currency when the location has none, to the sale amount. A sale in a
+// currency that neither schedule covers is rejected while other currencies are taxed, rather than
+// silently going untaxed. The result only depends on ledger state and the amount, so every
+// endorser computes the same duty.
+func (r *RealEstate) computeTax(ctx contractapi.TransactionContextInterface, location string, amount int64, currency string) (string, []TaxLine, int64, error) {
+	var taxed bool
+	var brackets []TaxBracket
+	jurisdiction := location
+	for _, candidate := range []string{location, DefaultJurisdiction} {
+		schedule, err := r.GetTaxSchedule(ctx, candidate)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		taxed = taxed || len(schedule) > 0
+		brackets = bracketsIn(schedule, currency)
+		if len(brackets) > 0 {
+			jurisdiction = candidate
+			break
+		}
+	}
+	if len(brackets) == 0 {
+		if taxed {
+			return "", nil, 0, invalidArgument("no tax schedule for %s covers sales in %s", location, currency)
+		}
+		return DefaultJurisdiction, nil, 0, nil
+	}
+	lines, total := applyBrackets(brackets, amount)
+	return jurisdiction, lines, total, nil
+}
+
+// applyBrackets charges each bracket's rate on the part of amount within it, rounding each duty
+// to the minor unit. brackets must be sorted and in the amount's currency.
+func applyBrackets(brackets []TaxBracket, amount int64) ([]TaxLine, int64) {
+	var lines []TaxLine
+	var total int64
+	for _, bracket := range brackets {
+		if amount <= bracket.Min {
+			break
+		}
+		upper := amount
+		if bracket.Max != 0 && bracket.Max < amount {
+			upper = bracket.Max
+		}
+		taxable := upper - bracket.Min
+		duty := int64(math.Round(float64(taxable) * bracket.Rate / 100))
+		lines = append(lines, TaxLine{Min: bracket.Min, Max: bracket.Max, Rate: bracket.Rate, Taxable: taxable, Duty: duty})
+		total += duty
+	}
+	return lines, total
+}
+
+// bracketsIn returns the brackets of schedule in currency, keeping their order.
+func bracketsIn(schedule []TaxBracket, currency string) []TaxBracket {
+	var brackets []TaxBracket
+	for _, bracket := range schedule {
+		if bracket.Currency == currency {
+			brackets = append(brackets, bracket)
+		}
+	}
+	return brackets
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,171 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBrackets(t *testing.T) {
+	brackets := []TaxBracket{
+		{Min: 0, Max: 10000000, Rate: 1, Currency: "USD"},
+		{Min: 10000000, Max: 50000000, Rate: 2.5, Currency: "USD"},
+		{Min: 50000000, Max: 0, Rate: 5, Currency: "USD"},
+	}
+	tests := []struct {
+		name      string
+		amount    int64
+		wantLines []TaxLine
+		wantTotal int64
+	}{
+		{name: "nothing to tax", amount: 0},
+		{
+			name:      "first bracket",
+			amount:    5000000,
+			wantLines: []TaxLine{{Min: 0, Max: 10000000, Rate: 1, Taxable: 5000000, Duty: 50000}},
+			wantTotal: 50000,
+		},
+		{
+			name:      "bracket boundary",
+			amount:    10000000,
+			wantLines: []TaxLine{{Min: 0, Max: 10000000, Rate: 1, Taxable: 10000000, Duty: 100000}},
+			wantTotal: 100000,
+		},
+		{
+			name:   "open-ended bracket",
+			amount: 60000000,
+			wantLines: []TaxLine{
+				{Min: 0, Max: 10000000, Rate: 1, Taxable: 10000000, Duty: 100000},
+				{Min: 10000000, Max: 50000000, Rate: 2.5, Taxable: 40000000, Duty: 1000000},
+				{Min: 50000000, Max: 0, Rate: 5, Taxable: 10000000, Duty: 500000},
+			},
+			wantTotal: 1600000,
+		},
+		{
+			name:      "duty rounds to the minor unit",
+			amount:    333,
+			wantLines: []TaxLine{{Min: 0, Max: 10000000, Rate: 1, Taxable: 333, Duty: 3}},
+			wantTotal: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines, total := applyBrackets(brackets, test.amount)
+			assert.Equal(t, test.wantLines, lines)
+			assert.Equal(t, test.wantTotal, total)
+		})
+	}
+}
+
+func TestComputeTax(t *testing.T) {
+	stub := shimtest.NewMockStub("realestate", nil)
+	stub.MockTransactionStart("schedules")
+	putBracket := func(jurisdiction string, min string, max string, rate string) {
+		key, err := stub.CreateCompositeKey(taxBracketCompositeKey, []string{"taxbracket", jurisdiction, min, max, rate})
+		require.NoError(t, err)
+		require.NoError(t, stub.PutState(key, []byte{0x00}))
+	}
+	putBracket("nairobi", "0 KES", "0 KES", "4.00")
+	putBracket(DefaultJurisdiction, "0 USD", "0 USD", "2.00")
+	// Brackets written before currencies were introduced are decimals in USD.
+	putBracket("boston", "0.00", "0.00", "1.50")
+	stub.MockTransactionEnd("schedules")
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	r := new(RealEstate)
+
+	tests := []struct {
+		name             string
+		location         string
+		amount           int64
+		currency         string
+		wantJurisdiction string
+		wantTotal        int64
+		wantErr          bool
+	}{
+		{name: "location schedule", location: "nairobi", amount: 1000000, currency: "KES", wantJurisdiction: "nairobi", wantTotal: 40000},
+		{name: "default schedule in the sale's currency", location: "nairobi", amount: 1000000, currency: "USD", wantJurisdiction: DefaultJurisdiction, wantTotal: 20000},
+		{name: "location without a schedule", location: "lagos", amount: 1000000, currency: "USD", wantJurisdiction: DefaultJurisdiction, wantTotal: 20000},
+		{name: "legacy schedule", location: "boston", amount: 1000000, currency: "USD", wantJurisdiction: "boston", wantTotal: 15000},
+		{name: "no schedule in the sale's currency", location: "nairobi", amount: 1000000, currency: "EUR", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jurisdiction, _, total, err := r.computeTax(ctx, test.location, test.amount, test.currency)
+			if test.wantErr {
+				var chaincodeErr *Error
+				require.ErrorAs(t, err, &chaincodeErr)
+				assert.Equal(t, ErrInvalidArgument, chaincodeErr.Code)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantJurisdiction, jurisdiction)
+			assert.Equal(t, test.wantTotal, total)
+		})
+	}
+}
+
+func TestTaxReport(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	buyer := testClient{email: "buyer@example.com"}
+	heir := testClient{email: "heir@example.com"}
+	admin := testClient{email: "admin@example.com", role: roleAdmin}
+	registrar := testClient{email: "registrar@example.com", role: roleRegistrar}
+	auditor := testClient{email: "auditor@example.com", role: roleAuditor}
+	r := new(RealEstate)
+
+	stub.mustInvoke(admin, func(ctx contractapi.TransactionContextInterface) error {
+		return r.SetTaxSchedule(ctx, DefaultJurisdiction, "USD", []TaxBracket{{Min: 0, Max: 0, Rate: 2}})
+	})
+	sale := func(propertyId string, price int64) string {
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			return r.RegisterProperty(ctx, propertyId, "Plot", "Nairobi", 10, owner.email, price, "USD", true, "")
+		})
+		var transactionId string
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			transactionId, err = r.BuyProperty(ctx, propertyId, buyer.email, owner.email)
+			return err
+		})
+		return transactionId
+	}
+	sale("p1", 100000)
+	reversed := sale("p2", 300000)
+	stub.mustInvoke(registrar, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.ReverseTransaction(ctx, reversed, "forged deed")
+		return err
+	})
+	stub.mustInvoke(registrar, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.TransferBySuccession(ctx, "p1", buyer.email, []Owner{{Email: heir.email, Share: 100}}, "5f2b")
+		return err
+	})
+
+	// Only the sale of p1 is a completed sale: the sale of p2 was reversed, and neither the
+	// reversal nor the succession is a sale.
+	today := time.Now().UTC()
+	var report []TaxReportEntry
+	stub.mustInvoke(auditor, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		report, err = r.GetTaxReport(ctx, today.AddDate(0, 0, -1).Format(leaseDateLayout), today.AddDate(0, 0, 1).Format(leaseDateLayout))
+		return err
+	})
+	assert.Equal(t, []TaxReportEntry{{
+		Period:       today.Format(taxPeriodLayout),
+		Jurisdiction: DefaultJurisdiction,
+		Currency:     "USD",
+		Transactions: 1,
+		SalesVolume:  100000,
+		TotalDuty:    2000,
+	}}, report)
+
+	err := stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.GetTaxReport(ctx, "2024-01-01", "2024-12-31")
+		return err
+	})
+	assert.Error(t, err, "sale amounts are private")
+}
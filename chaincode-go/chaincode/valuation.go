@@ -0,0 +1,221 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Valuation is an appraiser's opinion of a property's value, backed by the hash of their report.
+// The value is in minor units of Currency, which is the property's currency; valuations
+// submitted before currencies were introduced hold a decimal in defaultCurrency.
+type Valuation struct {
+	Id             string `json:"id"`
+	PropertyId     string `json:"property_id"`
+	AppraiserEmail string `json:"appraiser_email"`
+	Value          int64  `json:"value"`
+	Currency       string `json:"currency"`
+	Method         string `json:"method"`
+	Date           string `json:"date"`
+	ReportHash     string `json:"report_hash"`
+}
+
+// The date comes before the id so that a property's valuations are returned oldest first.
+const valuationCompositeKey = "valuation~propertyId~date~valuationId~appraiserEmail~value~method~reportHash"
+const valuationBandCompositeKey = "valuationband~basisPoints"
+
+var valuationMethods = map[string]bool{
+	"comparable": true,
+	"income":     true,
+	"cost":       true,
+}
+
+// SubmitValuation records a valuation by the client, who must be an appraiser.
+func (r *RealEstate) SubmitValuation(ctx contractapi.TransactionContextInterface, valuationId string, propertyId string, value int64, currency string, method string, reportHash string) error {
+	if _, err := requireRole(ctx, "submit valuations", roleAppraiser); err != nil {
+		return err
+	}
+	appraiserEmail, err := clientName(ctx)
+	if err != nil {
+		return err
+	}
+	property, _, err := r.getProperty(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	if property.Retired {
+		return invalidArgument("cannot value a retired property")
+	}
+	if ownsShare(property, appraiserEmail) {
+		return permissionDenied("owners cannot value their own property")
+	}
+	if err := validateMoney("valuation", value, currency); err != nil {
+		return err
+	}
+	if currency != property.Currency {
+		return invalidArgument("valuation must be in the property's currency %s", property.Currency)
+	}
+	if !valuationMethods[method] {
+		return invalidArgument("unknown valuation method %q", method)
+	}
+	if decoded, err := hex.DecodeString(reportHash); err != nil || len(decoded) != 32 {
+		return invalidArgument("report hash must be a hex encoded SHA-256 digest")
+	}
+	valuations, err := r.GetValuations(ctx, propertyId)
+	if err != nil {
+		return err
+	}
+	for _, valuation := range valuations {
+		if valuation.Id == valuationId {
+			return alreadyExists("valuation already exists")
+		}
+	}
+	date, err := lineageDate(ctx)
+	if err != nil {
+		return err
+	}
+	valuationKey, err := ctx.GetStub().CreateCompositeKey(valuationCompositeKey, []string{"valuation", propertyId, date, valuationId, appraiserEmail, formatMoney(value, currency), method, reportHash})
+	if err != nil {
+		log.Println("failed to create composite key for valuation")
+		return errors.New("failed to create composite key for valuation")
+	}
+	err = ctx.GetStub().PutState(valuationKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put valuation in world state")
+		return errors.New("failed to put valuation in world state")
+	}
+	return nil
+}
+
+// GetValuations returns every valuation of a property, oldest first.
+func (r *RealEstate) GetValuations(ctx contractapi.TransactionContextInterface, propertyId string) ([]Valuation, error) {
+	var valuations []Valuation
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(valuationCompositeKey, []string{"valuation", propertyId})
+	if err != nil {
+		return nil, errors.New("failed to get valuations")
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		queryResponse, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New("failed to iterate over valuations")
+		}
+		_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if splitKeyErr != nil {
+			return nil, fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+		}
+		value, currency, err := parseMoney(keyParts[5])
+		if err != nil {
+			return nil, err
+		}
+		valuations = append(valuations, Valuation{
+			Id:             keyParts[3],
+			PropertyId:     keyParts[1],
+			AppraiserEmail: keyParts[4],
+			Value:          value,
+			Currency:       currency,
+			Method:         keyParts[6],
+			Date:           keyParts[2],
+			ReportHash:     keyParts[7],
+		})
+	}
+	return valuations, nil
+}
+
+func (r *RealEstate) GetLatestValuation(ctx contractapi.TransactionContextInterface, propertyId string) (*Valuation, error) {
+	valuations, err := r.GetValuations(ctx, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	if len(valuations) == 0 {
+		return nil, notFound("property has no valuations")
+	}
+	return &valuations[len(valuations)-1], nil
+}
+
+// SetValuationBand sets how far, in percent, an asking price may stray from the latest
+// valuation before the property is flagged. A band of 0 turns the check off. Only admins may
+// set the band.
+func (r *RealEstate) SetValuationBand(ctx contractapi.TransactionContextInterface, band string) error {
+	if _, err := requireRole(ctx, "set the valuation band", roleAdmin); err != nil {
+		return err
+	}
+	current, err := r.getValuationBandKey(ctx)
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		if err := ctx.GetStub().DelState(current); err != nil {
+			return errors.New("failed to delete old valuation band state")
+		}
+	}
+	if percentage, err := strconv.ParseFloat(band, 64); err == nil && percentage == 0 {
+		return nil
+	}
+	basisPoints, err := parseShare(band)
+	if err != nil {
+		return err
+	}
+	bandKey, err := ctx.GetStub().CreateCompositeKey(valuationBandCompositeKey, []string{"valuationband", strconv.Itoa(basisPoints)})
+	if err != nil {
+		log.Println("failed to create composite key for valuation band")
+		return errors.New("failed to create composite key for valuation band")
+	}
+	err = ctx.GetStub().PutState(bandKey, []byte{0x00})
+	if err != nil {
+		log.Println("failed to put valuation band in world state")
+		return errors.New("failed to put valuation band in world state")
+	}
+	return nil
+}
+
+// loadValuation fills in the latest valuation of a property and flags an asking price outside
+// the configured band. A legacy valuation in another currency than the price is not compared.
+func (r *RealEstate) loadValuation(ctx contractapi.TransactionContextInterface, property *Property) error {
+	valuations, err := r.GetValuations(ctx, property.Id)
+	if err != nil || len(valuations) == 0 {
+		return err
+	}
+	latest := valuations[len(valuations)-1]
+	if latest.Currency != property.Currency {
+		return nil
+	}
+	property.Valuation = latest.Value
+	property.PriceDeviation = roundAmount(float64(property.Price-latest.Value) / float64(latest.Value) * 100)
+	bandKey, err := r.getValuationBandKey(ctx)
+	if err != nil || bandKey == "" {
+		return err
+	}
+	_, keyParts, splitKeyErr := ctx.GetStub().SplitCompositeKey(bandKey)
+	if splitKeyErr != nil {
+		return fmt.Errorf("error splitting key: %s", splitKeyErr.Error())
+	}
+	basisPoints, err := strconv.Atoi(keyParts[1])
+	if err != nil {
+		return err
+	}
+	property.PriceFlagged = math.Abs(property.PriceDeviation)*100 > float64(basisPoints)
+	return nil
+}
+
+func (r *RealEstate) getValuationBandKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(valuationBandCompositeKey, []string{"valuationband"})
+	if err != nil {
+		return "", errors.New("failed to read valuation band from world state")
+	}
+	defer resultIterator.Close()
+	if !resultIterator.HasNext() {
+		return "", nil
+	}
+	queryResponse, err := resultIterator.Next()
+	if err != nil {
+		return "", errors.New("failed to iterate over valuation bands")
+	}
+	return queryResponse.Key, nil
+}
@@ -0,0 +1,81 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuations(t *testing.T) {
+	stub := newTestStub(t)
+	owner := testClient{email: "owner@example.com"}
+	appraiser := testClient{email: "valuer@example.com", role: roleAppraiser}
+	admin := testClient{email: "admin@example.com", role: roleAdmin}
+	r := new(RealEstate)
+	report := sha256.Sum256([]byte("valuation report"))
+	reportHash := hex.EncodeToString(report[:])
+	first := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p1", "Plot", "Naivasha", 10, owner.email, 100000, "USD", true, "")
+	})
+	stub.mustInvoke(appraiser, func(ctx contractapi.TransactionContextInterface) error {
+		return r.RegisterProperty(ctx, "p2", "Plot", "Naivasha", 10, appraiser.email, 100000, "USD", true, "")
+	})
+	submit := func(client testClient, valuationId string, propertyId string, value int64, currency string, at time.Time) error {
+		return stub.invoke(client, at, func(ctx contractapi.TransactionContextInterface) error {
+			return r.SubmitValuation(ctx, valuationId, propertyId, value, currency, "comparable", reportHash)
+		})
+	}
+	setBand := func(band string) {
+		stub.mustInvoke(admin, func(ctx contractapi.TransactionContextInterface) error {
+			return r.SetValuationBand(ctx, band)
+		})
+	}
+	property := func() Property {
+		var detail *PropertyDetail
+		stub.mustInvoke(owner, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			detail, err = r.GetProperty(ctx, "p1")
+			return err
+		})
+		return detail.Property
+	}
+
+	assert.ErrorContains(t, submit(owner, "v1", "p1", 80000, "USD", first), ErrPermissionDenied)
+	assert.ErrorContains(t, submit(appraiser, "v1", "p2", 80000, "USD", first), "owners cannot value their own property")
+	assert.ErrorContains(t, submit(appraiser, "v1", "p1", 80000, "EUR", first), "property's currency USD")
+	err := stub.invoke(owner, time.Time{}, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := r.GetLatestValuation(ctx, "p1")
+		return err
+	})
+	assert.ErrorContains(t, err, ErrNotFound)
+
+	require.NoError(t, submit(appraiser, "v1", "p1", 80000, "USD", first))
+	assert.ErrorContains(t, submit(appraiser, "v1", "p1", 90000, "USD", first), ErrAlreadyExists)
+	p1 := property()
+	assert.Equal(t, int64(80000), p1.Valuation)
+	assert.Equal(t, 25.0, p1.PriceDeviation)
+	assert.False(t, p1.PriceFlagged, "no band is set")
+
+	setBand("20")
+	assert.True(t, property().PriceFlagged)
+	setBand("30")
+	assert.False(t, property().PriceFlagged)
+	setBand("20")
+
+	// The latest valuation is the one compared, whatever order the ids sort in.
+	require.NoError(t, submit(appraiser, "a0", "p1", 95000, "USD", first.Add(24*time.Hour)))
+	p1 = property()
+	assert.Equal(t, int64(95000), p1.Valuation)
+	assert.False(t, p1.PriceFlagged)
+
+	setBand("0")
+	require.NoError(t, submit(appraiser, "v2", "p1", 50000, "USD", first.Add(48*time.Hour)))
+	assert.False(t, property().PriceFlagged, "a band of 0 turns the check off")
+}
@@ -1,19 +1,96 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"os"
 	"project/chaincode-go/chaincode"
+	"strconv"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// When CHAINCODE_SERVER_ADDRESS is set the chaincode runs as an external service that the peer
+// connects to, so it can be started as its own process or container and debugged locally.
+// Otherwise the peer builds and launches it as usual.
+//
+//	CHAINCODE_SERVER_ADDRESS  listen address, e.g. 0.0.0.0:9999
+//	CHAINCODE_ID              package id returned by `peer lifecycle chaincode install`
+//	CHAINCODE_TLS_DISABLED    "true" to serve without TLS (default false)
+//	CHAINCODE_TLS_KEY         server private key file
+//	CHAINCODE_TLS_CERT        server certificate file
+//	CHAINCODE_CLIENT_CA_CERT  CA certificate file used to verify the peer, optional
 func main() {
-	assetChaincode, err := contractapi.NewChaincode(&chaincode.RealEstate{})
+	assetChaincode, err := contractapi.NewChaincode(chaincode.Contracts()...)
 	if err != nil {
 		log.Panicf("Error creating asset-transfer-basic chaincode: %v", err)
 	}
 
-	if err := assetChaincode.Start(); err != nil {
-		log.Panicf("Error starting asset-transfer-basic chaincode: %v", err)
+	address := os.Getenv("CHAINCODE_SERVER_ADDRESS")
+	if address == "" {
+		if err := assetChaincode.Start(); err != nil {
+			log.Panicf("Error starting asset-transfer-basic chaincode: %v", err)
+		}
+		return
 	}
+
+	ccid := os.Getenv("CHAINCODE_ID")
+	if ccid == "" {
+		log.Panicf("CHAINCODE_ID must be set when CHAINCODE_SERVER_ADDRESS is")
+	}
+	tlsProps, err := getTLSProperties()
+	if err != nil {
+		log.Panicf("Error reading chaincode TLS settings: %v", err)
+	}
+	server := &shim.ChaincodeServer{
+		CCID:     ccid,
+		Address:  address,
+		CC:       assetChaincode,
+		TLSProps: tlsProps,
+	}
+	log.Printf("Starting chaincode %s as a service on %s", ccid, address)
+	if err := server.Start(); err != nil {
+		log.Panicf("Error starting asset-transfer-basic chaincode service: %v", err)
+	}
+}
+
+func getTLSProperties() (shim.TLSProperties, error) {
+	disabled := false
+	if value := os.Getenv("CHAINCODE_TLS_DISABLED"); value != "" {
+		var err error
+		disabled, err = strconv.ParseBool(value)
+		if err != nil {
+			return shim.TLSProperties{}, err
+		}
+	}
+	if disabled {
+		return shim.TLSProperties{Disabled: true}, nil
+	}
+
+	keyPath, certPath := os.Getenv("CHAINCODE_TLS_KEY"), os.Getenv("CHAINCODE_TLS_CERT")
+	if keyPath == "" || certPath == "" {
+		return shim.TLSProperties{}, errors.New("CHAINCODE_TLS_KEY and CHAINCODE_TLS_CERT must be set unless CHAINCODE_TLS_DISABLED is true")
+	}
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return shim.TLSProperties{}, err
+	}
+	cert, err := os.ReadFile(certPath)
+	if err != nil {
+		return shim.TLSProperties{}, err
+	}
+	var clientCACerts []byte
+	if caPath := os.Getenv("CHAINCODE_CLIENT_CA_CERT"); caPath != "" {
+		clientCACerts, err = os.ReadFile(caPath)
+		if err != nil {
+			return shim.TLSProperties{}, err
+		}
+	}
+	return shim.TLSProperties{
+		Disabled:      false,
+		Key:           key,
+		Cert:          cert,
+		ClientCACerts: clientCACerts,
+	}, nil
 }
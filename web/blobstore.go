@@ -0,0 +1,72 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore keeps uploaded property documents off chain. Only their hashes go on the ledger.
+type BlobStore interface {
+	Put(key string, content io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+type LocalBlobStore struct {
+	Root string
+}
+
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalBlobStore{Root: root}, nil
+}
+
+func (store *LocalBlobStore) Put(key string, content io.Reader) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+func (store *LocalBlobStore) Get(key string) (io.ReadCloser, error) {
+	path, err := store.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (store *LocalBlobStore) Delete(key string) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// path maps a key onto the store root, refusing keys that would escape it.
+func (store *LocalBlobStore) path(key string) (string, error) {
+	path := filepath.Join(store.Root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(store.Root)+string(os.PathSeparator)) {
+		return "", errors.New("invalid blob key")
+	}
+	return path, nil
+}
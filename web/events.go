@@ -0,0 +1,59 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const eventTransactionReversed = "TransactionReversed"
+
+// ListenForEvents keeps the Mongo read model in step with chaincode events, including those
+// caused by transactions submitted through other clients.
+func (handler *Handler) ListenForEvents(ctx context.Context, network *client.Network, chaincodeName string) {
+	events, err := network.ChaincodeEvents(ctx, chaincodeName)
+	if err != nil {
+		log.Println("failed to listen for chaincode events:", err)
+		return
+	}
+	for event := range events {
+		switch event.EventName {
+		case eventTransactionReversed:
+			var reversal ReversalDto
+			if err := json.Unmarshal(event.Payload, &reversal); err != nil {
+				log.Println("failed to decode reversal event:", err)
+				continue
+			}
+			if err := handler.applyReversal(reversal); err != nil {
+				log.Println("failed to apply reversal event:", err)
+			}
+		}
+	}
+}
+
+// applyReversal hands the property in Mongo back to the restored owner and notifies both parties.
+func (handler *Handler) applyReversal(reversal ReversalDto) error {
+	if err := handler.syncOwners(reversal.PropertyId); err != nil {
+		return err
+	}
+	filter := bson.M{"_id": reversal.PropertyId}
+	update := bson.M{"$set": bson.M{"is_listed": false}}
+	if _, err := handler.PropertyCollection.UpdateOne(context.Background(), filter, update); err != nil {
+		return err
+	}
+	if err := handler.syncTransactions(reversal.PropertyId); err != nil {
+		return err
+	}
+	message := fmt.Sprintf("transaction %s on property %s was reversed by %s: %s", reversal.TransactionId, reversal.PropertyId, reversal.RegistrarEmail, reversal.Justification)
+	handler.notify(reversal.RestoredOwner, message)
+	handler.notify(reversal.PreviousOwner, message)
+	return nil
+}
+
+func (handler *Handler) notify(email string, message string) {
+	log.Printf("notification to %s: %s", email, message)
+}
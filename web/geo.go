@@ -0,0 +1,125 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// GeoPolygon is a GeoJSON Polygon. Coordinates are [longitude, latitude] pairs and the first
+// ring is the outer boundary of the parcel.
+type GeoPolygon struct {
+	Type        string        `json:"type" bson:"type"`
+	Coordinates [][][]float64 `json:"coordinates" bson:"coordinates"`
+}
+
+func ValidateBoundary(boundary *GeoPolygon) error {
+	if boundary.Type != "Polygon" {
+		return errors.New("boundary must be a GeoJSON Polygon")
+	}
+	if len(boundary.Coordinates) == 0 {
+		return errors.New("boundary must have an outer ring")
+	}
+	for _, ring := range boundary.Coordinates {
+		if len(ring) < 4 {
+			return errors.New("boundary rings need at least four positions")
+		}
+		for _, position := range ring {
+			if len(position) != 2 {
+				return errors.New("boundary positions must be [longitude, latitude]")
+			}
+			if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+				return fmt.Errorf("boundary position %v is out of range", position)
+			}
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return errors.New("boundary rings must be closed")
+		}
+	}
+	return nil
+}
+
+// BoundaryHash is the hex SHA-256 of the boundary's JSON encoding, anchored on the ledger.
+func BoundaryHash(boundary *GeoPolygon) (string, error) {
+	data, err := json.Marshal(boundary)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// BoundariesOverlap reports whether the interiors of two parcels overlap. Mongo's
+// $geoIntersects also matches parcels that merely share an edge, which neighbouring plots
+// always do, so candidates are checked again here on their outer rings.
+func BoundariesOverlap(a *GeoPolygon, b *GeoPolygon) bool {
+	ringA, ringB := a.Coordinates[0], b.Coordinates[0]
+	for i := 0; i < len(ringA)-1; i++ {
+		for j := 0; j < len(ringB)-1; j++ {
+			if segmentsCross(ringA[i], ringA[i+1], ringB[j], ringB[j+1]) {
+				return true
+			}
+		}
+	}
+	return ringHasPointInside(ringA, ringB) || ringHasPointInside(ringB, ringA)
+}
+
+// ringHasPointInside checks the vertices, edge midpoints and centroid of ring against other.
+func ringHasPointInside(ring [][]float64, other [][]float64) bool {
+	var sumX, sumY float64
+	for i := 0; i < len(ring)-1; i++ {
+		midpoint := []float64{(ring[i][0] + ring[i+1][0]) / 2, (ring[i][1] + ring[i+1][1]) / 2}
+		if strictlyInside(ring[i], other) || strictlyInside(midpoint, other) {
+			return true
+		}
+		sumX += ring[i][0]
+		sumY += ring[i][1]
+	}
+	centroid := []float64{sumX / float64(len(ring)-1), sumY / float64(len(ring)-1)}
+	return strictlyInside(centroid, ring) && strictlyInside(centroid, other)
+}
+
+// strictlyInside is a ray-casting test that treats points on the ring itself as outside.
+func strictlyInside(point []float64, ring [][]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-2; i < len(ring)-1; j, i = i, i+1 {
+		if orientation(ring[j], ring[i], point) == 0 && onSegment(ring[j], ring[i], point) {
+			return false
+		}
+		if (ring[i][1] > point[1]) != (ring[j][1] > point[1]) {
+			x := (ring[j][0]-ring[i][0])*(point[1]-ring[i][1])/(ring[j][1]-ring[i][1]) + ring[i][0]
+			if point[0] < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// segmentsCross reports a proper crossing; segments that only touch or run along each other do not count.
+func segmentsCross(p1, p2, q1, q2 []float64) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+	return d1*d2 < 0 && d3*d4 < 0
+}
+
+func orientation(a, b, c []float64) float64 {
+	value := (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	}
+	return 0
+}
+
+func onSegment(a, b, point []float64) bool {
+	return point[0] >= min(a[0], b[0]) && point[0] <= max(a[0], b[0]) &&
+		point[1] >= min(a[1], b[1]) && point[1] <= max(a[1], b[1])
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// square is the closed ring of an axis-aligned square with its lower left corner at x, y.
+func square(x, y, side float64) *GeoPolygon {
+	return &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+		{x, y}, {x + side, y}, {x + side, y + side}, {x, y + side}, {x, y},
+	}}}
+}
+
+func TestBoundariesOverlap(t *testing.T) {
+	plot := square(36, -2, 0.5)
+
+	// Neighbouring plots share an edge or a corner, which is not an overlap.
+	assert.False(t, BoundariesOverlap(plot, square(36.5, -2, 0.5)))
+	assert.False(t, BoundariesOverlap(plot, square(36.5, -1.5, 0.5)))
+	assert.False(t, BoundariesOverlap(plot, square(38, -1, 0.5)))
+
+	assert.True(t, BoundariesOverlap(plot, square(36.25, -1.75, 0.5)), "partial overlap")
+	assert.True(t, BoundariesOverlap(plot, square(36.125, -1.875, 0.25)), "parcel inside another")
+	assert.True(t, BoundariesOverlap(square(36.125, -1.875, 0.25), plot), "parcel around another")
+	assert.True(t, BoundariesOverlap(plot, square(36, -2, 0.5)), "the same parcel twice")
+
+	// Two strips crossing like a plus sign have no corner inside each other.
+	horizontal := &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{{0, 1}, {3, 1}, {3, 2}, {0, 2}, {0, 1}}}}
+	vertical := &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{{1, 0}, {2, 0}, {2, 3}, {1, 3}, {1, 0}}}}
+	assert.True(t, BoundariesOverlap(horizontal, vertical))
+}
+
+func TestValidateBoundary(t *testing.T) {
+	require.NoError(t, ValidateBoundary(square(36.80, -1.30, 0.01)))
+
+	open := square(36.80, -1.30, 0.01)
+	open.Coordinates[0] = open.Coordinates[0][:4]
+	assert.EqualError(t, ValidateBoundary(open), "boundary rings must be closed")
+
+	outOfRange := square(179.995, -1.30, 0.01)
+	assert.ErrorContains(t, ValidateBoundary(outOfRange), "out of range")
+
+	point := &GeoPolygon{Type: "Point", Coordinates: [][][]float64{{{36.8, -1.3}}}}
+	assert.EqualError(t, ValidateBoundary(point), "boundary must be a GeoJSON Polygon")
+	assert.EqualError(t, ValidateBoundary(&GeoPolygon{Type: "Polygon"}), "boundary must have an outer ring")
+}
+
+func TestBoundaryHash(t *testing.T) {
+	hash, err := BoundaryHash(square(36.80, -1.30, 0.01))
+	require.NoError(t, err)
+	assert.Len(t, hash, 64)
+	again, err := BoundaryHash(square(36.80, -1.30, 0.01))
+	require.NoError(t, err)
+	assert.Equal(t, hash, again)
+	other, err := BoundaryHash(square(36.81, -1.30, 0.01))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
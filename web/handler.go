@@ -2,10 +2,14 @@ package web
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,6 +17,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,12 +25,28 @@ import (
 )
 
 type Handler struct {
-	Contract           *client.Contract
-	UserCollection     *mongo.Collection
-	PropertyCollection *mongo.Collection
-	JwtKey             string
+	Contract              *client.Contract
+	RoleContracts         map[string]*client.Contract
+	UserCollection        *mongo.Collection
+	PropertyCollection    *mongo.Collection
+	TransactionCollection *mongo.Collection
+	JwtKey                string
+	BlobStore             BlobStore
 }
 
+// contractFor returns the contract signed by the identity enrolled for role, whose certificate
+// carries the role attribute that privileged chaincode functions check. Roles without an
+// identity of their own use the default one.
+func (handler *Handler) contractFor(role string) *client.Contract {
+	if contract, ok := handler.RoleContracts[role]; ok {
+		return contract
+	}
+	return handler.Contract
+}
+
+// maxDocumentSize caps the size of a single uploaded property document.
+const maxDocumentSize = 32 << 20
+
 func (handler *Handler) generateToken(user User) (string, error) {
 	expirationTime := time.Now().Add(5 * time.Minute)
 	claims := &Claims{
@@ -33,6 +54,7 @@ func (handler *Handler) generateToken(user User) (string, error) {
 		Name:     user.Name,
 		Email:    user.Email,
 		Password: user.Password,
+		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
@@ -116,7 +138,15 @@ func (handler *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		CreateResponse(w, err, nil, http.StatusBadRequest)
 		return
 	}
-	_, err = handler.Contract.SubmitTransaction("RegisterUser", userId, user.Name, user.Email, user.Address, user.Contact, string(bcryptPassword))
+	privateDetails, err := json.Marshal(UserPrivateDto{Address: user.Address, Contact: user.Contact, Password: string(bcryptPassword)})
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	_, err = handler.Contract.Submit("user:Register",
+		client.WithArguments(userId, user.Name, user.Email),
+		client.WithTransient(map[string][]byte{"user": privateDetails}),
+	)
 	if err != nil {
 		log.Println("error in chaincode")
 		CreateResponse(w, err, nil, http.StatusBadRequest)
@@ -125,6 +155,8 @@ func (handler *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	savedUser := ConvertToDto(user, User{})
 	savedUser.UserId = userId
 	savedUser.Password = string(bcryptPassword)
+	// Self-registered users always start as plain users; admins grant other roles with GrantRole.
+	savedUser.Role = RoleUser
 	_, err = handler.UserCollection.InsertOne(context.Background(), savedUser)
 	if err != nil {
 		CreateResponse(w, err, nil, http.StatusBadRequest)
@@ -133,10 +165,39 @@ func (handler *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	CreateResponse(w, nil, "User RegisterSuccessFully", http.StatusOK)
 }
 
+// GrantRole sets the role of a registered user. Only admins can grant roles.
+func (handler *Handler) GrantRole(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can grant roles"), nil, http.StatusForbidden)
+		return
+	}
+	var request RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := ValidateRoleRequest(request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	result, err := handler.UserCollection.UpdateOne(context.Background(), bson.M{"email": request.Email}, bson.M{"$set": bson.M{"role": request.Role}})
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		CreateResponse(w, errors.New("user not found"), nil, http.StatusNotFound)
+		return
+	}
+	CreateResponse(w, nil, "Role Granted", http.StatusOK)
+}
+
 func (handler *Handler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	data, err := handler.Contract.EvaluateTransaction("GetAllUsers")
+	claims := r.Context().Value("claims").(*Claims)
+	data, err := handler.contractFor(claims.Role).EvaluateTransaction("user:GetAll")
 	if err != nil {
-		CreateResponse(w, err, nil, http.StatusBadRequest)
+		CreateChaincodeErrorResponse(w, err)
 		return
 	}
 	if data == nil {
@@ -191,6 +252,9 @@ func (handler *Handler) RegisterProperty(w http.ResponseWriter, r *http.Request)
 		CreateResponse(w, err, nil, http.StatusBadRequest)
 		return
 	}
+	if property.Currency == "" {
+		property.Currency = DefaultCurrency
+	}
 	err := ValidatePropertyDto(property)
 	if err != nil {
 		CreateResponse(w, err, nil, http.StatusBadRequest)
@@ -207,22 +271,35 @@ func (handler *Handler) RegisterProperty(w http.ResponseWriter, r *http.Request)
 			return
 		}
 	}
-	fmt.Println("property", existingProperty)
 	if existingProperty.Id != "" {
 		CreateResponse(w, errors.New("combination of  title and location should be unique"), nil, http.StatusBadRequest)
 		return
 	}
+	boundaryHash := ""
+	if property.Boundary != nil {
+		if err := handler.checkBoundaryOverlap(property.Boundary); err != nil {
+			CreateResponse(w, err, nil, http.StatusBadRequest)
+			return
+		}
+		boundaryHash, err = BoundaryHash(property.Boundary)
+		if err != nil {
+			CreateResponse(w, err, nil, http.StatusBadRequest)
+			return
+		}
+	}
 	propertyId := "p" + uuid.New().String()
 	ownerEmail := claims.Email
-	_, err = handler.Contract.SubmitTransaction("RegisterProperty", propertyId, property.Title, property.Location, strconv.FormatFloat(property.Size, 'f', 2, 64), ownerEmail, strconv.FormatFloat(property.Price, 'f', 2, 64), strconv.FormatBool(property.IsListed))
+	_, err = handler.Contract.SubmitTransaction("property:Register", propertyId, property.Title, property.Location, strconv.FormatFloat(property.Size, 'f', 2, 64), ownerEmail, strconv.FormatInt(property.Price, 10), property.Currency, strconv.FormatBool(property.IsListed), boundaryHash)
 	if err != nil {
 		log.Println("error in chaincode")
-		CreateResponse(w, err, nil, http.StatusBadRequest)
+		CreateChaincodeErrorResponse(w, err)
 		return
 	}
 	savedProperty := ConvertToDto(property, Property{})
 	savedProperty.Id = propertyId
 	savedProperty.OwnerEmail = ownerEmail
+	savedProperty.Owners = []Owner{{Email: ownerEmail, Share: 100}}
+	savedProperty.RegisteredAt = time.Now().UTC()
 	_, err = handler.PropertyCollection.InsertOne(context.Background(), savedProperty)
 	if err != nil {
 		CreateResponse(w, err, nil, http.StatusBadRequest)
@@ -232,9 +309,16 @@ func (handler *Handler) RegisterProperty(w http.ResponseWriter, r *http.Request)
 }
 
 func (handler *Handler) GetAllProperty(w http.ResponseWriter, r *http.Request) {
-	data, err := handler.Contract.EvaluateTransaction("GetAllProperty")
+	// owner and location filters are answered from the chaincode's secondary indexes.
+	function, args := "property:GetAll", []string{}
+	if owner := r.URL.Query().Get("owner"); owner != "" {
+		function, args = "property:GetByOwner", []string{owner}
+	} else if location := r.URL.Query().Get("location"); location != "" {
+		function, args = "property:GetByLocation", []string{location}
+	}
+	data, err := handler.Contract.EvaluateTransaction(function, args...)
 	if err != nil {
-		CreateResponse(w, err, nil, http.StatusBadRequest)
+		CreateChaincodeErrorResponse(w, err)
 		return
 	}
 	if data == nil {
@@ -278,7 +362,11 @@ func (handler *Handler) BuyProperty(w http.ResponseWriter, r *http.Request) {
 		CreateResponse(w, errors.New("property is not listed for sale"), nil, http.StatusBadRequest)
 		return
 	}
-	if claims.Email != property.OwnerEmail {
+	sellerEmail := claims.Email
+	if principal := r.URL.Query().Get("onBehalfOf"); principal != "" {
+		sellerEmail = principal
+	}
+	if !IsOwner(property, sellerEmail) {
 		CreateResponse(w, errors.New("seller is not the current owner of the property"), nil, http.StatusBadRequest)
 		return
 	}
@@ -286,31 +374,67 @@ func (handler *Handler) BuyProperty(w http.ResponseWriter, r *http.Request) {
 		CreateResponse(w, errors.New("buyer cannot be the current owner"), nil, http.StatusBadRequest)
 		return
 	}
-	data, err := handler.Contract.SubmitTransaction("BuyProperty", propertyId, buyerEmail, claims.Email)
+	// A negotiated amount, in minor units of the listing's currency, stays in the private
+	// collection; without one the listed price is used.
+	function := "transfer:Buy"
+	proposalOptions := []client.ProposalOption{client.WithArguments(propertyId, buyerEmail, claims.Email)}
+	if sellerEmail != claims.Email {
+		function = "transfer:BuyAsAgent"
+		proposalOptions = []client.ProposalOption{client.WithArguments(propertyId, buyerEmail, claims.Email, sellerEmail)}
+	}
+	if amount := r.URL.Query().Get("amount"); amount != "" {
+		saleAmount, err := strconv.ParseInt(amount, 10, 64)
+		if err != nil || saleAmount <= 0 {
+			CreateResponse(w, errors.New("amount should be a positive amount in minor units"), nil, http.StatusBadRequest)
+			return
+		}
+		sale, err := json.Marshal(SalePrivateDto{Amount: saleAmount, Currency: property.Currency})
+		if err != nil {
+			CreateResponse(w, err, nil, http.StatusBadRequest)
+			return
+		}
+		proposalOptions = append(proposalOptions, client.WithTransient(map[string][]byte{"sale": sale}))
+	}
+	data, err := handler.Contract.Submit(function, proposalOptions...)
 	if err != nil {
 		log.Println("error in chaincode")
-		CreateResponse(w, err, nil, http.StatusBadRequest)
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if len(data) == 0 {
+		CreateResponse(w, nil, "Sale recorded, awaiting consent of co-owners", http.StatusOK)
 		return
 	}
 	property.OwnerEmail = buyerEmail
 	property.IsListed = false
+	property.Owners = []Owner{{Email: buyerEmail, Share: 100}}
 	filter = bson.M{"_id": propertyId}
-	update := bson.M{"$set": bson.M{"owner_email": buyerEmail, "is_listed": false}}
+	update := bson.M{"$set": bson.M{"owner_email": buyerEmail, "is_listed": false, "owners": property.Owners}}
 	_, err = handler.PropertyCollection.UpdateOne(context.Background(), filter, update)
 	if err != nil {
 		CreateResponse(w, err, nil, http.StatusBadRequest)
 		return
 	}
+	if err := handler.syncTransactions(propertyId); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
 
 	CreateResponse(w, nil, string(data), http.StatusOK)
 
 }
 
 func (handler *Handler) GetAllTransaction(w http.ResponseWriter, r *http.Request) {
-	transactionId := r.URL.Query().Get("transactionId")
-	data, err := handler.Contract.EvaluateTransaction("GetAllTransaction", transactionId)
+	claims := r.Context().Value("claims").(*Claims)
+	function, args := "transfer:GetAll", []string{r.URL.Query().Get("transactionId")}
+	if party := r.URL.Query().Get("party"); party != "" {
+		function, args = "transfer:GetByParty", []string{party}
+	} else if propertyId := r.URL.Query().Get("propertyId"); propertyId != "" {
+		function, args = "transfer:GetByProperty", []string{propertyId}
+	}
+	data, err := handler.contractFor(claims.Role).EvaluateTransaction(function, args...)
 	if err != nil {
-		CreateResponse(w, err, nil, http.StatusBadRequest)
+		CreateChaincodeErrorResponse(w, err)
 		return
 	}
 	if data == nil {
@@ -346,14 +470,30 @@ func (handler *Handler) UpdateFlag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if claims.Email != property.OwnerEmail {
+	// An agent acting under a power of attorney names the owner in onBehalfOf; the chaincode
+	// checks the delegation and records the agent.
+	sellerEmail := claims.Email
+	if principal := r.URL.Query().Get("onBehalfOf"); principal != "" {
+		sellerEmail = principal
+	}
+	if !IsOwner(property, sellerEmail) {
 		CreateResponse(w, errors.New("seller is not the current owner of the property"), nil, http.StatusBadRequest)
 		return
 	}
-	_, err := handler.Contract.SubmitTransaction("UpdateFlag", propertyId, claims.Email)
+	var data []byte
+	var err error
+	if sellerEmail != claims.Email {
+		data, err = handler.Contract.SubmitTransaction("property:ListAsAgent", propertyId, claims.Email, sellerEmail)
+	} else {
+		data, err = handler.Contract.SubmitTransaction("property:List", propertyId, claims.Email)
+	}
 	if err != nil {
 		log.Println("error in chaincode")
-		CreateResponse(w, err, nil, http.StatusBadRequest)
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if string(data) == consentPending {
+		CreateResponse(w, nil, handler.listingResult(propertyId, "Listing recorded, awaiting consent of co-owners"), http.StatusOK)
 		return
 	}
 	property.IsListed = true
@@ -364,6 +504,1449 @@ func (handler *Handler) UpdateFlag(w http.ResponseWriter, r *http.Request) {
 		CreateResponse(w, err, nil, http.StatusBadRequest)
 		return
 	}
-	CreateResponse(w, err, "Property Updated", http.StatusOK)
+	CreateResponse(w, err, handler.listingResult(propertyId, "Property Updated"), http.StatusOK)
+
+}
+
+func (handler *Handler) RepriceProperty(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request RepriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.Price <= 0 {
+		CreateResponse(w, errors.New("price should be a positive amount in minor units"), nil, http.StatusBadRequest)
+		return
+	}
+	var property Property
+	filter := bson.M{"_id": request.PropertyId}
+	if err := handler.PropertyCollection.FindOne(context.Background(), filter).Decode(&property); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("property not found"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if !IsOwner(property, claims.Email) {
+		CreateResponse(w, errors.New("only owners can reprice a property"), nil, http.StatusForbidden)
+		return
+	}
+	data, err := handler.Contract.SubmitTransaction("property:Reprice", request.PropertyId, strconv.FormatInt(request.Price, 10), claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if string(data) == consentPending {
+		CreateResponse(w, nil, "Price change recorded, awaiting consent of co-owners", http.StatusOK)
+		return
+	}
+	update := bson.M{"$set": bson.M{"price": request.Price}}
+	if _, err := handler.PropertyCollection.UpdateOne(context.Background(), filter, update); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, "Property Repriced", http.StatusOK)
+}
+
+func (handler *Handler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	propertyId := mux.Vars(r)["id"]
+	data, err := handler.contractFor(claims.Role).EvaluateTransaction("property:GetPrices", propertyId)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if data == nil {
+		CreateResponse(w, err, "no price history", http.StatusOK)
+		return
+	}
+	var records []PriceRecordDto
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode price data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, records, http.StatusOK)
+}
+
+func (handler *Handler) ScheduleListing(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request ListingRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := ValidateListingRequest(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	var property Property
+	filter := bson.M{"_id": request.PropertyId}
+	if err := handler.PropertyCollection.FindOne(context.Background(), filter).Decode(&property); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("property not found"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if !IsOwner(property, claims.Email) {
+		CreateResponse(w, errors.New("seller is not the current owner of the property"), nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.Contract.SubmitTransaction("property:ScheduleListing", request.PropertyId, claims.Email, request.StartsAt, request.EndsAt)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	switch string(data) {
+	case consentPending:
+		CreateResponse(w, nil, handler.listingResult(request.PropertyId, "Listing recorded, awaiting consent of co-owners"), http.StatusOK)
+	case listingScheduled:
+		CreateResponse(w, nil, handler.listingResult(request.PropertyId, "Listing scheduled"), http.StatusOK)
+	default:
+		update := bson.M{"$set": bson.M{"is_listed": true}}
+		if _, err := handler.PropertyCollection.UpdateOne(context.Background(), filter, update); err != nil {
+			CreateResponse(w, err, nil, http.StatusBadRequest)
+			return
+		}
+		CreateResponse(w, nil, handler.listingResult(request.PropertyId, "Property Updated"), http.StatusOK)
+	}
+}
+
+// listingResult attaches the asking price's deviation from the latest valuation to a listing
+// response. The check is advisory, so a failed lookup only drops it.
+func (handler *Handler) listingResult(propertyId string, message string) ListingResultDto {
+	result := ListingResultDto{Message: message}
+	data, err := handler.Contract.EvaluateTransaction("property:Get", propertyId)
+	if err != nil {
+		log.Println("error in chaincode")
+		return result
+	}
+	var detail PropertyDetailDto
+	if err := json.Unmarshal(data, &detail); err != nil {
+		return result
+	}
+	if detail.Property.Valuation > 0 {
+		result.PriceCheck = &PriceCheckDto{
+			Price:     detail.Property.Price,
+			Currency:  detail.Property.Currency,
+			Valuation: detail.Property.Valuation,
+			Deviation: detail.Property.PriceDeviation,
+			Flagged:   detail.Property.PriceFlagged,
+		}
+	}
+	return result
+}
+
+func (handler *Handler) SubmitValuation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAppraiser {
+		CreateResponse(w, errors.New("only appraisers can submit valuations"), nil, http.StatusForbidden)
+		return
+	}
+	var request ValuationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.Currency == "" {
+		request.Currency = DefaultCurrency
+	}
+	if err := ValidateValuationRequest(request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	valuationId := "v" + uuid.New().String()
+	_, err := handler.contractFor(claims.Role).SubmitTransaction("valuation:Submit", valuationId, request.PropertyId, strconv.FormatInt(request.Value, 10), request.Currency, request.Method, request.ReportHash)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, valuationId, http.StatusOK)
+}
+
+// GetValuations returns the latest valuation of a property together with its full history.
+func (handler *Handler) GetValuations(w http.ResponseWriter, r *http.Request) {
+	propertyId := r.URL.Query().Get("propertyId")
+	data, err := handler.Contract.EvaluateTransaction("valuation:GetAll", propertyId)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if data == nil {
+		CreateResponse(w, err, "no valuations", http.StatusOK)
+		return
+	}
+	var history ValuationHistoryDto
+	err = json.Unmarshal(data, &history.History)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode valuation data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	if len(history.History) > 0 {
+		history.Latest = &history.History[len(history.History)-1]
+	}
+	CreateResponse(w, nil, history, http.StatusOK)
+}
+
+func (handler *Handler) SetValuationBand(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can set the valuation band"), nil, http.StatusForbidden)
+		return
+	}
+	var request ValuationBandRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.Band < 0 || request.Band > 100 {
+		CreateResponse(w, errors.New("band should be between 0 and 100"), nil, http.StatusBadRequest)
+		return
+	}
+	_, err := handler.contractFor(claims.Role).SubmitTransaction("valuation:SetBand", strconv.FormatFloat(request.Band, 'f', 2, 64))
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Valuation Band Updated", http.StatusOK)
+}
+
+// RebuildIndexes backfills the chaincode's owner, location and transaction indexes for records
+// written before they existed.
+func (handler *Handler) RebuildIndexes(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can rebuild indexes"), nil, http.StatusForbidden)
+		return
+	}
+	_, err := handler.Contract.SubmitTransaction("property:RebuildIndexes")
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Indexes Rebuilt", http.StatusOK)
+}
+
+// RunMigration upgrades one batch of ledger records of the asset parameter ("user", "property"
+// or "transaction") to the chaincode's current schema version and reports the progress. Repeat
+// it with the returned bookmark until the response is done.
+func (handler *Handler) RunMigration(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can run migrations"), nil, http.StatusForbidden)
+		return
+	}
+	batchSize := "0"
+	if size := r.URL.Query().Get("batchSize"); size != "" {
+		if _, err := strconv.ParseInt(size, 10, 32); err != nil {
+			CreateResponse(w, errors.New("batchSize should be a number"), nil, http.StatusBadRequest)
+			return
+		}
+		batchSize = size
+	}
+	data, err := handler.contractFor(claims.Role).SubmitTransaction("registry:RunMigration", r.URL.Query().Get("asset"), batchSize, r.URL.Query().Get("bookmark"))
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var progress MigrationProgressDto
+	if err := json.Unmarshal(data, &progress); err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode migration progress: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, progress, http.StatusOK)
+}
+
+// MigrateAmounts converts the prices and amounts the read model recorded as decimals before
+// currencies were introduced into minor units of the chaincode's default currency. Ledger
+// records are upgraded by RunMigration.
+func (handler *Handler) MigrateAmounts(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can migrate amounts"), nil, http.StatusForbidden)
+		return
+	}
+	currency, err := handler.legacyCurrency()
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	scale := math.Pow10(currency.Digits)
+	var result AmountMigrationDto
+	legacy := bson.M{"currency": bson.M{"$exists": false}}
+	minorUnits := func(field string) bson.M {
+		return bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$" + field, scale}}, 0}}}
+	}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"price": minorUnits("price"), "currency": currency.Code}}}}
+	properties, err := handler.PropertyCollection.UpdateMany(context.Background(), legacy, update)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	update = mongo.Pipeline{{{Key: "$set", Value: bson.M{"amount": minorUnits("amount"), "currency": currency.Code}}}}
+	transactions, err := handler.TransactionCollection.UpdateMany(context.Background(), legacy, update)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	result.Properties = properties.ModifiedCount
+	result.Transactions = transactions.ModifiedCount
+	CreateResponse(w, nil, result, http.StatusOK)
+}
+
+// legacyCurrency asks the chaincode which currency amounts recorded before currencies were
+// introduced are in, and how many digits its minor unit has.
+func (handler *Handler) legacyCurrency() (*CurrencyDto, error) {
+	data, err := handler.Contract.EvaluateTransaction("registry:GetCurrencies")
+	if err != nil {
+		return nil, err
+	}
+	var currencies []CurrencyDto
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return nil, fmt.Errorf("failed to decode currencies: %v", err)
+	}
+	for _, currency := range currencies {
+		if currency.Default {
+			return &currency, nil
+		}
+	}
+	return nil, errors.New("chaincode has no default currency")
+}
+
+func (handler *Handler) GetProperty(w http.ResponseWriter, r *http.Request) {
+	propertyId := r.URL.Query().Get("propertyId")
+	data, err := handler.Contract.EvaluateTransaction("property:Get", propertyId)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var property PropertyDetailDto
+	err = json.Unmarshal(data, &property)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode property data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, property, http.StatusOK)
+}
+
+func (handler *Handler) GetPropertyHistory(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	propertyId := r.URL.Query().Get("propertyId")
+	data, err := handler.contractFor(claims.Role).EvaluateTransaction("property:GetHistory", propertyId)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var history PropertyHistoryDto
+	err = json.Unmarshal(data, &history)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode property history: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, history, http.StatusOK)
+}
 
+func (handler *Handler) RegisterLien(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleLender {
+		CreateResponse(w, errors.New("only lenders can register liens"), nil, http.StatusForbidden)
+		return
+	}
+	var lien LienDto
+	if err := json.NewDecoder(r.Body).Decode(&lien); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if lien.Currency == "" {
+		lien.Currency = DefaultCurrency
+	}
+	err := ValidateLienDto(lien)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	filter := bson.M{"_id": lien.PropertyId}
+	if err := handler.PropertyCollection.FindOne(context.Background(), filter).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("property not found"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	lienId := "l" + uuid.New().String()
+	_, err = handler.contractFor(claims.Role).SubmitTransaction("lien:Register", lienId, lien.PropertyId, strconv.FormatInt(lien.Amount, 10), lien.Currency, strconv.Itoa(lien.Priority))
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, lienId, http.StatusOK)
+}
+
+func (handler *Handler) ReleaseLien(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleLender {
+		CreateResponse(w, errors.New("only lenders can release liens"), nil, http.StatusForbidden)
+		return
+	}
+	propertyId := r.URL.Query().Get("propertyId")
+	lienId := r.URL.Query().Get("lienId")
+	_, err := handler.contractFor(claims.Role).SubmitTransaction("lien:Release", propertyId, lienId)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Lien Released", http.StatusOK)
+}
+
+func (handler *Handler) TransferShare(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request ShareTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	err := ValidateShareTransfer(request)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	var property Property
+	filter := bson.M{"_id": request.PropertyId}
+	if err := handler.PropertyCollection.FindOne(context.Background(), filter).Decode(&property); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("property not found"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if !IsOwner(property, claims.Email) {
+		CreateResponse(w, errors.New("sender is not an owner of the property"), nil, http.StatusBadRequest)
+		return
+	}
+	if err := handler.UserCollection.FindOne(context.Background(), bson.M{"email": request.ToEmail}).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("recipient not registred"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	_, err = handler.Contract.SubmitTransaction("transfer:Share", request.PropertyId, claims.Email, request.ToEmail, strconv.FormatFloat(request.Share, 'f', 2, 64))
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	err = handler.syncOwners(request.PropertyId)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, "Share Transferred", http.StatusOK)
+}
+
+func (handler *Handler) SetConsentThreshold(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	propertyId := r.URL.Query().Get("propertyId")
+	threshold := r.URL.Query().Get("threshold")
+	data, err := handler.Contract.SubmitTransaction("property:SetConsentThreshold", propertyId, threshold, claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if string(data) == consentPending {
+		CreateResponse(w, nil, "Threshold change recorded, awaiting consent of co-owners", http.StatusOK)
+		return
+	}
+	CreateResponse(w, nil, "Consent Threshold Updated", http.StatusOK)
+}
+
+// syncOwners copies the owners recorded on the ledger into the property read model.
+func (handler *Handler) syncOwners(propertyId string) error {
+	data, err := handler.Contract.EvaluateTransaction("property:Get", propertyId)
+	if err != nil {
+		return err
+	}
+	var detail PropertyDetailDto
+	if err := json.Unmarshal(data, &detail); err != nil {
+		return fmt.Errorf("failed to decode property data: %v", err)
+	}
+	owners := make([]Owner, 0, len(detail.Property.Owners))
+	for _, owner := range detail.Property.Owners {
+		owners = append(owners, Owner(owner))
+	}
+	filter := bson.M{"_id": propertyId}
+	update := bson.M{"$set": bson.M{"owner_email": detail.Property.OwnerEmail, "owners": owners}}
+	_, err = handler.PropertyCollection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+func (handler *Handler) FreezeProperty(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if !CanFreeze(claims.Role) {
+		CreateResponse(w, errors.New("only registrars and courts can freeze properties"), nil, http.StatusForbidden)
+		return
+	}
+	var request FreezeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := ValidateFreezeRequest(request, false); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	freezeId := "f" + uuid.New().String()
+	_, err := handler.contractFor(claims.Role).SubmitTransaction("freeze:Freeze", request.PropertyId, freezeId, request.Reason, request.Reference)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if err := handler.syncFrozen(request.PropertyId); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, freezeId, http.StatusOK)
+}
+
+func (handler *Handler) UnfreezeProperty(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if !CanFreeze(claims.Role) {
+		CreateResponse(w, errors.New("only registrars and courts can unfreeze properties"), nil, http.StatusForbidden)
+		return
+	}
+	var request FreezeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := ValidateFreezeRequest(request, true); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	_, err := handler.contractFor(claims.Role).SubmitTransaction("freeze:Unfreeze", request.PropertyId, request.FreezeId, request.Reason)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if err := handler.syncFrozen(request.PropertyId); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, "Freeze Released", http.StatusOK)
+}
+
+func (handler *Handler) GetFreezes(w http.ResponseWriter, r *http.Request) {
+	propertyId := r.URL.Query().Get("propertyId")
+	data, err := handler.Contract.EvaluateTransaction("freeze:GetAll", propertyId)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if data == nil {
+		CreateResponse(w, err, "no freezes", http.StatusOK)
+		return
+	}
+	var freezes []FreezeDto
+	err = json.Unmarshal(data, &freezes)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode freeze data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, freezes, http.StatusOK)
+}
+
+// GetAuditLog lists who invoked which chaincode function, optionally between from and to
+// (RFC3339) and for one actor, given as an MSP id or certificate subject, one page at a time.
+// Pass the returned bookmark to get the next page.
+func (handler *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAuditor {
+		CreateResponse(w, errors.New("only auditors can read the audit log"), nil, http.StatusForbidden)
+		return
+	}
+	query := r.URL.Query()
+	pageSize := "0"
+	if size := query.Get("pageSize"); size != "" {
+		if _, err := strconv.ParseInt(size, 10, 32); err != nil {
+			CreateResponse(w, errors.New("pageSize should be a number"), nil, http.StatusBadRequest)
+			return
+		}
+		pageSize = size
+	}
+	data, err := handler.contractFor(claims.Role).EvaluateTransaction("audit:GetLog", query.Get("from"), query.Get("to"), query.Get("actor"), pageSize, query.Get("bookmark"))
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var page AuditPageDto
+	err = json.Unmarshal(data, &page)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode audit data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, page, http.StatusOK)
+}
+
+// syncFrozen copies the ledger's freeze status of a property into Mongo.
+func (handler *Handler) syncFrozen(propertyId string) error {
+	data, err := handler.Contract.EvaluateTransaction("property:Get", propertyId)
+	if err != nil {
+		return err
+	}
+	var detail PropertyDetailDto
+	if err := json.Unmarshal(data, &detail); err != nil {
+		return fmt.Errorf("failed to decode property data: %v", err)
+	}
+	filter := bson.M{"_id": propertyId}
+	update := bson.M{"$set": bson.M{"frozen": detail.Property.Frozen}}
+	_, err = handler.PropertyCollection.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+func (handler *Handler) ReverseTransaction(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleRegistrar {
+		CreateResponse(w, errors.New("only registrars can reverse transactions"), nil, http.StatusForbidden)
+		return
+	}
+	var request ReversalRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.TransactionId == "" || request.Justification == "" {
+		CreateResponse(w, errors.New("transaction_id and justification should not be empty"), nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.contractFor(claims.Role).SubmitTransaction("transfer:Reverse", request.TransactionId, request.Justification)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, string(data), http.StatusOK)
+}
+
+func (handler *Handler) TransferBySuccession(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleRegistrar {
+		CreateResponse(w, errors.New("only registrars can approve successions"), nil, http.StatusForbidden)
+		return
+	}
+	var request SuccessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := ValidateSuccession(request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	heirs, err := json.Marshal(request.Heirs)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.contractFor(claims.Role).SubmitTransaction("transfer:BySuccession", request.PropertyId, request.DeceasedEmail, string(heirs), request.ProbateHash)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if err := handler.syncOwners(request.PropertyId); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	filter := bson.M{"_id": request.PropertyId}
+	update := bson.M{"$set": bson.M{"is_listed": false}}
+	if _, err := handler.PropertyCollection.UpdateOne(context.Background(), filter, update); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := handler.syncTransactions(request.PropertyId); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, string(data), http.StatusOK)
+}
+
+func (handler *Handler) GrantDelegation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request DelegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := ValidateDelegation(request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := handler.UserCollection.FindOne(context.Background(), bson.M{"email": request.AgentEmail}).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("agent not registred"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.PropertyId == "" {
+		request.PropertyId = allProperties
+	}
+	scope, err := json.Marshal(request.Scope)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	delegationId := "dg" + uuid.New().String()
+	_, err = handler.Contract.SubmitTransaction("delegation:Grant", delegationId, claims.Email, request.AgentEmail, string(scope), request.PropertyId, request.Expiry)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, delegationId, http.StatusOK)
+}
+
+func (handler *Handler) RevokeDelegation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	delegationId := r.URL.Query().Get("delegationId")
+	_, err := handler.Contract.SubmitTransaction("delegation:Revoke", claims.Email, delegationId)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Delegation Revoked", http.StatusOK)
+}
+
+func (handler *Handler) GetDelegations(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	data, err := handler.Contract.EvaluateTransaction("delegation:GetAll", claims.Email)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if data == nil {
+		CreateResponse(w, err, "no delegations", http.StatusOK)
+		return
+	}
+	var delegations []DelegationDto
+	err = json.Unmarshal(data, &delegations)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode delegation data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, delegations, http.StatusOK)
+}
+
+func (handler *Handler) CreateLease(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var lease LeaseDto
+	if err := json.NewDecoder(r.Body).Decode(&lease); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if lease.Currency == "" {
+		lease.Currency = DefaultCurrency
+	}
+	err := ValidateLeaseDto(lease)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := handler.UserCollection.FindOne(context.Background(), bson.M{"email": lease.TenantEmail}).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("tenant not registred"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	leaseId := "ls" + uuid.New().String()
+	_, err = handler.Contract.SubmitTransaction("lease:Create", leaseId, lease.PropertyId, claims.Email, lease.TenantEmail, lease.StartDate, lease.EndDate, strconv.FormatInt(lease.Rent, 10), strconv.FormatInt(lease.Deposit, 10), lease.Currency)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, leaseId, http.StatusOK)
+}
+
+func (handler *Handler) SignLease(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	propertyId := r.URL.Query().Get("propertyId")
+	leaseId := r.URL.Query().Get("leaseId")
+	data, err := handler.Contract.SubmitTransaction("lease:Sign", propertyId, leaseId, claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Lease Signed, status "+string(data), http.StatusOK)
+}
+
+func (handler *Handler) RenewLease(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request LeaseRenewalRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.EndDate == "" || request.Rent <= 0 {
+		CreateResponse(w, errors.New("end_date and a positive rent are required"), nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.Contract.SubmitTransaction("lease:Renew", request.PropertyId, request.LeaseId, request.EndDate, strconv.FormatInt(request.Rent, 10), claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if string(data) == consentPending {
+		CreateResponse(w, nil, "Renewal recorded, awaiting the other party", http.StatusOK)
+		return
+	}
+	CreateResponse(w, nil, "Lease Renewed", http.StatusOK)
+}
+
+func (handler *Handler) TerminateLease(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	propertyId := r.URL.Query().Get("propertyId")
+	leaseId := r.URL.Query().Get("leaseId")
+	_, err := handler.Contract.SubmitTransaction("lease:Terminate", propertyId, leaseId, claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Lease Terminated", http.StatusOK)
+}
+
+func (handler *Handler) GetLeases(w http.ResponseWriter, r *http.Request) {
+	propertyId := r.URL.Query().Get("propertyId")
+	data, err := handler.Contract.EvaluateTransaction("lease:GetAll", propertyId)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if data == nil {
+		CreateResponse(w, err, "no leases", http.StatusOK)
+		return
+	}
+	var leases []LeaseDto
+	err = json.Unmarshal(data, &leases)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode lease data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, leases, http.StatusOK)
+}
+
+func (handler *Handler) CreateAuction(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request CreateAuctionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.PropertyId == "" || request.Deadline == "" {
+		CreateResponse(w, errors.New("property_id and deadline should not be empty"), nil, http.StatusBadRequest)
+		return
+	}
+	auctionId := "a" + uuid.New().String()
+	data, err := handler.Contract.SubmitTransaction("auction:Create", auctionId, request.PropertyId, claims.Email, request.Deadline)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if string(data) == consentPending {
+		CreateResponse(w, nil, "Auction recorded, awaiting consent of co-owners", http.StatusOK)
+		return
+	}
+	CreateResponse(w, nil, auctionId, http.StatusOK)
+}
+
+func (handler *Handler) SubmitBid(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request BidRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.AuctionId == "" || request.Amount <= 0 {
+		CreateResponse(w, errors.New("auction_id and a positive amount are required"), nil, http.StatusBadRequest)
+		return
+	}
+	// The salt is needed again to reveal the bid, so a generated one is returned to the bidder.
+	if request.Salt == "" {
+		request.Salt = uuid.New().String()
+	}
+	bid, err := json.Marshal(request)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.Contract.Submit("auction:SubmitBid",
+		client.WithArguments(request.AuctionId, claims.Email),
+		client.WithTransient(map[string][]byte{"bid": bid}),
+	)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, map[string]string{"hash": string(data), "salt": request.Salt}, http.StatusOK)
+}
+
+func (handler *Handler) RevealBid(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request BidRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	_, err := handler.Contract.SubmitTransaction("auction:RevealBid", request.AuctionId, claims.Email, strconv.FormatInt(request.Amount, 10), request.Salt)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Bid Revealed", http.StatusOK)
+}
+
+func (handler *Handler) CloseAuction(w http.ResponseWriter, r *http.Request) {
+	auctionId := r.URL.Query().Get("auctionId")
+	data, err := handler.Contract.SubmitTransaction("auction:Close", auctionId)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var result AuctionResultDto
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode auction data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	if result.Auction.WinnerEmail != "" {
+		filter := bson.M{"_id": result.Auction.PropertyId}
+		owners := []Owner{{Email: result.Auction.WinnerEmail, Share: 100}}
+		update := bson.M{"$set": bson.M{"owner_email": result.Auction.WinnerEmail, "is_listed": false, "owners": owners}}
+		_, err = handler.PropertyCollection.UpdateOne(context.Background(), filter, update)
+		if err != nil {
+			CreateResponse(w, err, nil, http.StatusBadRequest)
+			return
+		}
+		if err := handler.syncTransactions(result.Auction.PropertyId); err != nil {
+			CreateResponse(w, err, nil, http.StatusBadRequest)
+			return
+		}
+	}
+	CreateResponse(w, nil, result, http.StatusOK)
+}
+
+func (handler *Handler) GetAuction(w http.ResponseWriter, r *http.Request) {
+	auctionId := r.URL.Query().Get("auctionId")
+	data, err := handler.Contract.EvaluateTransaction("auction:Get", auctionId)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var result AuctionResultDto
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode auction data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, result, http.StatusOK)
+}
+
+func (handler *Handler) SetTaxSchedule(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can set the tax schedule"), nil, http.StatusForbidden)
+		return
+	}
+	var request TaxScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if request.Jurisdiction == "" {
+		CreateResponse(w, errors.New("jurisdiction field should not be empty"), nil, http.StatusBadRequest)
+		return
+	}
+	if err := ValidateCurrency(request.Currency); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	brackets, err := json.Marshal(request.Brackets)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	_, err = handler.contractFor(claims.Role).SubmitTransaction("tax:SetSchedule", request.Jurisdiction, request.Currency, string(brackets))
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	CreateResponse(w, nil, "Tax Schedule Updated", http.StatusOK)
+}
+
+func (handler *Handler) GetTaxSchedule(w http.ResponseWriter, r *http.Request) {
+	jurisdiction := r.URL.Query().Get("jurisdiction")
+	data, err := handler.Contract.EvaluateTransaction("tax:GetSchedule", jurisdiction)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if data == nil {
+		CreateResponse(w, err, "no tax schedule for jurisdiction", http.StatusOK)
+		return
+	}
+	var brackets []TaxBracketDto
+	err = json.Unmarshal(data, &brackets)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode tax schedule: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, brackets, http.StatusOK)
+}
+
+func (handler *Handler) GetTaxReport(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can view the tax report"), nil, http.StatusForbidden)
+		return
+	}
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	data, err := handler.contractFor(claims.Role).EvaluateTransaction("tax:GetReport", from, to)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var report []TaxReportEntryDto
+	err = json.Unmarshal(data, &report)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode tax report: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, report, http.StatusOK)
+}
+
+func (handler *Handler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	propertyId := mux.Vars(r)["id"]
+	var property Property
+	filter := bson.M{"_id": propertyId}
+	if err := handler.PropertyCollection.FindOne(context.Background(), filter).Decode(&property); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("property not found"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if !IsOwner(property, claims.Email) && claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only owners can attach documents"), nil, http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentSize)
+	if err := r.ParseMultipartForm(maxDocumentSize); err != nil {
+		CreateResponse(w, fmt.Errorf("failed to read upload: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		CreateResponse(w, errors.New("file field is required"), nil, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	name := r.FormValue("name")
+	if name == "" {
+		name = header.Filename
+	}
+	mediaType, err := DetectMediaType(file, header.Header.Get("Content-Type"))
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+
+	documentId := "d" + uuid.New().String()
+	blobKey := propertyId + "/" + documentId
+	hasher := sha256.New()
+	if err := handler.BlobStore.Put(blobKey, io.TeeReader(file, hasher)); err != nil {
+		CreateResponse(w, fmt.Errorf("failed to store document: %v", err), nil, http.StatusInternalServerError)
+		return
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	_, err = handler.contractFor(claims.Role).SubmitTransaction("document:Attach", propertyId, documentId, name, hash, mediaType, claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		handler.BlobStore.Delete(blobKey)
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	document := DocumentDto{Id: documentId, PropertyId: propertyId, Name: name, Hash: hash, MediaType: mediaType, UploaderEmail: claims.Email}
+	CreateResponse(w, nil, document, http.StatusOK)
+}
+
+func (handler *Handler) VerifyDocument(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	propertyId := vars["id"]
+	documentId := vars["documentId"]
+	data, err := handler.Contract.EvaluateTransaction("document:Get", propertyId, documentId)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusNotFound)
+		return
+	}
+	var document DocumentDto
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode document data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	content, err := handler.BlobStore.Get(propertyId + "/" + documentId)
+	if err != nil {
+		CreateResponse(w, fmt.Errorf("stored document is missing: %v", err), nil, http.StatusNotFound)
+		return
+	}
+	defer content.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, content); err != nil {
+		CreateResponse(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	storedHash := hex.EncodeToString(hasher.Sum(nil))
+	verification := DocumentVerification{
+		DocumentId: documentId,
+		LedgerHash: document.Hash,
+		StoredHash: storedHash,
+		Valid:      storedHash == document.Hash,
+	}
+	CreateResponse(w, nil, verification, http.StatusOK)
+}
+
+// checkBoundaryOverlap rejects a boundary whose interior overlaps a parcel that is still
+// registered, other than the excluded ones. The check reads the read model before the chaincode
+// call and the new parcel is only inserted after it, so two concurrent requests for overlapping
+// parcels can both pass it.
+func (handler *Handler) checkBoundaryOverlap(boundary *GeoPolygon, excludedIds ...string) error {
+	filter := bson.M{
+		"boundary": bson.M{"$geoIntersects": bson.M{"$geometry": boundary}},
+		"retired":  bson.M{"$ne": true},
+	}
+	if len(excludedIds) > 0 {
+		filter["_id"] = bson.M{"$nin": excludedIds}
+	}
+	cursor, err := handler.PropertyCollection.Find(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	var candidates []Property
+	if err := cursor.All(context.Background(), &candidates); err != nil {
+		return err
+	}
+	for _, candidate := range candidates {
+		if candidate.Boundary != nil && BoundariesOverlap(boundary, candidate.Boundary) {
+			return fmt.Errorf("boundary overlaps registered property %s", candidate.Id)
+		}
+	}
+	return nil
+}
+
+// checkPartsOverlap rejects parcels of a subdivision or merge that overlap each other or a
+// registered parcel other than the ones they replace.
+func (handler *Handler) checkPartsOverlap(parts []PropertyPartDto, replacedIds []string) error {
+	for i, part := range parts {
+		if part.Boundary == nil {
+			continue
+		}
+		for _, other := range parts[:i] {
+			if other.Boundary != nil && BoundariesOverlap(part.Boundary, other.Boundary) {
+				return fmt.Errorf("parcels %s and %s overlap", other.Id, part.Id)
+			}
+		}
+		if err := handler.checkBoundaryOverlap(part.Boundary, replacedIds...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (handler *Handler) SearchProperties(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter bson.M
+	switch {
+	case query.Get("within") != "":
+		box, err := ParseFloats(query.Get("within"), 4)
+		if err != nil {
+			CreateResponse(w, fmt.Errorf("within should be minLng,minLat,maxLng,maxLat: %v", err), nil, http.StatusBadRequest)
+			return
+		}
+		area := GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+			{box[0], box[1]}, {box[2], box[1]}, {box[2], box[3]}, {box[0], box[3]}, {box[0], box[1]},
+		}}}
+		filter = bson.M{"boundary": bson.M{"$geoWithin": bson.M{"$geometry": area}}}
+	case query.Get("near") != "":
+		point, err := ParseFloats(query.Get("near"), 2)
+		if err != nil {
+			CreateResponse(w, fmt.Errorf("near should be lng,lat: %v", err), nil, http.StatusBadRequest)
+			return
+		}
+		near := bson.M{"$geometry": bson.M{"type": "Point", "coordinates": point}}
+		if maxDistance := query.Get("maxDistance"); maxDistance != "" {
+			meters, err := strconv.ParseFloat(maxDistance, 64)
+			if err != nil {
+				CreateResponse(w, errors.New("maxDistance should be a number of meters"), nil, http.StatusBadRequest)
+				return
+			}
+			near["$maxDistance"] = meters
+		}
+		filter = bson.M{"boundary": bson.M{"$near": near}}
+	default:
+		CreateResponse(w, errors.New("either near or within is required"), nil, http.StatusBadRequest)
+		return
+	}
+	cursor, err := handler.PropertyCollection.Find(context.Background(), filter)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	var properties []Property
+	if err := cursor.All(context.Background(), &properties); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, properties, http.StatusOK)
+}
+
+// propertySearchParams maps the query parameters of QueryProperties to the chaincode's
+// whitelisted query fields and operators.
+var propertySearchParams = []struct {
+	param string
+	field string
+	op    string
+}{
+	{"location", "location", "eq"},
+	{"title", "title", "eq"},
+	{"owner", "ownerEmail", "eq"},
+	{"listed", "isListed", "eq"},
+	{"minPrice", "price", "gte"},
+	{"maxPrice", "price", "lte"},
+	{"currency", "currency", "eq"},
+	{"minSize", "size", "gte"},
+	{"maxSize", "size", "lte"},
+}
+
+// QueryProperties answers ad-hoc property searches such as listed properties in a location under
+// a price with a CouchDB rich query, one page at a time. Pass the returned bookmark to get the
+// next page.
+func (handler *Handler) QueryProperties(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	conditions := []QueryConditionDto{}
+	for _, param := range propertySearchParams {
+		if value := query.Get(param.param); value != "" {
+			conditions = append(conditions, QueryConditionDto{Field: param.field, Op: param.op, Value: value})
+		}
+	}
+	pageSize := "0"
+	if size := query.Get("pageSize"); size != "" {
+		if _, err := strconv.ParseInt(size, 10, 32); err != nil {
+			CreateResponse(w, errors.New("pageSize should be a number"), nil, http.StatusBadRequest)
+			return
+		}
+		pageSize = size
+	}
+	selector, err := json.Marshal(conditions)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.Contract.EvaluateTransaction("property:Query", string(selector), pageSize, query.Get("bookmark"))
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var page PropertyPageDto
+	if err := json.Unmarshal(data, &page); err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode property data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, page, http.StatusOK)
+}
+
+func (handler *Handler) SubdivideProperty(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request SubdivideRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	var parent Property
+	if err := handler.PropertyCollection.FindOne(context.Background(), bson.M{"_id": request.PropertyId}).Decode(&parent); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("property not found"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if !IsOwner(parent, claims.Email) {
+		CreateResponse(w, errors.New("only owners can subdivide the property"), nil, http.StatusForbidden)
+		return
+	}
+	for i := range request.Children {
+		if err := PreparePropertyPart(&request.Children[i], true); err != nil {
+			CreateResponse(w, err, nil, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := handler.checkPartsOverlap(request.Children, []string{request.PropertyId}); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	children, err := json.Marshal(request.Children)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.Contract.SubmitTransaction("property:Subdivide", request.PropertyId, string(children), claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if string(data) == consentPending {
+		CreateResponse(w, nil, map[string]interface{}{"status": consentPending, "request": request}, http.StatusOK)
+		return
+	}
+	if err := handler.saveParts(parent, request.Children, request.PropertyId); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, request.Children, http.StatusOK)
+}
+
+func (handler *Handler) MergeProperties(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	var request MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if len(request.PropertyIds) < 2 {
+		CreateResponse(w, errors.New("at least two property_ids are required"), nil, http.StatusBadRequest)
+		return
+	}
+	var first Property
+	if err := handler.PropertyCollection.FindOne(context.Background(), bson.M{"_id": request.PropertyIds[0]}).Decode(&first); err != nil {
+		if err == mongo.ErrNoDocuments {
+			CreateResponse(w, errors.New("property not found"), nil, http.StatusNotFound)
+			return
+		}
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if !IsOwner(first, claims.Email) {
+		CreateResponse(w, errors.New("only owners can merge the properties"), nil, http.StatusForbidden)
+		return
+	}
+	if err := PreparePropertyPart(&request.Merged, false); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	if err := handler.checkPartsOverlap([]PropertyPartDto{request.Merged}, request.PropertyIds); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	propertyIds, err := json.Marshal(request.PropertyIds)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	merged, err := json.Marshal(request.Merged)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	data, err := handler.Contract.SubmitTransaction("property:Merge", string(propertyIds), string(merged), claims.Email)
+	if err != nil {
+		log.Println("error in chaincode")
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if string(data) == consentPending {
+		CreateResponse(w, nil, map[string]interface{}{"status": consentPending, "request": request}, http.StatusOK)
+		return
+	}
+	// The chaincode sums the parents' sizes for the merged parcel.
+	detail, err := handler.Contract.EvaluateTransaction("property:Get", request.Merged.Id)
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	var mergedProperty PropertyDetailDto
+	if err := json.Unmarshal(detail, &mergedProperty); err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode property data: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	request.Merged.Size = mergedProperty.Property.Size
+	if err := handler.saveParts(first, []PropertyPartDto{request.Merged}, request.PropertyIds...); err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, request.Merged, http.StatusOK)
+}
+
+// saveParts adds the parcels created by a subdivision or merge to the read model with the
+// owners of template, and marks the parcels they replaced as retired.
+func (handler *Handler) saveParts(template Property, parts []PropertyPartDto, retiredIds ...string) error {
+	owners := template.Owners
+	if len(owners) == 0 {
+		owners = []Owner{{Email: template.OwnerEmail, Share: 100}}
+	}
+	for _, part := range parts {
+		property := Property{
+			Id:           part.Id,
+			Title:        part.Title,
+			Location:     part.Location,
+			Size:         part.Size,
+			OwnerEmail:   template.OwnerEmail,
+			Price:        part.Price,
+			Currency:     part.Currency,
+			Owners:       owners,
+			Boundary:     part.Boundary,
+			RegisteredAt: time.Now().UTC(),
+		}
+		if _, err := handler.PropertyCollection.InsertOne(context.Background(), property); err != nil {
+			return err
+		}
+	}
+	filter := bson.M{"_id": bson.M{"$in": retiredIds}}
+	update := bson.M{"$set": bson.M{"retired": true, "is_listed": false}}
+	_, err := handler.PropertyCollection.UpdateMany(context.Background(), filter, update)
+	return err
 }
@@ -13,6 +13,19 @@ type UserDto struct {
 	Address  string `json:"address"`
 	Contact  string `json:"contact"`
 	Password string `json:"password,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+type RoleRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// UserPrivateDto is passed to the chaincode through the transient map and never written to the public ledger.
+type UserPrivateDto struct {
+	Address  string `json:"address"`
+	Contact  string `json:"contact"`
+	Password string `json:"password"`
 }
 
 type User struct {
@@ -22,34 +35,504 @@ type User struct {
 	Address  string `json:"address" bson:"address,omitempty"`
 	Contact  string `json:"contact" bson:"contact,omitempty"`
 	Password string `json:"password" bson:"password,omitempty"`
+	Role     string `json:"role" bson:"role,omitempty"`
 }
 type PropertyDto struct {
-	Id         string  `json:"id"`
-	Title      string  `json:"title"`
-	Location   string  `json:"location"`
-	Size       float64 `json:"size"`
-	OwnerEmail string  `json:"current_owner_email"`
-	Price      float64 `json:"price"`
-	IsListed   bool    `json:"is_listed"`
+	Id               string      `json:"id"`
+	Title            string      `json:"title"`
+	Location         string      `json:"location"`
+	Size             float64     `json:"size"`
+	OwnerEmail       string      `json:"current_owner_email"`
+	Price            int64       `json:"price"`
+	Currency         string      `json:"currency"`
+	IsListed         bool        `json:"is_listed"`
+	Owners           []OwnerDto  `json:"owners"`
+	ConsentThreshold float64     `json:"consent_threshold"`
+	Boundary         *GeoPolygon `json:"boundary,omitempty"`
+	BoundaryHash     string      `json:"boundary_hash"`
+	Retired          bool        `json:"retired"`
+	Frozen           bool        `json:"frozen"`
+	ListingStatus    string      `json:"listing_status"`
+	ListedFrom       string      `json:"listed_from"`
+	ListedUntil      string      `json:"listed_until"`
+	Valuation        int64       `json:"valuation"`
+	PriceDeviation   float64     `json:"price_deviation"`
+	PriceFlagged     bool        `json:"price_flagged"`
 }
 type Property struct {
-    Id         string  `bson:"_id,omitempty" json:"property_id"`       
-    Title      string  `bson:"title,omitempty" json:"title"`        
-    Location   string  `bson:"location,omitempty" json:"location"`    
-    Size       float64 `bson:"size,omitempty" json:"size"`            
-    OwnerEmail string  `bson:"owner_email,omitempty" json:"current_owner_email"` 
-    Price      float64 `bson:"price,omitempty" json:"price"`          
-    IsListed   bool    `bson:"is_listed,omitempty" json:"is_listed"`  
+	Id           string      `bson:"_id,omitempty" json:"property_id"`
+	Title        string      `bson:"title,omitempty" json:"title"`
+	Location     string      `bson:"location,omitempty" json:"location"`
+	Size         float64     `bson:"size,omitempty" json:"size"`
+	OwnerEmail   string      `bson:"owner_email,omitempty" json:"current_owner_email"`
+	Price        int64       `bson:"price,omitempty" json:"price"`
+	Currency     string      `bson:"currency,omitempty" json:"currency"`
+	IsListed     bool        `bson:"is_listed,omitempty" json:"is_listed"`
+	Owners       []Owner     `bson:"owners,omitempty" json:"owners"`
+	Boundary     *GeoPolygon `bson:"boundary,omitempty" json:"boundary,omitempty"`
+	Retired      bool        `bson:"retired,omitempty" json:"retired"`
+	Frozen       bool        `bson:"frozen,omitempty" json:"frozen"`
+	RegisteredAt time.Time   `bson:"registered_at,omitempty" json:"registered_at"`
+}
+
+// Transaction is the read model of a ledger transaction, kept in Mongo for analytics.
+type Transaction struct {
+	Id          string    `bson:"_id" json:"id"`
+	Type        string    `bson:"type" json:"type"`
+	PropertyId  string    `bson:"property_id" json:"property_id"`
+	Location    string    `bson:"location" json:"location"`
+	BuyerEmail  string    `bson:"buyer_email" json:"buyer_email"`
+	SellerEmail string    `bson:"seller_email" json:"seller_email"`
+	Amount      int64     `bson:"amount" json:"amount"`
+	Currency    string    `bson:"currency" json:"currency"`
+	Date        time.Time `bson:"date" json:"date"`
+	Status      string    `bson:"status" json:"status"`
+}
+
+type PropertyPartDto struct {
+	Id           string      `json:"id"`
+	Title        string      `json:"title"`
+	Location     string      `json:"location"`
+	Size         float64     `json:"size"`
+	Price        int64       `json:"price"`
+	Currency     string      `json:"currency"`
+	Boundary     *GeoPolygon `json:"boundary,omitempty"`
+	BoundaryHash string      `json:"boundary_hash"`
+}
+
+type SubdivideRequest struct {
+	PropertyId string            `json:"property_id"`
+	Children   []PropertyPartDto `json:"children"`
+}
+
+type MergeRequest struct {
+	PropertyIds []string        `json:"property_ids"`
+	Merged      PropertyPartDto `json:"merged"`
+}
+
+type LineageLinkDto struct {
+	Relation   string `json:"relation"`
+	PropertyId string `json:"property_id"`
+	Event      string `json:"event"`
+	Date       string `json:"date"`
+}
+
+type OwnerDto struct {
+	Email string  `json:"email"`
+	Share float64 `json:"share"`
+}
+
+type Owner struct {
+	Email string  `bson:"email" json:"email"`
+	Share float64 `bson:"share" json:"share"`
+}
+
+type ShareTransferRequest struct {
+	PropertyId string  `json:"property_id"`
+	ToEmail    string  `json:"to_email"`
+	Share      float64 `json:"share"`
 }
 
 type TransactionDto struct {
-	Id          string  `json:"id"`
-	PropertyId  string  `json:"property_id"`
-	BuyerEmail  string  `json:"buyer_email"`
-	SellerEmail string  `json:"seller_email"`
-	Amount      float64 `json:"amount"`
-	Date        string  `json:"date"`
-	Status      string  `json:"status"`
+	Id            string       `json:"id"`
+	Type          string       `json:"type"`
+	PropertyId    string       `json:"property_id"`
+	BuyerEmail    string       `json:"buyer_email"`
+	SellerEmail   string       `json:"seller_email"`
+	Amount        int64        `json:"amount"`
+	Currency      string       `json:"currency"`
+	Date          string       `json:"date"`
+	Status        string       `json:"status"`
+	Jurisdiction  string       `json:"jurisdiction"`
+	Tax           []TaxLineDto `json:"tax"`
+	TotalTax      int64        `json:"total_tax"`
+	ReversedBy    string       `json:"reversed_by"`
+	Reverses      string       `json:"reverses"`
+	Justification string       `json:"justification"`
+	ApprovedBy    string       `json:"approved_by"`
+	Heirs         []OwnerDto   `json:"heirs"`
+	ProbateHash   string       `json:"probate_hash"`
+	AgentEmail    string       `json:"agent_email"`
+}
+
+type SuccessionRequest struct {
+	PropertyId    string     `json:"property_id"`
+	DeceasedEmail string     `json:"deceased_email"`
+	Heirs         []OwnerDto `json:"heirs"`
+	ProbateHash   string     `json:"probate_hash"`
+}
+
+type ReversalRequest struct {
+	TransactionId string `json:"transaction_id"`
+	Justification string `json:"justification"`
+}
+
+type ReversalDto struct {
+	TransactionId  string `json:"transaction_id"`
+	CompensatingId string `json:"compensating_id"`
+	PropertyId     string `json:"property_id"`
+	RestoredOwner  string `json:"restored_owner"`
+	PreviousOwner  string `json:"previous_owner"`
+	RegistrarEmail string `json:"registrar_email"`
+	Justification  string `json:"justification"`
+	Date           string `json:"date"`
+}
+
+// TaxBracketDto charges Rate percent between Min and Max, in minor units of Currency.
+type TaxBracketDto struct {
+	Min      int64   `json:"min"`
+	Max      int64   `json:"max"`
+	Rate     float64 `json:"rate"`
+	Currency string  `json:"currency"`
+}
+
+type TaxLineDto struct {
+	Min     int64   `json:"min"`
+	Max     int64   `json:"max"`
+	Rate    float64 `json:"rate"`
+	Taxable int64   `json:"taxable"`
+	Duty    int64   `json:"duty"`
+}
+
+// TaxScheduleRequest replaces a jurisdiction's schedule in one currency.
+type TaxScheduleRequest struct {
+	Jurisdiction string          `json:"jurisdiction"`
+	Currency     string          `json:"currency"`
+	Brackets     []TaxBracketDto `json:"brackets"`
+}
+
+type TaxReportEntryDto struct {
+	Period       string `json:"period"`
+	Jurisdiction string `json:"jurisdiction"`
+	Currency     string `json:"currency"`
+	Transactions int    `json:"transactions"`
+	SalesVolume  int64  `json:"sales_volume"`
+	TotalDuty    int64  `json:"total_duty"`
+}
+
+// SalePrivateDto carries a negotiated sale amount, in minor units of the listing's currency,
+// through the transient map.
+type SalePrivateDto struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency,omitempty"`
+}
+
+type LienDto struct {
+	Id          string `json:"id"`
+	PropertyId  string `json:"property_id"`
+	LenderEmail string `json:"lender_email"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Priority    int    `json:"priority"`
+	Status      string `json:"status"`
+}
+
+type DelegationDto struct {
+	Id             string   `json:"id"`
+	PrincipalEmail string   `json:"principal_email"`
+	AgentEmail     string   `json:"agent_email"`
+	Scope          []string `json:"scope"`
+	PropertyId     string   `json:"property_id"`
+	Expiry         string   `json:"expiry"`
+	Status         string   `json:"status"`
+}
+
+// DelegationRequest grants a power of attorney. An empty property_id covers all of the
+// principal's properties.
+type DelegationRequest struct {
+	AgentEmail string   `json:"agent_email"`
+	Scope      []string `json:"scope"`
+	PropertyId string   `json:"property_id"`
+	Expiry     string   `json:"expiry"`
+}
+
+type AgentActionDto struct {
+	PropertyId     string `json:"property_id"`
+	TransactionId  string `json:"transaction_id"`
+	AgentEmail     string `json:"agent_email"`
+	PrincipalEmail string `json:"principal_email"`
+	Action         string `json:"action"`
+	Result         string `json:"result"`
+	Date           string `json:"date"`
+}
+
+type ListingRequest struct {
+	PropertyId string `json:"property_id"`
+	StartsAt   string `json:"starts_at"`
+	EndsAt     string `json:"ends_at"`
+}
+
+type RepriceRequest struct {
+	PropertyId string `json:"property_id"`
+	Price      int64  `json:"price"`
+}
+
+// CurrencyDto is a currency the chaincode accepts and the number of digits of its minor unit.
+type CurrencyDto struct {
+	Code    string `json:"code"`
+	Digits  int    `json:"digits"`
+	Default bool   `json:"default"`
+}
+
+type PriceRecordDto struct {
+	PropertyId    string `json:"property_id"`
+	Date          string `json:"date"`
+	TransactionId string `json:"transaction_id"`
+	Event         string `json:"event"`
+	Price         int64  `json:"price"`
+	Currency      string `json:"currency"`
+}
+
+type MarketTrendDto struct {
+	Month       string  `json:"month"`
+	Currency    string  `json:"currency"`
+	Sales       int     `json:"sales"`
+	MedianPrice float64 `json:"median_price"`
+	MeanPrice   float64 `json:"mean_price"`
+}
+
+type ValuationDto struct {
+	Id             string `json:"id"`
+	PropertyId     string `json:"property_id"`
+	AppraiserEmail string `json:"appraiser_email"`
+	Value          int64  `json:"value"`
+	Currency       string `json:"currency"`
+	Method         string `json:"method"`
+	Date           string `json:"date"`
+	ReportHash     string `json:"report_hash"`
+}
+
+// ValuationRequest values a property in minor units of its currency, which is the default when
+// Currency is empty.
+type ValuationRequest struct {
+	PropertyId string `json:"property_id"`
+	Value      int64  `json:"value"`
+	Currency   string `json:"currency"`
+	Method     string `json:"method"`
+	ReportHash string `json:"report_hash"`
+}
+
+type ValuationHistoryDto struct {
+	Latest  *ValuationDto  `json:"latest"`
+	History []ValuationDto `json:"history"`
+}
+
+// ValuationBandRequest sets the allowed deviation, in percent, of an asking price from the
+// latest valuation. A band of 0 turns the check off.
+type ValuationBandRequest struct {
+	Band float64 `json:"band"`
+}
+
+type PriceCheckDto struct {
+	Price     int64   `json:"price"`
+	Currency  string  `json:"currency"`
+	Valuation int64   `json:"valuation"`
+	Deviation float64 `json:"deviation"`
+	Flagged   bool    `json:"flagged"`
+}
+
+type ListingResultDto struct {
+	Message    string         `json:"message"`
+	PriceCheck *PriceCheckDto `json:"price_check,omitempty"`
+}
+
+// QueryConditionDto is one clause of a chaincode rich query; see QueryProperties.
+type QueryConditionDto struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+type PropertyPageDto struct {
+	Properties []PropertyDto `json:"properties"`
+	Bookmark   string        `json:"bookmark"`
+	Count      int32         `json:"count"`
+}
+
+type AuditEntryDto struct {
+	TransactionId string `json:"transaction_id"`
+	Timestamp     string `json:"timestamp"`
+	MSPId         string `json:"msp_id"`
+	Subject       string `json:"subject"`
+	Function      string `json:"function"`
+	ArgsDigest    string `json:"args_digest"`
+}
+
+type AuditPageDto struct {
+	Entries  []AuditEntryDto `json:"entries"`
+	Bookmark string          `json:"bookmark"`
+	Count    int32           `json:"count"`
+}
+
+type FreezeDto struct {
+	Id             string `json:"id"`
+	PropertyId     string `json:"property_id"`
+	AuthorityEmail string `json:"authority_email"`
+	AuthorityRole  string `json:"authority_role"`
+	Reason         string `json:"reason"`
+	Reference      string `json:"reference"`
+	FrozenAt       string `json:"frozen_at"`
+	Status         string `json:"status"`
+	ReleasedBy     string `json:"released_by"`
+	ReleasedAt     string `json:"released_at"`
+	ReleaseReason  string `json:"release_reason"`
+}
+
+type FreezeRequest struct {
+	PropertyId string `json:"property_id"`
+	FreezeId   string `json:"freeze_id"`
+	Reason     string `json:"reason"`
+	Reference  string `json:"reference"`
+}
+
+type LeaseDto struct {
+	Id             string `json:"id"`
+	PropertyId     string `json:"property_id"`
+	LandlordEmail  string `json:"landlord_email"`
+	TenantEmail    string `json:"tenant_email"`
+	StartDate      string `json:"start_date"`
+	EndDate        string `json:"end_date"`
+	Rent           int64  `json:"rent"`
+	Deposit        int64  `json:"deposit"`
+	Currency       string `json:"currency"`
+	LandlordSigned bool   `json:"landlord_signed"`
+	TenantSigned   bool   `json:"tenant_signed"`
+	Status         string `json:"status"`
+}
+
+// LeaseRenewalRequest renews a lease at a rent in minor units of the lease's currency.
+type LeaseRenewalRequest struct {
+	PropertyId string `json:"property_id"`
+	LeaseId    string `json:"lease_id"`
+	EndDate    string `json:"end_date"`
+	Rent       int64  `json:"rent"`
+}
+
+// AuctionDto is an auction held in the currency of the property's price. The winning bid and
+// revealed bids are minor units of it.
+type AuctionDto struct {
+	Id          string `json:"id"`
+	PropertyId  string `json:"property_id"`
+	SellerEmail string `json:"seller_email"`
+	Deadline    string `json:"deadline"`
+	Status      string `json:"status"`
+	WinnerEmail string `json:"winner_email"`
+	WinningBid  int64  `json:"winning_bid"`
+	Currency    string `json:"currency"`
+}
+
+type BidDto struct {
+	AuctionId   string `json:"auction_id"`
+	BidderEmail string `json:"bidder_email"`
+	Hash        string `json:"hash"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Status      string `json:"status"`
+}
+
+type AuctionResultDto struct {
+	Auction       AuctionDto `json:"auction"`
+	Bids          []BidDto   `json:"bids"`
+	TransactionId string     `json:"transaction_id"`
+}
+
+type CreateAuctionRequest struct {
+	PropertyId string `json:"property_id"`
+	Deadline   string `json:"deadline"`
+}
+
+// BidRequest seals or reveals a bid in minor units of the auction's currency.
+type BidRequest struct {
+	AuctionId string `json:"auction_id"`
+	Amount    int64  `json:"amount"`
+	Salt      string `json:"salt"`
+}
+
+type DocumentDto struct {
+	Id            string `json:"id"`
+	PropertyId    string `json:"property_id"`
+	Name          string `json:"name"`
+	Hash          string `json:"hash"`
+	MediaType     string `json:"media_type"`
+	UploaderEmail string `json:"uploader_email"`
+	UploadedAt    string `json:"uploaded_at"`
+}
+
+type DocumentVerification struct {
+	DocumentId string `json:"document_id"`
+	LedgerHash string `json:"ledger_hash"`
+	StoredHash string `json:"stored_hash"`
+	Valid      bool   `json:"valid"`
+}
+
+type PropertyDetailDto struct {
+	Property  PropertyDto   `json:"property"`
+	Liens     []LienDto     `json:"liens"`
+	Leases    []LeaseDto    `json:"leases"`
+	Documents []DocumentDto `json:"documents"`
+}
+
+type PropertyHistoryDto struct {
+	PropertyId   string           `json:"property_id"`
+	Transactions []TransactionDto `json:"transactions"`
+	Liens        []LienDto        `json:"liens"`
+	Lineage      []LineageLinkDto `json:"lineage"`
+	Freezes      []FreezeDto      `json:"freezes"`
+	AgentActions []AgentActionDto `json:"agent_actions"`
+}
+
+type AmountMigrationDto struct {
+	Properties   int64 `json:"properties"`
+	Transactions int64 `json:"transactions"`
+}
+
+type MigrationProgressDto struct {
+	Asset    string `json:"asset"`
+	Version  int    `json:"version"`
+	Scanned  int    `json:"scanned"`
+	Migrated int    `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+	Done     bool   `json:"done"`
+}
+
+type RegistrySummaryDto struct {
+	Users       int `json:"users"`
+	Properties  int `json:"properties"`
+	Listed      int `json:"listed"`
+	Unlisted    int `json:"unlisted"`
+	Retired     int `json:"retired"`
+	Sales       int `json:"sales"`
+	Reversals   int `json:"reversals"`
+	Successions int `json:"successions"`
+}
+
+type LocationStatsDto struct {
+	Location     string  `json:"location"`
+	Properties   int     `json:"properties"`
+	Listed       int     `json:"listed"`
+	AveragePrice float64 `json:"average_price"`
+	Sales        int     `json:"sales"`
+	SalesVolume  float64 `json:"sales_volume"`
+}
+
+type PeriodStatsDto struct {
+	Period           string  `json:"period"`
+	Registrations    int     `json:"registrations"`
+	Sales            int     `json:"sales"`
+	SalesVolume      float64 `json:"sales_volume"`
+	AverageSalePrice float64 `json:"average_sale_price"`
+}
+
+// StatsDto combines the analytics computed from the read model with the ledger's own counters,
+// which stay authoritative when the two disagree.
+type StatsDto struct {
+	Interval  string              `json:"interval"`
+	Currency  string              `json:"currency"`
+	Listed    int                 `json:"listed"`
+	Unlisted  int                 `json:"unlisted"`
+	Locations []LocationStatsDto  `json:"locations"`
+	Periods   []PeriodStatsDto    `json:"periods"`
+	Ledger    *RegistrySummaryDto `json:"ledger"`
 }
 
 type Response struct {
@@ -64,10 +547,41 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+const (
+	RoleUser      = "user"
+	RoleLender    = "lender"
+	RoleAdmin     = "admin"
+	RoleRegistrar = "registrar"
+	RoleCourt     = "court"
+	RoleAppraiser = "appraiser"
+	RoleAuditor   = "auditor"
+)
+
+// SigningRoles are the roles that privileged chaincode functions check in the role attribute of
+// the client certificate, so the server signs their requests with an identity enrolled for them.
+var SigningRoles = []string{RoleAdmin, RoleRegistrar, RoleCourt, RoleAppraiser, RoleAuditor, RoleLender}
+
+// Actions an owner can delegate, and the property id of a delegation covering every property.
+const (
+	delegateList  = "list"
+	delegateSell  = "sell"
+	allProperties = "*"
+)
+
+// Results of ScheduleListing besides consentPending.
+const (
+	listingScheduled = "Scheduled"
+	listingLive      = "Live"
+)
+
+// consentPending is returned by the chaincode when an action still needs co-owner consent.
+const consentPending = "PendingConsent"
+
 type Claims struct {
 	UserId   string
 	Name     string
 	Email    string
 	Password string
+	Role     string
 	jwt.RegisteredClaims
 }
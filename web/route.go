@@ -5,16 +5,25 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 	"github.com/joho/godotenv"
 	"github.com/justinas/alice"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func Routers(contract *client.Contract) {
+// Routers serves the API. Requests are signed as network's identity, except those of callers
+// whose role has a network in roleNetworks, which are signed as that role's identity.
+func Routers(network *client.Network, roleNetworks map[string]*client.Network, chaincodeName string) {
+	contract := network.GetContract(chaincodeName)
+	roleContracts := make(map[string]*client.Contract)
+	for role, roleNetwork := range roleNetworks {
+		roleContracts[role] = roleNetwork.GetContract(chaincodeName)
+	}
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatalf("Err loading .env file")
@@ -35,22 +44,94 @@ func Routers(contract *client.Contract) {
 	db := client.Database("Chaincode")
 	userCollection := db.Collection(os.Getenv("USER_COLLECTION"))
 	propertyCollection := db.Collection(os.Getenv("PROPERTY_COLLECTION"))
+	boundaryIndex := mongo.IndexModel{Keys: bson.D{{Key: "boundary", Value: "2dsphere"}}}
+	if _, err := propertyCollection.Indexes().CreateOne(context.Background(), boundaryIndex); err != nil {
+		log.Fatal("Could not create boundary index:", err)
+	}
+	transactionCollection := db.Collection(os.Getenv("TRANSACTION_COLLECTION"))
+	transactionIndex := mongo.IndexModel{Keys: bson.D{{Key: "location", Value: 1}, {Key: "date", Value: 1}}}
+	if _, err := transactionCollection.Indexes().CreateOne(context.Background(), transactionIndex); err != nil {
+		log.Fatal("Could not create transaction index:", err)
+	}
 	log.Println("Database Connected ")
 	log.Println("Starting server...")
 	router := mux.NewRouter()
 	log.Println("Setting up routes")
-	handler := &Handler{Contract: contract, UserCollection: userCollection, PropertyCollection: propertyCollection, JwtKey: os.Getenv("JWT_KEY")}
+	blobStorePath := os.Getenv("BLOB_STORE_PATH")
+	if len(blobStorePath) == 0 {
+		blobStorePath = "documents"
+	}
+	blobStore, err := NewLocalBlobStore(blobStorePath)
+	if err != nil {
+		log.Fatal("Could not open document store:", err)
+	}
+	handler := &Handler{Contract: contract, RoleContracts: roleContracts, UserCollection: userCollection, PropertyCollection: propertyCollection, TransactionCollection: transactionCollection, JwtKey: os.Getenv("JWT_KEY"), BlobStore: blobStore}
+	go handler.ListenForEvents(context.Background(), network, chaincodeName)
+	schedulerInterval := time.Minute
+	if interval := os.Getenv("LISTING_SCHEDULER_INTERVAL"); interval != "" {
+		schedulerInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			log.Fatal("Invalid LISTING_SCHEDULER_INTERVAL:", err)
+		}
+	}
+	go handler.RunListingScheduler(context.Background(), schedulerInterval)
 	apipath := "/api/v2"
 	router.HandleFunc(apipath+"/createUser", handler.RegisterUser).Methods("POST")
 	router.HandleFunc(apipath+"/login", handler.Login).Methods("POST")
 	// chain
 	chain := alice.New(handler.jwtMiddleware)
 	router.Handle(apipath+"/getUsers", chain.ThenFunc(handler.GetAllUsers)).Methods("GET")
+	router.Handle(apipath+"/grantRole", chain.ThenFunc(handler.GrantRole)).Methods("PUT")
 	router.Handle(apipath+"/registerProperty", chain.ThenFunc(handler.RegisterProperty)).Methods("POST")
 	router.Handle(apipath+"/getProperties", chain.ThenFunc(handler.GetAllProperty)).Methods("GET")
 	router.Handle(apipath+"/sellProperty", chain.ThenFunc(handler.BuyProperty)).Methods("GET")
 	router.Handle(apipath+"/getTransactions", chain.ThenFunc(handler.GetAllTransaction)).Methods("GET")
 	router.Handle(apipath+"/updateProperty", chain.ThenFunc(handler.UpdateFlag)).Methods("PUT")
+	router.Handle(apipath+"/repriceProperty", chain.ThenFunc(handler.RepriceProperty)).Methods("PUT")
+	router.Handle(apipath+"/scheduleListing", chain.ThenFunc(handler.ScheduleListing)).Methods("POST")
+	router.Handle(apipath+"/submitValuation", chain.ThenFunc(handler.SubmitValuation)).Methods("POST")
+	router.Handle(apipath+"/getValuations", chain.ThenFunc(handler.GetValuations)).Methods("GET")
+	router.Handle(apipath+"/setValuationBand", chain.ThenFunc(handler.SetValuationBand)).Methods("PUT")
+	router.Handle(apipath+"/rebuildIndexes", chain.ThenFunc(handler.RebuildIndexes)).Methods("PUT")
+	router.Handle(apipath+"/runMigration", chain.ThenFunc(handler.RunMigration)).Methods("PUT")
+	router.Handle(apipath+"/migrateAmounts", chain.ThenFunc(handler.MigrateAmounts)).Methods("PUT")
+	router.Handle(apipath+"/getAuditLog", chain.ThenFunc(handler.GetAuditLog)).Methods("GET")
+	router.Handle(apipath+"/stats", chain.ThenFunc(handler.GetStats)).Methods("GET")
+	router.Handle(apipath+"/getProperty", chain.ThenFunc(handler.GetProperty)).Methods("GET")
+	router.Handle(apipath+"/getPropertyHistory", chain.ThenFunc(handler.GetPropertyHistory)).Methods("GET")
+	router.Handle(apipath+"/registerLien", chain.ThenFunc(handler.RegisterLien)).Methods("POST")
+	router.Handle(apipath+"/releaseLien", chain.ThenFunc(handler.ReleaseLien)).Methods("PUT")
+	router.Handle(apipath+"/transferShare", chain.ThenFunc(handler.TransferShare)).Methods("POST")
+	router.Handle(apipath+"/setConsentThreshold", chain.ThenFunc(handler.SetConsentThreshold)).Methods("PUT")
+	router.Handle(apipath+"/freezeProperty", chain.ThenFunc(handler.FreezeProperty)).Methods("POST")
+	router.Handle(apipath+"/unfreezeProperty", chain.ThenFunc(handler.UnfreezeProperty)).Methods("PUT")
+	router.Handle(apipath+"/getFreezes", chain.ThenFunc(handler.GetFreezes)).Methods("GET")
+	router.Handle(apipath+"/reverseTransaction", chain.ThenFunc(handler.ReverseTransaction)).Methods("POST")
+	router.Handle(apipath+"/grantDelegation", chain.ThenFunc(handler.GrantDelegation)).Methods("POST")
+	router.Handle(apipath+"/revokeDelegation", chain.ThenFunc(handler.RevokeDelegation)).Methods("PUT")
+	router.Handle(apipath+"/getDelegations", chain.ThenFunc(handler.GetDelegations)).Methods("GET")
+	router.Handle(apipath+"/transferBySuccession", chain.ThenFunc(handler.TransferBySuccession)).Methods("POST")
+	router.Handle(apipath+"/createLease", chain.ThenFunc(handler.CreateLease)).Methods("POST")
+	router.Handle(apipath+"/signLease", chain.ThenFunc(handler.SignLease)).Methods("PUT")
+	router.Handle(apipath+"/renewLease", chain.ThenFunc(handler.RenewLease)).Methods("PUT")
+	router.Handle(apipath+"/terminateLease", chain.ThenFunc(handler.TerminateLease)).Methods("PUT")
+	router.Handle(apipath+"/getLeases", chain.ThenFunc(handler.GetLeases)).Methods("GET")
+	router.Handle(apipath+"/createAuction", chain.ThenFunc(handler.CreateAuction)).Methods("POST")
+	router.Handle(apipath+"/submitBid", chain.ThenFunc(handler.SubmitBid)).Methods("POST")
+	router.Handle(apipath+"/revealBid", chain.ThenFunc(handler.RevealBid)).Methods("PUT")
+	router.Handle(apipath+"/closeAuction", chain.ThenFunc(handler.CloseAuction)).Methods("PUT")
+	router.Handle(apipath+"/getAuction", chain.ThenFunc(handler.GetAuction)).Methods("GET")
+	router.Handle(apipath+"/setTaxSchedule", chain.ThenFunc(handler.SetTaxSchedule)).Methods("PUT")
+	router.Handle(apipath+"/getTaxSchedule", chain.ThenFunc(handler.GetTaxSchedule)).Methods("GET")
+	router.Handle(apipath+"/getTaxReport", chain.ThenFunc(handler.GetTaxReport)).Methods("GET")
+	router.Handle(apipath+"/subdivideProperty", chain.ThenFunc(handler.SubdivideProperty)).Methods("POST")
+	router.Handle(apipath+"/mergeProperties", chain.ThenFunc(handler.MergeProperties)).Methods("POST")
+	router.Handle(apipath+"/searchProperties", chain.ThenFunc(handler.SearchProperties)).Methods("GET")
+	router.Handle(apipath+"/properties/search", chain.ThenFunc(handler.QueryProperties)).Methods("GET")
+	router.Handle(apipath+"/properties/{id}/prices", chain.ThenFunc(handler.GetPriceHistory)).Methods("GET")
+	router.Handle(apipath+"/market/trends", chain.ThenFunc(handler.GetMarketTrends)).Methods("GET")
+	router.Handle(apipath+"/properties/{id}/documents", chain.ThenFunc(handler.UploadDocument)).Methods("POST")
+	router.Handle(apipath+"/properties/{id}/documents/{documentId}/verify", chain.ThenFunc(handler.VerifyDocument)).Methods("GET")
 	log.Println("Listening in port 8080")
 	http.ListenAndServe("localhost:8080", router)
 }
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RunListingScheduler periodically starts listings whose scheduled time has come and expires
+// overdue ones. The chaincode decides what is due from transaction timestamps, so running the
+// scheduler on several servers at once is harmless.
+func (handler *Handler) RunListingScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			handler.runListingJob("property:StartScheduledListings", true)
+			handler.runListingJob("property:ExpireListings", false)
+		}
+	}
+}
+
+// runListingJob submits one listing transaction and mirrors the properties it changed into Mongo.
+func (handler *Handler) runListingJob(function string, isListed bool) {
+	data, err := handler.Contract.SubmitTransaction(function)
+	if err != nil {
+		log.Println("error in chaincode:", function, err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	var propertyIds []string
+	if err := json.Unmarshal(data, &propertyIds); err != nil {
+		log.Println("failed to decode", function, "result:", err)
+		return
+	}
+	if len(propertyIds) == 0 {
+		return
+	}
+	filter := bson.M{"_id": bson.M{"$in": propertyIds}}
+	update := bson.M{"$set": bson.M{"is_listed": isListed}}
+	if _, err := handler.PropertyCollection.UpdateMany(context.Background(), filter, update); err != nil {
+		log.Println("failed to update listings:", err)
+		return
+	}
+	log.Printf("%s updated %d properties", function, len(propertyIds))
+}
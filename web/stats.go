@@ -0,0 +1,348 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// statsIntervals maps the interval query parameter to the $dateToString format of its buckets.
+var statsIntervals = map[string]string{
+	"day":   "%Y-%m-%d",
+	"week":  "%G-W%V",
+	"month": "%Y-%m",
+	"year":  "%Y",
+}
+
+const statsDateLayout = "2006-01-02"
+
+// ledgerDateLayout is the layout of transaction dates on the ledger.
+const ledgerDateLayout = "2006-01-02 15:04:05"
+
+// syncTransactions copies the ledger's transactions of a property into the transaction read
+// model, so that a reversal also updates the status of the sale it reversed. It reads as the
+// registrar, since sale amounts are private.
+func (handler *Handler) syncTransactions(propertyId string) error {
+	data, err := handler.contractFor(RoleRegistrar).EvaluateTransaction("transfer:GetByProperty", propertyId)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	var transactions []TransactionDto
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		return fmt.Errorf("failed to decode transaction data: %v", err)
+	}
+	var property Property
+	if err := handler.PropertyCollection.FindOne(context.Background(), bson.M{"_id": propertyId}).Decode(&property); err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	for _, transaction := range transactions {
+		date, err := time.Parse(ledgerDateLayout, transaction.Date)
+		if err != nil {
+			return fmt.Errorf("invalid date on transaction %s: %v", transaction.Id, err)
+		}
+		record := Transaction{
+			Id:          transaction.Id,
+			Type:        transaction.Type,
+			PropertyId:  transaction.PropertyId,
+			Location:    property.Location,
+			BuyerEmail:  transaction.BuyerEmail,
+			SellerEmail: transaction.SellerEmail,
+			Amount:      transaction.Amount,
+			Currency:    transaction.Currency,
+			Date:        date,
+			Status:      transaction.Status,
+		}
+		filter := bson.M{"_id": record.Id}
+		if _, err := handler.TransactionCollection.ReplaceOne(context.Background(), filter, record, options.Replace().SetUpsert(true)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStats reports listing counts, per-location figures and registrations and completed sales per
+// interval (day, week, month or year), optionally limited to a location and to dates between from
+// and to (YYYY-MM-DD, both inclusive). Prices and amounts are minor units of the currency
+// parameter, USD by default, and only records in that currency are counted. The ledger's counters
+// are returned alongside.
+func (handler *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(*Claims)
+	if claims.Role != RoleAdmin {
+		CreateResponse(w, errors.New("only admins can read registry statistics"), nil, http.StatusForbidden)
+		return
+	}
+	query := r.URL.Query()
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = "month"
+	}
+	format, ok := statsIntervals[interval]
+	if !ok {
+		CreateResponse(w, fmt.Errorf("unknown interval %q", interval), nil, http.StatusBadRequest)
+		return
+	}
+	currency, err := currencyParam(r)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	dates := bson.M{}
+	if from := query.Get("from"); from != "" {
+		start, err := time.Parse(statsDateLayout, from)
+		if err != nil {
+			CreateResponse(w, errors.New("from should be a YYYY-MM-DD date"), nil, http.StatusBadRequest)
+			return
+		}
+		dates["$gte"] = start
+	}
+	if to := query.Get("to"); to != "" {
+		end, err := time.Parse(statsDateLayout, to)
+		if err != nil {
+			CreateResponse(w, errors.New("to should be a YYYY-MM-DD date"), nil, http.StatusBadRequest)
+			return
+		}
+		dates["$lt"] = end.AddDate(0, 0, 1)
+	}
+
+	properties := bson.M{"retired": bson.M{"$ne": true}, "currency": currency}
+	registrations := bson.M{"registered_at": bson.M{"$exists": true}, "currency": currency}
+	sales := bson.M{"type": "Sale", "status": "Completed", "currency": currency}
+	if location := query.Get("location"); location != "" {
+		match := locationMatch(location)
+		properties["location"] = match
+		registrations["location"] = match
+		sales["location"] = match
+	}
+	if len(dates) > 0 {
+		registrations["registered_at"] = dates
+		sales["date"] = dates
+	}
+
+	stats := StatsDto{Interval: interval, Currency: currency, Locations: []LocationStatsDto{}, Periods: []PeriodStatsDto{}}
+	if err := handler.locationStats(&stats, properties, sales); err != nil {
+		CreateResponse(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	if err := handler.periodStats(&stats, format, registrations, sales); err != nil {
+		CreateResponse(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	data, err := handler.Contract.EvaluateTransaction("registry:GetSummary")
+	if err != nil {
+		CreateChaincodeErrorResponse(w, err)
+		return
+	}
+	if err := json.Unmarshal(data, &stats.Ledger); err != nil {
+		CreateResponse(w, fmt.Errorf("failed to decode registry summary: %v", err), nil, http.StatusBadRequest)
+		return
+	}
+	CreateResponse(w, nil, stats, http.StatusOK)
+}
+
+// locationStats groups the active properties and the completed sales by location and totals the
+// listing counts.
+func (handler *Handler) locationStats(stats *StatsDto, properties bson.M, sales bson.M) error {
+	var propertyRows []struct {
+		Location     string  `bson:"_id"`
+		Properties   int     `bson:"properties"`
+		Listed       int     `bson:"listed"`
+		AveragePrice float64 `bson:"average_price"`
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: properties}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$location",
+			"properties":    bson.M{"$sum": 1},
+			"listed":        bson.M{"$sum": bson.M{"$cond": bson.A{"$is_listed", 1, 0}}},
+			"average_price": bson.M{"$avg": "$price"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	if err := aggregate(handler.PropertyCollection, pipeline, &propertyRows); err != nil {
+		return err
+	}
+	var saleRows []struct {
+		Location    string  `bson:"_id"`
+		Sales       int     `bson:"sales"`
+		SalesVolume float64 `bson:"sales_volume"`
+	}
+	pipeline = mongo.Pipeline{
+		{{Key: "$match", Value: sales}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          "$location",
+			"sales":        bson.M{"$sum": 1},
+			"sales_volume": bson.M{"$sum": "$amount"},
+		}}},
+	}
+	if err := aggregate(handler.TransactionCollection, pipeline, &saleRows); err != nil {
+		return err
+	}
+
+	positions := make(map[string]int)
+	for _, row := range propertyRows {
+		positions[row.Location] = len(stats.Locations)
+		stats.Locations = append(stats.Locations, LocationStatsDto{
+			Location:     row.Location,
+			Properties:   row.Properties,
+			Listed:       row.Listed,
+			AveragePrice: row.AveragePrice,
+		})
+		stats.Listed += row.Listed
+		stats.Unlisted += row.Properties - row.Listed
+	}
+	for _, row := range saleRows {
+		position, ok := positions[row.Location]
+		if !ok {
+			position = len(stats.Locations)
+			stats.Locations = append(stats.Locations, LocationStatsDto{Location: row.Location})
+		}
+		stats.Locations[position].Sales = row.Sales
+		stats.Locations[position].SalesVolume = row.SalesVolume
+	}
+	return nil
+}
+
+// periodStats buckets registrations and completed sales by the $dateToString format.
+func (handler *Handler) periodStats(stats *StatsDto, format string, registrations bson.M, sales bson.M) error {
+	var registrationRows []struct {
+		Period        string `bson:"_id"`
+		Registrations int    `bson:"registrations"`
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: registrations}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"$dateToString": bson.M{"format": format, "date": "$registered_at"}},
+			"registrations": bson.M{"$sum": 1},
+		}}},
+	}
+	if err := aggregate(handler.PropertyCollection, pipeline, &registrationRows); err != nil {
+		return err
+	}
+	var saleRows []struct {
+		Period           string  `bson:"_id"`
+		Sales            int     `bson:"sales"`
+		SalesVolume      float64 `bson:"sales_volume"`
+		AverageSalePrice float64 `bson:"average_sale_price"`
+	}
+	pipeline = mongo.Pipeline{
+		{{Key: "$match", Value: sales}},
+		{{Key: "$group", Value: bson.M{
+			"_id":                bson.M{"$dateToString": bson.M{"format": format, "date": "$date"}},
+			"sales":              bson.M{"$sum": 1},
+			"sales_volume":       bson.M{"$sum": "$amount"},
+			"average_sale_price": bson.M{"$avg": "$amount"},
+		}}},
+	}
+	if err := aggregate(handler.TransactionCollection, pipeline, &saleRows); err != nil {
+		return err
+	}
+
+	periods := make(map[string]*PeriodStatsDto)
+	for _, row := range registrationRows {
+		periods[row.Period] = &PeriodStatsDto{Period: row.Period, Registrations: row.Registrations}
+	}
+	for _, row := range saleRows {
+		period, ok := periods[row.Period]
+		if !ok {
+			period = &PeriodStatsDto{Period: row.Period}
+			periods[row.Period] = period
+		}
+		period.Sales = row.Sales
+		period.SalesVolume = row.SalesVolume
+		period.AverageSalePrice = row.AverageSalePrice
+	}
+	for _, period := range periods {
+		stats.Periods = append(stats.Periods, *period)
+	}
+	sort.Slice(stats.Periods, func(i, j int) bool { return stats.Periods[i].Period < stats.Periods[j].Period })
+	return nil
+}
+
+// GetMarketTrends reports the number of completed sales and their median and mean amount per
+// month, optionally for a single location. Amounts are minor units of the currency parameter,
+// USD by default.
+func (handler *Handler) GetMarketTrends(w http.ResponseWriter, r *http.Request) {
+	currency, err := currencyParam(r)
+	if err != nil {
+		CreateResponse(w, err, nil, http.StatusBadRequest)
+		return
+	}
+	sales := bson.M{"type": "Sale", "status": "Completed", "currency": currency}
+	if location := r.URL.Query().Get("location"); location != "" {
+		sales["location"] = locationMatch(location)
+	}
+	var rows []struct {
+		Month   string  `bson:"_id"`
+		Amounts []int64 `bson:"amounts"`
+		Mean    float64 `bson:"mean"`
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: sales}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     bson.M{"$dateToString": bson.M{"format": statsIntervals["month"], "date": "$date"}},
+			"amounts": bson.M{"$push": "$amount"},
+			"mean":    bson.M{"$avg": "$amount"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	if err := aggregate(handler.TransactionCollection, pipeline, &rows); err != nil {
+		CreateResponse(w, err, nil, http.StatusInternalServerError)
+		return
+	}
+	trends := make([]MarketTrendDto, 0, len(rows))
+	for _, row := range rows {
+		trends = append(trends, MarketTrendDto{
+			Month:       row.Month,
+			Currency:    currency,
+			Sales:       len(row.Amounts),
+			MedianPrice: median(row.Amounts),
+			MeanPrice:   row.Mean,
+		})
+	}
+	CreateResponse(w, nil, trends, http.StatusOK)
+}
+
+func median(values []int64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return float64(values[middle-1]+values[middle]) / 2
+	}
+	return float64(values[middle])
+}
+
+func currencyParam(r *http.Request) (string, error) {
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		return DefaultCurrency, nil
+	}
+	return currency, ValidateCurrency(currency)
+}
+
+func aggregate(collection *mongo.Collection, pipeline mongo.Pipeline, results interface{}) error {
+	cursor, err := collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(context.Background(), results)
+}
+
+// locationMatch matches a location case-insensitively and in full.
+func locationMatch(location string) bson.M {
+	return bson.M{"$regex": "^" + regexp.QuoteMeta(location) + "$", "$options": "i"}
+}
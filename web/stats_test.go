@@ -0,0 +1,27 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		want   float64
+	}{
+		{name: "no values", values: nil, want: 0},
+		{name: "one value", values: []int64{1250}, want: 1250},
+		{name: "odd count", values: []int64{300, 100, 200}, want: 200},
+		{name: "even count averages the middle values", values: []int64{400, 100, 300, 200}, want: 250},
+		{name: "half a minor unit", values: []int64{1, 2}, want: 1.5},
+		{name: "repeated values", values: []int64{5, 5, 5, 9}, want: 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, median(test.values))
+		})
+	}
+}
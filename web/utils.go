@@ -4,11 +4,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
 	"github.com/jinzhu/copier"
+	"google.golang.org/grpc/status"
 )
 
 func CreateResponse(w http.ResponseWriter, err error, data interface{},code int) {
@@ -30,6 +36,41 @@ func CreateResponse(w http.ResponseWriter, err error, data interface{},code int)
 
 }
 
+// chaincodeStatuses maps the error codes the chaincode prefixes its errors with to HTTP statuses.
+var chaincodeStatuses = map[string]int{
+	"INVALID_ARGUMENT":  http.StatusBadRequest,
+	"NOT_FOUND":         http.StatusNotFound,
+	"ALREADY_EXISTS":    http.StatusConflict,
+	"PERMISSION_DENIED": http.StatusForbidden,
+}
+
+// ChaincodeError digs the chaincode's own message out of a gateway error, which otherwise only
+// says that endorsement failed, and returns the HTTP status matching its error code. Errors
+// without a code are bad requests.
+func ChaincodeError(err error) (int, error) {
+	message := ""
+	for _, detail := range status.Convert(err).Details() {
+		if errorDetail, ok := detail.(*gateway.ErrorDetail); ok {
+			message = errorDetail.Message
+			break
+		}
+	}
+	if message == "" {
+		message = err.Error()
+	}
+	for code, httpStatus := range chaincodeStatuses {
+		if i := strings.Index(message, code+": "); i >= 0 {
+			return httpStatus, errors.New(message[i:])
+		}
+	}
+	return http.StatusBadRequest, errors.New(message)
+}
+
+func CreateChaincodeErrorResponse(w http.ResponseWriter, err error) {
+	code, err := ChaincodeError(err)
+	CreateResponse(w, err, nil, code)
+}
+
 func ValidRequest(user UserDto) error {
 	if user.Name == "" {
 		return fmt.Errorf("name field should not be empty")
@@ -52,6 +93,143 @@ func ValidRequest(user UserDto) error {
 	return nil
 }
 
+func ValidateRoleRequest(request RoleRequest) error {
+	if request.Email == "" {
+		return errors.New("email field should not be empty")
+	}
+	if !validRoles[request.Role] {
+		return fmt.Errorf("unknown role %q", request.Role)
+	}
+	return nil
+}
+
+var validRoles = map[string]bool{
+	RoleUser:      true,
+	RoleLender:    true,
+	RoleAdmin:     true,
+	RoleRegistrar: true,
+	RoleCourt:     true,
+	RoleAppraiser: true,
+	RoleAuditor:   true,
+}
+
+func ValidateLienDto(lien LienDto) error {
+	if lien.PropertyId == "" {
+		return errors.New("property_id field should not be empty")
+	}
+	if lien.Amount <= 0 {
+		return errors.New("amount should be greater than zero")
+	}
+	if lien.Priority < 1 {
+		return errors.New("priority should be at least 1")
+	}
+	return ValidateCurrency(lien.Currency)
+}
+
+// ValidateFreezeRequest checks a freeze or, when release is set, the release of one.
+func ValidateFreezeRequest(request FreezeRequest, release bool) error {
+	if request.PropertyId == "" {
+		return errors.New("property_id field should not be empty")
+	}
+	if request.Reason == "" {
+		return errors.New("reason field should not be empty")
+	}
+	if release && request.FreezeId == "" {
+		return errors.New("freeze_id field should not be empty")
+	}
+	if !release && request.Reference == "" {
+		return errors.New("reference field should not be empty")
+	}
+	return nil
+}
+
+// ValidateSuccession checks that a succession names the deceased, the probate order and heirs
+// whose shares of the deceased's stake add up to 100%.
+func ValidateSuccession(request SuccessionRequest) error {
+	if request.PropertyId == "" || request.DeceasedEmail == "" {
+		return errors.New("property_id and deceased_email fields should not be empty")
+	}
+	if request.ProbateHash == "" {
+		return errors.New("probate_hash field should not be empty")
+	}
+	if len(request.Heirs) == 0 {
+		return errors.New("at least one heir is required")
+	}
+	total := 0.0
+	for _, heir := range request.Heirs {
+		if heir.Email == "" || heir.Share <= 0 {
+			return errors.New("every heir needs an email and a positive share")
+		}
+		total += heir.Share
+	}
+	if math.Abs(total-100) > 0.005 {
+		return fmt.Errorf("heir shares add up to %.2f instead of 100", total)
+	}
+	return nil
+}
+
+func ValidateDelegation(request DelegationRequest) error {
+	if request.AgentEmail == "" {
+		return errors.New("agent_email field should not be empty")
+	}
+	if len(request.Scope) == 0 {
+		return errors.New("scope should name at least one action")
+	}
+	for _, action := range request.Scope {
+		if action != delegateList && action != delegateSell {
+			return fmt.Errorf("unknown action %q in scope", action)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, request.Expiry); err != nil {
+		return errors.New("expiry should be an RFC3339 timestamp")
+	}
+	return nil
+}
+
+// ValidateListingRequest checks a listing window. An empty starts_at means the listing goes
+// live straight away.
+func ValidateListingRequest(request *ListingRequest) error {
+	if request.PropertyId == "" {
+		return errors.New("property_id field should not be empty")
+	}
+	if request.StartsAt == "" {
+		request.StartsAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	start, err := time.Parse(time.RFC3339, request.StartsAt)
+	if err != nil {
+		return errors.New("starts_at should be an RFC3339 timestamp")
+	}
+	end, err := time.Parse(time.RFC3339, request.EndsAt)
+	if err != nil {
+		return errors.New("ends_at should be an RFC3339 timestamp")
+	}
+	if !end.After(start) {
+		return errors.New("ends_at should be after starts_at")
+	}
+	return nil
+}
+
+func ValidateValuationRequest(request ValuationRequest) error {
+	if request.PropertyId == "" {
+		return errors.New("property_id field should not be empty")
+	}
+	if request.Value <= 0 {
+		return errors.New("value should be greater than zero")
+	}
+	if request.Method == "" {
+		return errors.New("method field should not be empty")
+	}
+	if request.ReportHash == "" {
+		return errors.New("report_hash field should not be empty")
+	}
+	return ValidateCurrency(request.Currency)
+}
+
+// CanFreeze reports whether the role may place or lift legal holds on properties.
+func CanFreeze(role string) bool {
+	return role == RoleRegistrar || role == RoleCourt
+}
+
 func ValidatePropertyDto(property PropertyDto) (error){
 	 if property.Title==""{
 		return errors.New("title field should not be empty")
@@ -62,14 +240,144 @@ func ValidatePropertyDto(property PropertyDto) (error){
 	 if property.Size==0{
 		return errors.New("size field should not be empty")
 	 }
-	 if property.Price==0{
-		return errors.New("price field should not be empty")
+	 if property.Price<=0{
+		return errors.New("price should be a positive amount in minor units")
+	 }
+	 if err := ValidateCurrency(property.Currency); err != nil {
+		return err
+	 }
+	 if property.Boundary != nil {
+		return ValidateBoundary(property.Boundary)
 	 }
 	 return nil
 }
 
+// DefaultCurrency is the currency of requests that do not name one.
+const DefaultCurrency = "USD"
+
+// ValidateCurrency only checks that currency looks like an ISO 4217 code. The chaincode owns the
+// list of accepted currencies and their minor units, and rejects the others.
+func ValidateCurrency(currency string) error {
+	if len(currency) != 3 || strings.ToUpper(currency) != currency || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fmt.Errorf("unsupported currency %q", currency)
+	}
+	return nil
+}
+
 func ConvertToDto[S any, T any](source S, destination T) T {
 	copier.Copy(&destination, source)
 	return destination
 }
 
+
+func ValidateShareTransfer(request ShareTransferRequest) error {
+	if request.PropertyId == "" {
+		return errors.New("property_id field should not be empty")
+	}
+	if request.ToEmail == "" {
+		return errors.New("to_email field should not be empty")
+	}
+	if request.Share <= 0 || request.Share > 100 {
+		return errors.New("share should be greater than 0 and at most 100")
+	}
+	return nil
+}
+
+// IsOwner reports whether email holds a share of the property. Documents written before
+// co-ownership was introduced only carry the owner email.
+func IsOwner(property Property, email string) bool {
+	if len(property.Owners) == 0 {
+		return property.OwnerEmail == email
+	}
+	for _, owner := range property.Owners {
+		if owner.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateLeaseDto(lease LeaseDto) error {
+	if lease.PropertyId == "" {
+		return errors.New("property_id field should not be empty")
+	}
+	if lease.TenantEmail == "" {
+		return errors.New("tenant_email field should not be empty")
+	}
+	if lease.StartDate == "" || lease.EndDate == "" {
+		return errors.New("start_date and end_date should not be empty")
+	}
+	if lease.Rent <= 0 {
+		return errors.New("rent should be greater than zero")
+	}
+	if lease.Deposit < 0 {
+		return errors.New("deposit should not be negative")
+	}
+	return ValidateCurrency(lease.Currency)
+}
+
+// DetectMediaType trusts a specific declared content type and otherwise sniffs the first
+// bytes of the upload, rewinding it afterwards.
+func DetectMediaType(file io.ReadSeeker, declared string) (string, error) {
+	if declared != "" && declared != "application/octet-stream" {
+		return declared, nil
+	}
+	buffer := make([]byte, 512)
+	n, err := file.Read(buffer)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+// ParseFloats parses exactly count comma separated numbers.
+func ParseFloats(value string, count int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d numbers", count)
+	}
+	numbers := make([]float64, 0, count)
+	for _, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// PreparePropertyPart validates a parcel created by a subdivision or merge, assigns it an id
+// and anchors its boundary hash. Co-owners giving consent resend the ids from the first request.
+func PreparePropertyPart(part *PropertyPartDto, requireSize bool) error {
+	if part.Title == "" || part.Location == "" {
+		return errors.New("title and location fields should not be empty")
+	}
+	if (requireSize && part.Size <= 0) || part.Price <= 0 {
+		return errors.New("size and price should be greater than zero")
+	}
+	if part.Currency == "" {
+		part.Currency = DefaultCurrency
+	}
+	if err := ValidateCurrency(part.Currency); err != nil {
+		return err
+	}
+	if part.Id == "" {
+		part.Id = "p" + uuid.New().String()
+	}
+	part.BoundaryHash = ""
+	if part.Boundary != nil {
+		if err := ValidateBoundary(part.Boundary); err != nil {
+			return err
+		}
+		hash, err := BoundaryHash(part.Boundary)
+		if err != nil {
+			return err
+		}
+		part.BoundaryHash = hash
+	}
+	return nil
+}
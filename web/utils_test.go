@@ -0,0 +1,32 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		wantErr  bool
+	}{
+		{currency: "USD"},
+		{currency: "KES"},
+		{currency: "", wantErr: true},
+		{currency: "usd", wantErr: true},
+		{currency: "US", wantErr: true},
+		{currency: "USDT", wantErr: true},
+		{currency: "U$D", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.currency, func(t *testing.T) {
+			err := ValidateCurrency(test.currency)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}